@@ -0,0 +1,95 @@
+package agreement
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/shinmigo/gopay/alipay/kernel"
+)
+
+type Agreement struct {
+	Client *kernel.AliPayClient
+}
+
+/**
+ * 支付宝个人协议页面签约接口(PC/H5页面跳转)
+ */
+func (m *Agreement) PageSign(param *PageSign) (string, error) {
+	urlMap, err := m.signParams(param)
+	if err != nil {
+		return "", err
+	}
+	return m.Client.GetGatewayHost() + "?" + urlMap.Encode(), nil
+}
+
+/**
+ * 支付宝个人协议页面签约接口(APP唤起支付宝签约)
+ */
+func (m *Agreement) AppSign(param *PageSign) (string, error) {
+	urlMap, err := m.signParams(param)
+	if err != nil {
+		return "", err
+	}
+	return AppSignSchemeUrl + url.QueryEscape(urlMap.Encode()), nil
+}
+
+/**
+ * 支付宝个人代扣协议查询接口
+ */
+func (m *Agreement) Query(param *Query) (result *QueryRes, err error) {
+	if param == nil {
+		return nil, errors.New(kernel.InitializeDataErr)
+	}
+
+	err = m.Client.SendRequest("POST", param, &result)
+	return result, err
+}
+
+/**
+ * 支付宝个人代扣协议解约接口
+ */
+func (m *Agreement) Unsign(param *Unsign) (result *UnsignRes, err error) {
+	if param == nil {
+		return nil, errors.New(kernel.InitializeDataErr)
+	}
+
+	err = m.Client.SendRequest("POST", param, &result)
+	return result, err
+}
+
+/**
+ * 签约、解约异步通知验证签名
+ */
+func (m *Agreement) NotifyVerify(notifyData url.Values) (*Notify, error) {
+	notifyType := notifyData.Get("notify_type")
+	if notifyType != NotifyTypeSign && notifyType != NotifyTypeUnsign {
+		return nil, fmt.Errorf("notify_type 不正确: %s", notifyType)
+	}
+	if ok, err := m.Client.NotifyVerify(notifyData); ok == false {
+		return nil, err
+	}
+
+	res := &Notify{}
+	if err := kernel.DecodeNotify(notifyData, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+/**
+ * 组装签约请求参数
+ */
+func (m *Agreement) signParams(param *PageSign) (url.Values, error) {
+	if param == nil {
+		return nil, errors.New(kernel.InitializeDataErr)
+	}
+
+	if len(param.PersonalProductCode) == 0 {
+		param.PersonalProductCode = PersonalProductCodeCyclePay
+	}
+	if len(param.ProductCode) == 0 {
+		param.ProductCode = ProductCodeCyclePay
+	}
+	return m.Client.UrlParams(param)
+}
@@ -0,0 +1,173 @@
+package agreement
+
+import "github.com/shinmigo/gopay/alipay/kernel"
+
+const (
+	ProductCodeCyclePay         = "CYCLE_PAY_AUTH"   // 周期扣款产品码
+	PersonalProductCodeCyclePay = "CYCLE_PAY_AUTH_P" // 周期扣款个人签约产品码
+
+	NotifyTypeSign   = "dut_user_sign"   // 签约成功通知
+	NotifyTypeUnsign = "dut_user_unsign" // 解约成功通知
+
+	/**
+	 * APP唤起支付宝签约页面的地址前缀
+	 */
+	AppSignSchemeUrl = "alipays://platformapi/startapp?appId=60000157&appClearTop=false&startMultApp=YES&sign_params="
+)
+
+type AccessParams struct {
+	Channel string `json:"channel"` // 目前支持以下值：ALIPAYAPP（钱包h5页面签约） QRCODE（扫码签约） QRCODEORSMS（扫码签约或者短信签约）
+}
+
+type PeriodRuleParams struct {
+	PeriodType    string `json:"period_type"`              // 周期类型，枚举值为DAY和MONTH
+	Period        int    `json:"period"`                   // 周期数，与PeriodType组合使用确定扣款周期
+	ExecuteTime   string `json:"execute_time"`             // 商户发起首次扣款的时间，精确到日，格式为yyyy-MM-dd
+	SingleAmount  string `json:"single_amount"`            // 单次扣款最大金额，单位为元
+	TotalAmount   string `json:"total_amount,omitempty"`   // 周期内允许扣款的总金额，单位为元
+	TotalPayments int    `json:"total_payments,omitempty"` // 总扣款次数
+}
+
+/**
+ * 支付宝个人协议页面签约接口
+ */
+type PageSign struct {
+	PersonalProductCode string            `json:"personal_product_code"`           // 个人签约产品码，周期扣款取值CYCLE_PAY_AUTH_P
+	SignScene           string            `json:"sign_scene"`                      // 协议签约场景，商户和支付宝签约时确定，如INDUSTRY|DIGITAL_MEDIA
+	ProductCode         string            `json:"product_code,omitempty"`          // 销售产品码，周期扣款取值CYCLE_PAY_AUTH
+	ExternalAgreementNo string            `json:"external_agreement_no"`           // 商户签约号，代扣协议中标示用户的唯一签约号（确保在商户系统中唯一）
+	ExternalLogonId     string            `json:"external_logon_id,omitempty"`     // 用户在商户网站的登录账号，用于在签约页面展示
+	AccessParams        *AccessParams     `json:"access_params"`                   // 请按当前接入的方式进行填充，且输入值必须为文档中的参数取值范围
+	PeriodRuleParams    *PeriodRuleParams `json:"period_rule_params,omitempty"`    // 周期管控规则参数，周期扣款场景必传
+	SignValidityPeriod  string            `json:"sign_validity_period,omitempty"`  // 当前用户签约请求的协议有效周期，如2m、5d、1y
+	ThirdPartyType      string            `json:"third_party_type,omitempty"`      // 签约第三方主体类型，默认为PARTNER
+	MerchantProcessUrl  string            `json:"merchant_process_url,omitempty"`  // 签约成功后商户用于领取红包等的跳转页面地址
+	PromoParams         string            `json:"promo_params,omitempty"`          // 签约营销参数，json格式
+	AgreementEffectType string            `json:"agreement_effect_type,omitempty"` // 签约有效的时间限制，单位是秒
+	NotifyUrl           string            `json:"-"`                               //异步通知地址，为空时使用客户端配置
+	ReturnUrl           string            `json:"-"`                               //签约返回地址
+}
+
+func (m *PageSign) GetAliPayMethod() string {
+	return "alipay.user.agreement.page.sign"
+}
+
+func (m *PageSign) GetTextParams() map[string]string {
+	textParams := make(map[string]string, 2)
+	if len(m.NotifyUrl) > 0 {
+		textParams["notify_url"] = m.NotifyUrl
+	}
+	if len(m.ReturnUrl) > 0 {
+		textParams["return_url"] = m.ReturnUrl
+	}
+	return textParams
+}
+
+/**
+ * 支付宝个人代扣协议查询接口
+ */
+type Query struct {
+	PersonalProductCode string `json:"personal_product_code,omitempty"` // 协议产品码
+	AlipayUserId        string `json:"alipay_user_id,omitempty"`        // 用户的支付宝账号对应的支付宝唯一用户号
+	AlipayLogonId       string `json:"alipay_logon_id,omitempty"`       // 用户的支付宝登录账号
+	SignScene           string `json:"sign_scene,omitempty"`            // 签约协议场景
+	ExternalAgreementNo string `json:"external_agreement_no,omitempty"` // 代扣协议中标示用户的唯一签约号
+	ThirdPartyType      string `json:"third_party_type,omitempty"`      // 签约第三方主体类型
+	AgreementNo         string `json:"agreement_no,omitempty"`          // 支付宝系统中用以唯一标识用户签约记录的编号，传入时其它查询条件将被忽略
+}
+
+func (m *Query) GetAliPayMethod() string {
+	return "alipay.user.agreement.query"
+}
+
+func (m *Query) IsIdempotent() bool {
+	return true
+}
+
+type QueryResContent struct {
+	Code                string `json:"code"`                  //网关返回码
+	Msg                 string `json:"msg"`                   //网关返回描述
+	SubCode             string `json:"sub_code"`              //业务返回码
+	SubMsg              string `json:"sub_msg"`               //业务返回码描述
+	ValidTime           string `json:"valid_time"`            // 协议生效时间
+	AlipayLogonId       string `json:"alipay_logon_id"`       // 返回脱敏的支付宝账号
+	InvalidTime         string `json:"invalid_time"`          // 协议失效时间
+	PrincipalType       string `json:"pricipal_type"`         // 签约主体类型，CARD:支付宝账号 CUSTOMER:支付宝用户
+	DeviceId            string `json:"device_id"`             // 设备Id
+	PrincipalId         string `json:"principal_id"`          // 签约主体标识
+	SignScene           string `json:"sign_scene"`            // 签约协议的场景
+	AgreementNo         string `json:"agreement_no"`          // 用户签约成功后的协议号
+	ThirdPartyType      string `json:"third_party_type"`      // 签约第三方主体类型
+	Status              string `json:"status"`                // 协议当前状态，TEMP：暂存 NORMAL：正常 STOP：暂停
+	SignTime            string `json:"sign_time"`             // 协议签约时间
+	PersonalProductCode string `json:"personal_product_code"` // 协议产品码
+	ExternalAgreementNo string `json:"external_agreement_no"` // 代扣协议中标示用户的唯一签约号
+	ZmOpenId            string `json:"zm_open_id"`            // 用户的芝麻信用openId
+	ExternalLogonId     string `json:"external_logon_id"`     // 外部登录Id
+	CreditAuthMode      string `json:"credit_auth_mode"`      // 授信模式
+	SingleQuota         string `json:"single_quota"`          // 单笔代扣额度
+	LastDeductTime      string `json:"last_deduct_time"`      // 周期扣协议，上次扣款成功时间
+	NextDeductTime      string `json:"next_deduct_time"`      // 周期扣协议，预计下次扣款时间
+}
+
+type QueryRes = kernel.Response[QueryResContent]
+
+/**
+ * 支付宝个人代扣协议解约接口
+ */
+type Unsign struct {
+	AlipayUserId        string `json:"alipay_user_id,omitempty"`        // 用户的支付宝账号对应的支付宝唯一用户号
+	AlipayLogonId       string `json:"alipay_logon_id,omitempty"`       // 用户的支付宝登录账号
+	PersonalProductCode string `json:"personal_product_code,omitempty"` // 协议产品码
+	SignScene           string `json:"sign_scene,omitempty"`            // 签约协议场景
+	ExternalAgreementNo string `json:"external_agreement_no,omitempty"` // 代扣协议中标示用户的唯一签约号
+	ThirdPartyType      string `json:"third_party_type,omitempty"`      // 签约第三方主体类型
+	AgreementNo         string `json:"agreement_no,omitempty"`          // 支付宝系统中用以唯一标识用户签约记录的编号
+	ExtendParams        string `json:"extend_params,omitempty"`         // 扩展参数，json格式
+	OperateType         string `json:"operate_type,omitempty"`          // 注销操作类型，confirm（解约确认），invalid（解约作废）
+}
+
+func (m *Unsign) GetAliPayMethod() string {
+	return "alipay.user.agreement.unsign"
+}
+
+type UnsignResContent struct {
+	Code    string `json:"code"`     //网关返回码
+	Msg     string `json:"msg"`      //网关返回描述
+	SubCode string `json:"sub_code"` //业务返回码
+	SubMsg  string `json:"sub_msg"`  //业务返回码描述
+}
+
+type UnsignRes = kernel.Response[UnsignResContent]
+
+/**
+ * 签约、解约异步通知参数
+ */
+type Notify struct {
+	NotifyTime          string `json:"notify_time"`           // 通知的发送时间。格式为yyyy-MM-dd HH:mm:ss
+	NotifyType          string `json:"notify_type"`           // 通知的类型，dut_user_sign:签约 dut_user_unsign:解约
+	NotifyId            string `json:"notify_id"`             // 通知校验ID
+	Charset             string `json:"charset"`               // 编码格式
+	Version             string `json:"version"`               // 调用的接口版本
+	SignType            string `json:"sign_type"`             // 签名算法类型
+	Sign                string `json:"sign"`                  // 签名
+	AppId               string `json:"app_id"`                // 开发者APP_ID
+	AuthAppId           string `json:"auth_app_id"`           // 授权方的appid
+	PartnerId           string `json:"partner_id"`            // 签约的商户pid
+	AgreementNo         string `json:"agreement_no"`          // 支付宝系统中用以唯一标识用户签约记录的编号
+	ExternalAgreementNo string `json:"external_agreement_no"` // 商户签约号
+	PersonalProductCode string `json:"personal_product_code"` // 协议产品码
+	SignScene           string `json:"sign_scene"`            // 签约协议场景
+	Status              string `json:"status"`                // 协议状态，NORMAL：正常 UNSIGN：解约
+	AlipayUserId        string `json:"alipay_user_id"`        // 用户的支付宝唯一用户号
+	AlipayLogonId       string `json:"alipay_logon_id"`       // 用户的支付宝登录账号
+	ExternalLogonId     string `json:"external_logon_id"`     // 用户在商户网站的登录账号
+	SignTime            string `json:"sign_time"`             // 协议签约时间
+	ValidTime           string `json:"valid_time"`            // 协议生效时间
+	InvalidTime         string `json:"invalid_time"`          // 协议失效时间
+	UnsignTime          string `json:"unsign_time"`           // 协议解约时间
+	ZmOpenId            string `json:"zm_open_id"`            // 用户的芝麻信用openId
+	CreditAuthMode      string `json:"credit_auth_mode"`      // 授信模式
+	SingleQuota         string `json:"single_quota"`          // 单笔代扣额度
+	ForexEligible       string `json:"forex_eligible"`        // 是否海外购汇身份
+}
@@ -0,0 +1,96 @@
+package fund
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/shinmigo/gopay/alipay/kernel"
+)
+
+type Fund struct {
+	Client *kernel.AliPayClient
+}
+
+/**
+ * 线上资金授权冻结接口(APP)
+ */
+func (m *Fund) AppFreeze(param *AppFreeze) (string, error) {
+	if param == nil {
+		return "", errors.New(kernel.InitializeDataErr)
+	}
+
+	if len(param.ProductCode) == 0 {
+		param.ProductCode = ProductCodePreAuthOnline
+	}
+	urlMap, err := m.Client.UrlParams(param)
+	if err != nil {
+		return "", err
+	}
+	return urlMap.Encode(), nil
+}
+
+/**
+ * 资金授权发码接口
+ */
+func (m *Fund) VoucherCreate(param *VoucherCreate) (result *VoucherCreateRes, err error) {
+	if param == nil {
+		return nil, errors.New(kernel.InitializeDataErr)
+	}
+
+	if len(param.ProductCode) == 0 {
+		param.ProductCode = ProductCodePreAuth
+	}
+	err = m.Client.SendRequest("POST", param, &result)
+	return result, err
+}
+
+/**
+ * 资金授权解冻接口
+ */
+func (m *Fund) Unfreeze(param *Unfreeze) (result *UnfreezeRes, err error) {
+	if param == nil {
+		return nil, errors.New(kernel.InitializeDataErr)
+	}
+
+	err = m.Client.SendRequest("POST", param, &result)
+	return result, err
+}
+
+/**
+ * 资金授权操作查询接口
+ */
+func (m *Fund) OperationDetailQuery(param *OperationDetailQuery) (result *OperationDetailQueryRes, err error) {
+	if param == nil {
+		return nil, errors.New(kernel.InitializeDataErr)
+	}
+
+	err = m.Client.SendRequest("POST", param, &result)
+	return result, err
+}
+
+/**
+ * 资金授权撤销接口
+ */
+func (m *Fund) OperationCancel(param *OperationCancel) (result *OperationCancelRes, err error) {
+	if param == nil {
+		return nil, errors.New(kernel.InitializeDataErr)
+	}
+
+	err = m.Client.SendRequest("POST", param, &result)
+	return result, err
+}
+
+/**
+ * 资金授权冻结、解冻异步通知验证签名
+ */
+func (m *Fund) NotifyVerify(notifyData url.Values) (*Notify, error) {
+	if ok, err := m.Client.NotifyVerify(notifyData); ok == false {
+		return nil, err
+	}
+
+	res := &Notify{}
+	if err := kernel.DecodeNotify(notifyData, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,252 @@
+package fund
+
+import "github.com/shinmigo/gopay/alipay/kernel"
+
+const (
+	ProductCodePreAuthOnline = "PRE_AUTH_ONLINE" // 线上资金授权
+	ProductCodePreAuth       = "PRE_AUTH"        // 当面资金授权
+
+	NotifyTypeFreeze   = "fund_auth_freeze"   // 冻结成功通知
+	NotifyTypeUnfreeze = "fund_auth_unfreeze" // 解冻成功通知
+
+	OperationTypeFreeze   = "FREEZE"   // 冻结
+	OperationTypeUnfreeze = "UNFREEZE" // 解冻
+	OperationTypePay      = "PAY"      // 支付
+)
+
+/**
+ * 线上资金授权冻结接口
+ */
+type AppFreeze struct {
+	OutOrderNo         string `json:"out_order_no"`                   // 商户授权资金订单号，不能包含除中文、英文、数字以外的字符
+	OutRequestNo       string `json:"out_request_no"`                 // 商户本次资金操作的请求流水号，同一商户每次不同的资金操作请求，商户请求流水号不要重复
+	OrderTitle         string `json:"order_title"`                    // 业务订单的简单描述，如商品名称等
+	Amount             string `json:"amount"`                         // 需要冻结的金额，单位为：元（人民币），精确到小数点后两位，取值范围[0.01,100000000.00]
+	ProductCode        string `json:"product_code"`                   // 销售产品码，新接入线上预授权的业务，取值PRE_AUTH_ONLINE
+	PayeeLogonId       string `json:"payee_logon_id,omitempty"`       // 收款方支付宝账号（Email或手机号），与PayeeUserId同时传入时以PayeeUserId为准
+	PayeeUserId        string `json:"payee_user_id,omitempty"`        // 收款方的支付宝唯一用户号
+	TimeoutExpress     string `json:"timeout_express,omitempty"`      // 该笔订单允许的最晚付款时间，逾期将关闭该笔订单。取值范围：1m～15d
+	ExtraParam         string `json:"extra_param,omitempty"`          // 业务扩展参数，json格式，如{"category":"RENT_PHONE","outStoreCode":"charge001"}
+	EnablePayChannels  string `json:"enable_pay_channels,omitempty"`  // 商户可用该参数指定用户可使用的支付渠道，json格式
+	DisablePayChannels string `json:"disable_pay_channels,omitempty"` // 商户可用该参数指定用户不可使用的支付渠道，json格式
+	TransCurrency      string `json:"trans_currency,omitempty"`       // 标价币种
+	SettleCurrency     string `json:"settle_currency,omitempty"`      // 商户指定的结算币种
+	DepositProductMode string `json:"deposit_product_mode,omitempty"` // 免押受理台模式，POSTPAY:收费模式 DEPOSIT_ONLY:免押模式
+	IdentityParams     string `json:"identity_params,omitempty"`      // 用户实名信息参数，json格式
+	BusinessParams     string `json:"business_params,omitempty"`      // 商户传入业务信息，json格式
+	SceneCode          string `json:"scene_code,omitempty"`           // 场景码，需与支付宝约定
+	OrderBizCategory   string `json:"order_biz_category,omitempty"`   // 业务类目
+	PayTimeout         string `json:"pay_timeout,omitempty"`          // 该笔订单允许的最晚付款时间，逾期将关闭该笔订单
+	AuthNo             string `json:"auth_no,omitempty"`              // 支付宝资金授权订单号，用于追加冻结
+	NotifyUrl          string `json:"-"`                              //异步通知地址，为空时使用客户端配置
+}
+
+func (m *AppFreeze) GetAliPayMethod() string {
+	return "alipay.fund.auth.order.app.freeze"
+}
+
+func (m *AppFreeze) GetTextParams() map[string]string {
+	textParams := make(map[string]string, 1)
+	if len(m.NotifyUrl) > 0 {
+		textParams["notify_url"] = m.NotifyUrl
+	}
+	return textParams
+}
+
+/**
+ * 资金授权发码接口
+ */
+type VoucherCreate struct {
+	OutOrderNo        string `json:"out_order_no"`                  // 商户授权资金订单号
+	OutRequestNo      string `json:"out_request_no"`                // 商户本次资金操作的请求流水号
+	OrderTitle        string `json:"order_title"`                   // 业务订单的简单描述，如商品名称等
+	Amount            string `json:"amount"`                        // 需要冻结的金额，单位为：元（人民币），精确到小数点后两位
+	ProductCode       string `json:"product_code"`                  // 销售产品码，当面资金授权取值PRE_AUTH
+	PayeeLogonId      string `json:"payee_logon_id,omitempty"`      // 收款方支付宝账号
+	PayeeUserId       string `json:"payee_user_id,omitempty"`       // 收款方的支付宝唯一用户号
+	PayTimeout        string `json:"pay_timeout,omitempty"`         // 该笔订单允许的最晚付款时间，逾期将关闭该笔订单
+	ExtraParam        string `json:"extra_param,omitempty"`         // 业务扩展参数，json格式
+	TransCurrency     string `json:"trans_currency,omitempty"`      // 标价币种
+	SettleCurrency    string `json:"settle_currency,omitempty"`     // 商户指定的结算币种
+	EnablePayChannels string `json:"enable_pay_channels,omitempty"` // 商户可用该参数指定用户可使用的支付渠道
+}
+
+func (m *VoucherCreate) GetAliPayMethod() string {
+	return "alipay.fund.auth.order.voucher.create"
+}
+
+type VoucherCreateResContent struct {
+	Code         string `json:"code"`           //网关返回码
+	Msg          string `json:"msg"`            //网关返回描述
+	SubCode      string `json:"sub_code"`       //业务返回码
+	SubMsg       string `json:"sub_msg"`        //业务返回码描述
+	OutOrderNo   string `json:"out_order_no"`   // 商户的授权资金订单号
+	OutRequestNo string `json:"out_request_no"` // 商户本次资金操作的请求流水号
+	CodeType     string `json:"code_type"`      // 码类型，bar_code:条码 qr_code:二维码
+	CodeValue    string `json:"code_value"`     // 当前发码请求生成的二维码码串
+	CodeUrl      string `json:"code_url"`       // 二维码图片的URL地址
+}
+
+type VoucherCreateRes = kernel.Response[VoucherCreateResContent]
+
+/**
+ * 资金授权解冻接口
+ */
+type Unfreeze struct {
+	AuthNo       string `json:"auth_no"`               // 支付宝资金授权订单号
+	OutRequestNo string `json:"out_request_no"`        // 解冻请求流水号
+	Amount       string `json:"amount"`                // 本次操作解冻的金额，单位为：元（人民币），精确到小数点后两位
+	Remark       string `json:"remark"`                // 商户对本次解冻操作的附言描述
+	ExtraParam   string `json:"extra_param,omitempty"` // 解冻扩展信息，json格式；如{"unfreezeBizInfo":"{\"bizComplete\":\"true\"}"}
+}
+
+func (m *Unfreeze) GetAliPayMethod() string {
+	return "alipay.fund.auth.order.unfreeze"
+}
+
+func (m *Unfreeze) IsIdempotent() bool {
+	return len(m.OutRequestNo) > 0
+}
+
+type UnfreezeResContent struct {
+	Code         string `json:"code"`           //网关返回码
+	Msg          string `json:"msg"`            //网关返回描述
+	SubCode      string `json:"sub_code"`       //业务返回码
+	SubMsg       string `json:"sub_msg"`        //业务返回码描述
+	AuthNo       string `json:"auth_no"`        // 支付宝资金授权订单号
+	OutOrderNo   string `json:"out_order_no"`   // 商户的授权资金订单号
+	OperationId  string `json:"operation_id"`   // 支付宝资金操作流水号
+	OutRequestNo string `json:"out_request_no"` // 解冻请求流水号
+	Amount       string `json:"amount"`         // 本次解冻操作中信用解冻金额
+	Status       string `json:"status"`         // 流水状态，INIT：初始 SUCCESS：成功 CLOSED：关闭
+	GmtTrans     string `json:"gmt_trans"`      // 授权资金解冻成功时间
+	CreditAmount string `json:"credit_amount"`  // 本次解冻操作中信用解冻金额
+	FundAmount   string `json:"fund_amount"`    // 本次解冻操作中自有资金解冻金额
+}
+
+type UnfreezeRes = kernel.Response[UnfreezeResContent]
+
+/**
+ * 资金授权操作查询接口
+ */
+type OperationDetailQuery struct {
+	AuthNo        string `json:"auth_no,omitempty"`        // 支付宝授权资金订单号，与 OutOrderNo 二选一
+	OutOrderNo    string `json:"out_order_no,omitempty"`   // 商户的授权资金订单号，与 AuthNo 二选一
+	OperationId   string `json:"operation_id,omitempty"`   // 支付宝的授权资金操作流水号，与 OutRequestNo 二选一
+	OutRequestNo  string `json:"out_request_no,omitempty"` // 商户的授权资金操作流水号，与 OperationId 二选一
+	OperationType string `json:"operation_type,omitempty"` // 需要查询的授权资金操作类型，FREEZE、UNFREEZE、PAY
+}
+
+func (m *OperationDetailQuery) GetAliPayMethod() string {
+	return "alipay.fund.auth.operation.detail.query"
+}
+
+func (m *OperationDetailQuery) IsIdempotent() bool {
+	return true
+}
+
+type OperationDetailQueryResContent struct {
+	Code                    string `json:"code"`                       //网关返回码
+	Msg                     string `json:"msg"`                        //网关返回描述
+	SubCode                 string `json:"sub_code"`                   //业务返回码
+	SubMsg                  string `json:"sub_msg"`                    //业务返回码描述
+	AuthNo                  string `json:"auth_no"`                    // 支付宝资金授权订单号
+	OutOrderNo              string `json:"out_order_no"`               // 商户的授权资金订单号
+	OrderStatus             string `json:"order_status"`               // 资金授权单据状态，INIT：初始 AUTHORIZED：已授权 FINISH：完成 CLOSED：关闭
+	TotalFreezeAmount       string `json:"total_freeze_amount"`        // 订单累计的冻结金额
+	RestAmount              string `json:"rest_amount"`                // 订单总共剩余的冻结金额
+	TotalPayAmount          string `json:"total_pay_amount"`           // 订单累计用于支付的金额
+	OrderTitle              string `json:"order_title"`                // 业务订单的简单描述
+	PayerLogonId            string `json:"payer_logon_id"`             // 付款方支付宝账号登录号
+	PayerUserId             string `json:"payer_user_id"`              // 付款方支付宝账号UID
+	ExtraParam              string `json:"extra_param"`                // 商户请求创建预授权订单时传入的扩展参数
+	OperationId             string `json:"operation_id"`               // 支付宝资金操作流水号
+	OutRequestNo            string `json:"out_request_no"`             // 商户资金操作的请求流水号
+	Amount                  string `json:"amount"`                     // 该笔资金操作流水operation_id对应的操作金额
+	OperationType           string `json:"operation_type"`             // 支付宝资金操作类型
+	Status                  string `json:"status"`                     // 资金操作流水的状态，INIT：初始 SUCCESS：成功 CLOSED：关闭
+	Remark                  string `json:"remark"`                     // 商户对本次操作的附言描述
+	GmtCreate               string `json:"gmt_create"`                 // 资金授权单据操作流水创建时间
+	GmtTrans                string `json:"gmt_trans"`                  // 支付宝账务处理成功时间
+	PreAuthType             string `json:"pre_auth_type"`              // 预授权类型，CREDIT_AUTH:信用预授权
+	TransCurrency           string `json:"trans_currency"`             // 标价币种
+	TotalFreezeCreditAmount string `json:"total_freeze_credit_amount"` // 累计冻结信用金额
+	TotalFreezeFundAmount   string `json:"total_freeze_fund_amount"`   // 累计冻结自有资金金额
+	TotalPayCreditAmount    string `json:"total_pay_credit_amount"`    // 累计支付信用金额
+	TotalPayFundAmount      string `json:"total_pay_fund_amount"`      // 累计支付自有资金金额
+	RestCreditAmount        string `json:"rest_credit_amount"`         // 剩余冻结信用金额
+	RestFundAmount          string `json:"rest_fund_amount"`           // 剩余冻结自有资金金额
+	CreditAmount            string `json:"credit_amount"`              // 该笔资金操作流水中信用金额
+	FundAmount              string `json:"fund_amount"`                // 该笔资金操作流水中自有资金金额
+}
+
+type OperationDetailQueryRes = kernel.Response[OperationDetailQueryResContent]
+
+/**
+ * 资金授权撤销接口
+ */
+type OperationCancel struct {
+	AuthNo       string `json:"auth_no,omitempty"`        // 支付宝授权资金订单号，与 OutOrderNo 二选一
+	OutOrderNo   string `json:"out_order_no,omitempty"`   // 商户的授权资金订单号，与 AuthNo 二选一
+	OperationId  string `json:"operation_id,omitempty"`   // 支付宝的授权资金操作流水号，与 OutRequestNo 二选一
+	OutRequestNo string `json:"out_request_no,omitempty"` // 商户的授权资金操作流水号，与 OperationId 二选一
+	Remark       string `json:"remark"`                   // 商户对本次撤销操作的附言描述
+}
+
+func (m *OperationCancel) GetAliPayMethod() string {
+	return "alipay.fund.auth.operation.cancel"
+}
+
+type OperationCancelResContent struct {
+	Code         string `json:"code"`           //网关返回码
+	Msg          string `json:"msg"`            //网关返回描述
+	SubCode      string `json:"sub_code"`       //业务返回码
+	SubMsg       string `json:"sub_msg"`        //业务返回码描述
+	AuthNo       string `json:"auth_no"`        // 支付宝资金授权订单号
+	OutOrderNo   string `json:"out_order_no"`   // 商户的授权资金订单号
+	OperationId  string `json:"operation_id"`   // 支付宝资金操作流水号
+	OutRequestNo string `json:"out_request_no"` // 商户资金操作的请求流水号
+	Action       string `json:"action"`         // 本次撤销触发的资金动作，close：关闭冻结明细，无资金解冻 unfreeze：产生了资金解冻
+}
+
+type OperationCancelRes = kernel.Response[OperationCancelResContent]
+
+/**
+ * 资金授权冻结、解冻异步通知参数
+ */
+type Notify struct {
+	NotifyTime              string `json:"notify_time"`                // 通知的发送时间。格式为yyyy-MM-dd HH:mm:ss
+	NotifyType              string `json:"notify_type"`                // 通知的类型，fund_auth_freeze:冻结 fund_auth_unfreeze:解冻
+	NotifyId                string `json:"notify_id"`                  // 通知校验ID
+	Charset                 string `json:"charset"`                    // 编码格式
+	Version                 string `json:"version"`                    // 调用的接口版本
+	SignType                string `json:"sign_type"`                  // 签名算法类型
+	Sign                    string `json:"sign"`                       // 签名
+	AppId                   string `json:"app_id"`                     // 开发者APP_ID
+	AuthAppId               string `json:"auth_app_id"`                // 授权方的appid
+	AuthNo                  string `json:"auth_no"`                    // 支付宝资金授权订单号
+	OutOrderNo              string `json:"out_order_no"`               // 商户的授权资金订单号
+	OperationId             string `json:"operation_id"`               // 支付宝资金操作流水号
+	OutRequestNo            string `json:"out_request_no"`             // 商户资金操作的请求流水号
+	OperationType           string `json:"operation_type"`             // 资金操作类型，FREEZE、UNFREEZE
+	Amount                  string `json:"amount"`                     // 本次操作的金额
+	Status                  string `json:"status"`                     // 资金操作流水的状态
+	GmtCreate               string `json:"gmt_create"`                 // 操作创建时间
+	GmtTrans                string `json:"gmt_trans"`                  // 处理成功时间
+	PayerLogonId            string `json:"payer_logon_id"`             // 付款方支付宝账号登录号
+	PayerUserId             string `json:"payer_user_id"`              // 付款方支付宝用户号
+	PayeeLogonId            string `json:"payee_logon_id"`             // 收款方支付宝账号登录号
+	PayeeUserId             string `json:"payee_user_id"`              // 收款方支付宝用户号
+	TotalFreezeAmount       string `json:"total_freeze_amount"`        // 订单累计的冻结金额
+	TotalUnfreezeAmount     string `json:"total_unfreeze_amount"`      // 订单累计的解冻金额
+	TotalPayAmount          string `json:"total_pay_amount"`           // 订单累计用于支付的金额
+	RestAmount              string `json:"rest_amount"`                // 订单总共剩余的冻结金额
+	CreditAmount            string `json:"credit_amount"`              // 本次操作中信用金额
+	FundAmount              string `json:"fund_amount"`                // 本次操作中自有资金金额
+	TotalFreezeCreditAmount string `json:"total_freeze_credit_amount"` // 累计冻结信用金额
+	TotalFreezeFundAmount   string `json:"total_freeze_fund_amount"`   // 累计冻结自有资金金额
+	RestCreditAmount        string `json:"rest_credit_amount"`         // 剩余冻结信用金额
+	RestFundAmount          string `json:"rest_fund_amount"`           // 剩余冻结自有资金金额
+	PreAuthType             string `json:"pre_auth_type"`              // 预授权类型，CREDIT_AUTH:信用预授权
+	TransCurrency           string `json:"trans_currency"`             // 标价币种
+}
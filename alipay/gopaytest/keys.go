@@ -0,0 +1,114 @@
+package gopaytest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"time"
+)
+
+/**
+ * 测试网关使用的密钥与证书文件
+ */
+type keyFiles struct {
+	merchantPrivateKeyPath string
+	merchantPublicKey      *rsa.PublicKey
+	aliPayPrivateKey       *rsa.PrivateKey
+	aliPayPublicKeyPath    string
+	aliPayCertPath         string
+	aliPayRootCertPath     string
+	merchantCertPath       string
+}
+
+/**
+ * 生成商户与支付宝的测试密钥，证书模式下同时生成根证书、支付宝公钥证书与商户应用公钥证书
+ */
+func generateKeyFiles(dir string, certMode bool) (*keyFiles, error) {
+	merchantKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	aliPayKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	files := &keyFiles{
+		merchantPrivateKeyPath: filepath.Join(dir, "merchant_private_key.pem"),
+		merchantPublicKey:      &merchantKey.PublicKey,
+		aliPayPrivateKey:       aliPayKey,
+		aliPayPublicKeyPath:    filepath.Join(dir, "alipay_public_key.pem"),
+	}
+	merchantKeyBytes, err := x509.MarshalPKCS8PrivateKey(merchantKey)
+	if err != nil {
+		return nil, err
+	}
+	if err = writePem(files.merchantPrivateKeyPath, "PRIVATE KEY", merchantKeyBytes); err != nil {
+		return nil, err
+	}
+	aliPayPublicKeyBytes, err := x509.MarshalPKIXPublicKey(&aliPayKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if err = writePem(files.aliPayPublicKeyPath, "PUBLIC KEY", aliPayPublicKeyBytes); err != nil {
+		return nil, err
+	}
+	if !certMode {
+		return files, nil
+	}
+
+	rootKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	rootTemplate := certTemplate(1, "gopaytest Root CA")
+	rootTemplate.IsCA = true
+	rootTemplate.BasicConstraintsValid = true
+	rootTemplate.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	rootBytes, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	aliPayCertBytes, err := x509.CreateCertificate(rand.Reader, certTemplate(2, "gopaytest AliPay"), rootTemplate, &aliPayKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+	merchantCertBytes, err := x509.CreateCertificate(rand.Reader, certTemplate(3, "gopaytest Merchant"), rootTemplate, &merchantKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, err
+	}
+
+	files.aliPayRootCertPath = filepath.Join(dir, "alipay_root_cert.crt")
+	files.aliPayCertPath = filepath.Join(dir, "alipay_cert_public_key.crt")
+	files.merchantCertPath = filepath.Join(dir, "app_cert_public_key.crt")
+	if err = writePem(files.aliPayRootCertPath, "CERTIFICATE", rootBytes); err != nil {
+		return nil, err
+	}
+	if err = writePem(files.aliPayCertPath, "CERTIFICATE", aliPayCertBytes); err != nil {
+		return nil, err
+	}
+	if err = writePem(files.merchantCertPath, "CERTIFICATE", merchantCertBytes); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func certTemplate(serialNumber int64, commonName string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber:       big.NewInt(serialNumber),
+		Subject:            pkix.Name{CommonName: commonName, Organization: []string{"gopaytest"}},
+		NotBefore:          time.Now().Add(-time.Hour),
+		NotAfter:           time.Now().AddDate(1, 0, 0),
+		SignatureAlgorithm: x509.SHA256WithRSA,
+	}
+}
+
+func writePem(path, blockType string, content []byte) error {
+	return ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: content}), 0600)
+}
@@ -0,0 +1,152 @@
+package gopaytest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const (
+	TradeStatusWaitBuyerPay = "WAIT_BUYER_PAY" // 交易创建，等待买家付款
+	TradeStatusClosed       = "TRADE_CLOSED"   // 未付款交易超时关闭，或支付完成后全额退款
+	TradeStatusSuccess      = "TRADE_SUCCESS"  // 交易支付成功
+	TradeStatusFinished     = "TRADE_FINISHED" // 交易结束，不可退款
+)
+
+var (
+	ErrOrderNotExist     = errors.New("gopaytest: order not exist")
+	ErrTradeStatusError  = errors.New("gopaytest: trade status error")
+	ErrRefundAmountError = errors.New("gopaytest: refund amount error")
+)
+
+/**
+ * 测试网关中的订单
+ */
+type Order struct {
+	TradeNo     string             // 支付宝交易号
+	OutTradeNo  string             // 商户订单号
+	Subject     string             // 订单标题
+	TotalAmount string             // 订单金额
+	TradeStatus string             // 交易状态
+	BuyerId     string             // 买家支付宝用户号
+	NotifyUrl   string             // 异步通知地址
+	GmtCreate   string             // 交易创建时间
+	GmtPayment  string             // 交易付款时间
+	GmtClose    string             // 交易结束时间
+	Refunds     map[string]*Refund // 退款记录，key为退款请求号
+}
+
+/**
+ * 测试网关中的退款记录
+ */
+type Refund struct {
+	OutRequestNo string // 退款请求号
+	RefundAmount string // 退款金额
+	RefundReason string // 退款原因
+	GmtRefund    string // 退款时间
+}
+
+/**
+ * 订单状态流转：WAIT_BUYER_PAY -> TRADE_SUCCESS -> TRADE_FINISHED
+ *            WAIT_BUYER_PAY -> TRADE_CLOSED
+ *            TRADE_SUCCESS  -> TRADE_CLOSED（全额退款）
+ */
+func (m *Order) transit(tradeStatus string) error {
+	allowed := false
+	switch m.TradeStatus {
+	case TradeStatusWaitBuyerPay:
+		allowed = tradeStatus == TradeStatusSuccess || tradeStatus == TradeStatusClosed
+	case TradeStatusSuccess:
+		allowed = tradeStatus == TradeStatusFinished || tradeStatus == TradeStatusClosed
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s -> %s", ErrTradeStatusError, m.TradeStatus, tradeStatus)
+	}
+
+	m.TradeStatus = tradeStatus
+	return nil
+}
+
+/**
+ * 退款，同一退款请求号重复请求时返回已有的退款记录
+ */
+func (m *Order) refund(outRequestNo, refundAmount, refundReason, now string) (*Refund, error) {
+	if refund, ok := m.Refunds[outRequestNo]; ok {
+		return refund, nil
+	}
+	if m.TradeStatus != TradeStatusSuccess {
+		return nil, ErrTradeStatusError
+	}
+
+	refundCent, err := toCent(refundAmount)
+	if err != nil || refundCent <= 0 {
+		return nil, ErrRefundAmountError
+	}
+	totalCent, err := toCent(m.TotalAmount)
+	if err != nil {
+		return nil, err
+	}
+	refundedCent, err := m.refundedCent()
+	if err != nil {
+		return nil, err
+	}
+	if refundedCent+refundCent > totalCent {
+		return nil, ErrRefundAmountError
+	}
+
+	refund := &Refund{
+		OutRequestNo: outRequestNo,
+		RefundAmount: formatCent(refundCent),
+		RefundReason: refundReason,
+		GmtRefund:    now,
+	}
+	m.Refunds[outRequestNo] = refund
+	if refundedCent+refundCent == totalCent {
+		m.TradeStatus = TradeStatusClosed
+		m.GmtClose = now
+	}
+	return refund, nil
+}
+
+/**
+ * 累计退款金额，单位元
+ */
+func (m *Order) RefundFee() string {
+	refundedCent, _ := m.refundedCent()
+	return formatCent(refundedCent)
+}
+
+func (m *Order) refundedCent() (int64, error) {
+	var refundedCent int64
+	for _, refund := range m.Refunds {
+		cent, err := toCent(refund.RefundAmount)
+		if err != nil {
+			return 0, err
+		}
+		refundedCent += cent
+	}
+	return refundedCent, nil
+}
+
+func (m *Order) clone() *Order {
+	order := *m
+	order.Refunds = make(map[string]*Refund, len(m.Refunds))
+	for outRequestNo, refund := range m.Refunds {
+		refundCopy := *refund
+		order.Refunds[outRequestNo] = &refundCopy
+	}
+	return &order
+}
+
+func toCent(amount string) (int64, error) {
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(math.Round(value * 100)), nil
+}
+
+func formatCent(cent int64) string {
+	return fmt.Sprintf("%d.%02d", cent/100, cent%100)
+}
@@ -0,0 +1,542 @@
+package gopaytest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shinmigo/gopay/alipay/kernel"
+)
+
+/**
+ * 测试网关配置
+ */
+type Options struct {
+	AppId     string // 商户支付宝应用APPID，默认 2021000000000000
+	CertMode  bool   // 是否使用公钥证书模式
+	SignType  string // 签名类型，默认 RSA2
+	NotifyUrl string // 异步通知地址，为空时使用请求中的notify_url
+}
+
+/**
+ * 本地支付宝测试网关
+ */
+type Server struct {
+	URL string // 网关地址，可作为 kernel.Config.GatewayHost
+
+	options      Options
+	httpServer   *httptest.Server
+	dir          string
+	keys         *keyFiles
+	aliPayCertSN string
+	mutex        sync.Mutex
+	orders       map[string]*Order
+	tradeNoIndex map[string]string
+	sequence     int64
+}
+
+type bizError struct {
+	code    string
+	msg     string
+	subCode string
+	subMsg  string
+}
+
+/**
+ * 启动本地支付宝测试网关
+ */
+func NewServer(options *Options) (*Server, error) {
+	server := &Server{
+		orders:       make(map[string]*Order),
+		tradeNoIndex: make(map[string]string),
+	}
+	if options != nil {
+		server.options = *options
+	}
+	if len(server.options.AppId) == 0 {
+		server.options.AppId = "2021000000000000"
+	}
+	if len(server.options.SignType) == 0 {
+		server.options.SignType = kernel.AliPaySignType2
+	}
+
+	dir, err := ioutil.TempDir("", "gopaytest")
+	if err != nil {
+		return nil, err
+	}
+	server.dir = dir
+	server.keys, err = generateKeyFiles(dir, server.options.CertMode)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	if server.options.CertMode {
+		server.aliPayCertSN, _, err = kernel.GetAliPayCertSN(server.keys.aliPayCertPath)
+		if err != nil {
+			_ = os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+
+	server.httpServer = httptest.NewServer(http.HandlerFunc(server.serveGateway))
+	server.URL = server.httpServer.URL + "/gateway.do"
+	return server, nil
+}
+
+/**
+ * 关闭测试网关并删除临时密钥文件
+ */
+func (m *Server) Close() {
+	m.httpServer.Close()
+	_ = os.RemoveAll(m.dir)
+}
+
+/**
+ * 指向测试网关的客户端配置
+ */
+func (m *Server) Config() *kernel.Config {
+	return &kernel.Config{
+		AppId:                  m.options.AppId,
+		AliPayPublicKeyPath:    m.keys.aliPayPublicKeyPath,
+		MerchantPrivateKeyPath: m.keys.merchantPrivateKeyPath,
+		AliPayCertPath:         m.keys.aliPayCertPath,
+		AliPayRootCertPath:     m.keys.aliPayRootCertPath,
+		MerchantCertPath:       m.keys.merchantCertPath,
+		NotifyUrl:              m.options.NotifyUrl,
+		SignType:               m.options.SignType,
+		GatewayHost:            m.URL,
+	}
+}
+
+/**
+ * 初始化指向测试网关的支付宝客户端
+ */
+func (m *Server) Client() (*kernel.AliPayClient, error) {
+	return kernel.NewAliPayClient(m.Config())
+}
+
+/**
+ * 直接创建一笔待支付订单
+ */
+func (m *Server) CreateOrder(outTradeNo, subject, totalAmount string) *Order {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.createOrder(outTradeNo, subject, totalAmount, m.options.NotifyUrl).clone()
+}
+
+/**
+ * 查询订单当前状态
+ */
+func (m *Server) Order(outTradeNo string) (*Order, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	order, ok := m.orders[outTradeNo]
+	if !ok {
+		return nil, false
+	}
+	return order.clone(), true
+}
+
+/**
+ * 模拟买家付款，并发送 TRADE_SUCCESS 异步通知
+ */
+func (m *Server) Pay(outTradeNo string) error {
+	return m.transit(outTradeNo, TradeStatusSuccess)
+}
+
+/**
+ * 模拟交易结束，并发送 TRADE_FINISHED 异步通知
+ */
+func (m *Server) Finish(outTradeNo string) error {
+	return m.transit(outTradeNo, TradeStatusFinished)
+}
+
+/**
+ * 模拟交易超时关闭，并发送 TRADE_CLOSED 异步通知
+ */
+func (m *Server) Expire(outTradeNo string) error {
+	return m.transit(outTradeNo, TradeStatusClosed)
+}
+
+/**
+ * 按订单当前状态重新发送异步通知
+ */
+func (m *Server) Notify(outTradeNo string) error {
+	m.mutex.Lock()
+	order, ok := m.orders[outTradeNo]
+	if !ok {
+		m.mutex.Unlock()
+		return ErrOrderNotExist
+	}
+	order = order.clone()
+	m.mutex.Unlock()
+
+	return m.sendNotify(order)
+}
+
+/**
+ * 生成签名后的异步通知参数
+ */
+func (m *Server) NotifyValues(order *Order) (url.Values, error) {
+	notifyData := url.Values{}
+	notifyData.Set("notify_time", m.now())
+	notifyData.Set("notify_type", "trade_status_sync")
+	notifyData.Set("notify_id", strconv.FormatInt(time.Now().UnixNano(), 10))
+	notifyData.Set("app_id", m.options.AppId)
+	notifyData.Set("auth_app_id", m.options.AppId)
+	notifyData.Set("charset", kernel.AliPayCharset)
+	notifyData.Set("version", kernel.AliPayVersion)
+	notifyData.Set("sign_type", m.options.SignType)
+	notifyData.Set("trade_no", order.TradeNo)
+	notifyData.Set("out_trade_no", order.OutTradeNo)
+	notifyData.Set("trade_status", order.TradeStatus)
+	notifyData.Set("total_amount", order.TotalAmount)
+	notifyData.Set("subject", order.Subject)
+	notifyData.Set("gmt_create", order.GmtCreate)
+	if len(order.BuyerId) > 0 {
+		notifyData.Set("buyer_id", order.BuyerId)
+		notifyData.Set("receipt_amount", order.TotalAmount)
+		notifyData.Set("buyer_pay_amount", order.TotalAmount)
+		notifyData.Set("invoice_amount", order.TotalAmount)
+		notifyData.Set("gmt_payment", order.GmtPayment)
+	}
+	if len(order.GmtClose) > 0 {
+		notifyData.Set("gmt_close", order.GmtClose)
+	}
+	if len(order.Refunds) > 0 {
+		notifyData.Set("refund_fee", order.RefundFee())
+	}
+
+	sign, err := m.sign(notifyString(notifyData))
+	if err != nil {
+		return nil, err
+	}
+	notifyData.Set(kernel.AliPaySignNodeName, sign)
+	return notifyData, nil
+}
+
+func (m *Server) transit(outTradeNo, tradeStatus string) error {
+	m.mutex.Lock()
+	order, ok := m.orders[outTradeNo]
+	if !ok {
+		m.mutex.Unlock()
+		return ErrOrderNotExist
+	}
+	if err := order.transit(tradeStatus); err != nil {
+		m.mutex.Unlock()
+		return err
+	}
+	switch tradeStatus {
+	case TradeStatusSuccess:
+		order.BuyerId = "2088000000000001"
+		order.GmtPayment = m.now()
+	case TradeStatusFinished, TradeStatusClosed:
+		order.GmtClose = m.now()
+	}
+	order = order.clone()
+	m.mutex.Unlock()
+
+	return m.sendNotify(order)
+}
+
+func (m *Server) sendNotify(order *Order) error {
+	if len(order.NotifyUrl) == 0 {
+		return nil
+	}
+	notifyData, err := m.NotifyValues(order)
+	if err != nil {
+		return err
+	}
+	response, err := http.PostForm(order.NotifyUrl, notifyData)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	responseByte, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(responseByte)) != "success" {
+		return fmt.Errorf("gopaytest: notify not acknowledged: %s", responseByte)
+	}
+	return nil
+}
+
+func (m *Server) createOrder(outTradeNo, subject, totalAmount, notifyUrl string) *Order {
+	if order, ok := m.orders[outTradeNo]; ok {
+		return order
+	}
+
+	m.sequence++
+	order := &Order{
+		TradeNo:     fmt.Sprintf("%s%010d", time.Now().Format("20060102"), m.sequence),
+		OutTradeNo:  outTradeNo,
+		Subject:     subject,
+		TotalAmount: totalAmount,
+		TradeStatus: TradeStatusWaitBuyerPay,
+		NotifyUrl:   notifyUrl,
+		GmtCreate:   m.now(),
+		Refunds:     make(map[string]*Refund),
+	}
+	m.orders[outTradeNo] = order
+	m.tradeNoIndex[order.TradeNo] = outTradeNo
+	return order
+}
+
+func (m *Server) findOrder(outTradeNo, tradeNo string) (*Order, *bizError) {
+	if len(outTradeNo) == 0 {
+		outTradeNo = m.tradeNoIndex[tradeNo]
+	}
+	order, ok := m.orders[outTradeNo]
+	if !ok {
+		return nil, &bizError{"40004", "Business Failed", "ACQ.TRADE_NOT_EXIST", "交易不存在"}
+	}
+	return order, nil
+}
+
+/**
+ * 网关入口
+ */
+func (m *Server) serveGateway(writer http.ResponseWriter, request *http.Request) {
+	if err := request.ParseForm(); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := request.Form
+	method := params.Get("method")
+	nodeName := strings.ReplaceAll(method, ".", "_") + "_response"
+	if err := m.verifyRequest(params); err != nil {
+		m.writeResponse(writer, kernel.AliPayErrorResponse, map[string]interface{}{
+			"code":     "40002",
+			"msg":      "Invalid Arguments",
+			"sub_code": "isv.invalid-signature",
+			"sub_msg":  err.Error(),
+		})
+		return
+	}
+
+	bizContent := make(map[string]interface{})
+	if content := params.Get("biz_content"); len(content) > 0 {
+		if err := json.Unmarshal([]byte(content), &bizContent); err != nil {
+			m.writeResponse(writer, kernel.AliPayErrorResponse, map[string]interface{}{
+				"code":     "40002",
+				"msg":      "Invalid Arguments",
+				"sub_code": "isv.invalid-biz-content",
+				"sub_msg":  err.Error(),
+			})
+			return
+		}
+	}
+	biz := func(key string) string {
+		value, _ := bizContent[key].(string)
+		return value
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	switch method {
+	case "alipay.trade.page.pay", "alipay.trade.wap.pay":
+		notifyUrl := m.options.NotifyUrl
+		if len(notifyUrl) == 0 {
+			notifyUrl = params.Get("notify_url")
+		}
+		order := m.createOrder(biz("out_trade_no"), biz("subject"), biz("total_amount"), notifyUrl)
+		writer.Header().Set("Content-Type", "text/html;charset=utf-8")
+		_, _ = fmt.Fprintf(writer, "<html><body>gopaytest cashier %s %s %s</body></html>", order.OutTradeNo, order.TradeNo, order.TotalAmount)
+	case "alipay.trade.query":
+		order, bizErr := m.findOrder(biz("out_trade_no"), biz("trade_no"))
+		if bizErr != nil {
+			m.writeBizError(writer, nodeName, bizErr)
+			return
+		}
+		m.writeSuccess(writer, nodeName, map[string]interface{}{
+			"trade_no":       order.TradeNo,
+			"out_trade_no":   order.OutTradeNo,
+			"trade_status":   order.TradeStatus,
+			"total_amount":   order.TotalAmount,
+			"buyer_user_id":  order.BuyerId,
+			"send_pay_date":  order.GmtPayment,
+			"subject":        order.Subject,
+			"receipt_amount": order.TotalAmount,
+		})
+	case "alipay.trade.close":
+		order, bizErr := m.findOrder(biz("out_trade_no"), biz("trade_no"))
+		if bizErr != nil {
+			m.writeBizError(writer, nodeName, bizErr)
+			return
+		}
+		if order.TradeStatus != TradeStatusWaitBuyerPay {
+			m.writeBizError(writer, nodeName, &bizError{"40004", "Business Failed", "ACQ.TRADE_STATUS_ERROR", "交易状态不合法"})
+			return
+		}
+		_ = order.transit(TradeStatusClosed)
+		order.GmtClose = m.now()
+		m.writeSuccess(writer, nodeName, map[string]interface{}{
+			"trade_no":     order.TradeNo,
+			"out_trade_no": order.OutTradeNo,
+		})
+	case "alipay.trade.refund":
+		order, bizErr := m.findOrder(biz("out_trade_no"), biz("trade_no"))
+		if bizErr != nil {
+			m.writeBizError(writer, nodeName, bizErr)
+			return
+		}
+		outRequestNo := biz("out_request_no")
+		if len(outRequestNo) == 0 {
+			outRequestNo = order.OutTradeNo
+		}
+		_, err := order.refund(outRequestNo, biz("refund_amount"), biz("refund_reason"), m.now())
+		if err == ErrTradeStatusError {
+			m.writeBizError(writer, nodeName, &bizError{"40004", "Business Failed", "ACQ.TRADE_STATUS_ERROR", "交易状态不合法"})
+			return
+		}
+		if err != nil {
+			m.writeBizError(writer, nodeName, &bizError{"40004", "Business Failed", "ACQ.REFUND_AMT_NOT_EQUAL_TOTAL", "退款金额超限"})
+			return
+		}
+		m.writeSuccess(writer, nodeName, map[string]interface{}{
+			"trade_no":       order.TradeNo,
+			"out_trade_no":   order.OutTradeNo,
+			"buyer_user_id":  order.BuyerId,
+			"fund_change":    "Y",
+			"refund_fee":     order.RefundFee(),
+			"gmt_refund_pay": m.now(),
+		})
+	case "alipay.trade.fastpay.refund.query":
+		order, bizErr := m.findOrder(biz("out_trade_no"), biz("trade_no"))
+		if bizErr != nil {
+			m.writeBizError(writer, nodeName, bizErr)
+			return
+		}
+		content := map[string]interface{}{
+			"trade_no":     order.TradeNo,
+			"out_trade_no": order.OutTradeNo,
+		}
+		if refund, ok := order.Refunds[biz("out_request_no")]; ok {
+			content["out_request_no"] = refund.OutRequestNo
+			content["refund_reason"] = refund.RefundReason
+			content["total_amount"] = order.TotalAmount
+			content["refund_amount"] = refund.RefundAmount
+		}
+		m.writeSuccess(writer, nodeName, content)
+	default:
+		m.writeResponse(writer, kernel.AliPayErrorResponse, map[string]interface{}{
+			"code":     "40002",
+			"msg":      "Invalid Arguments",
+			"sub_code": "isv.invalid-method",
+			"sub_msg":  "不存在的方法名",
+		})
+	}
+}
+
+/**
+ * 验证商户请求签名
+ */
+func (m *Server) verifyRequest(params url.Values) error {
+	if params.Get("app_id") != m.options.AppId {
+		return errors.New("invalid app_id")
+	}
+	signBytes, err := base64.StdEncoding.DecodeString(params.Get(kernel.AliPaySignNodeName))
+	if err != nil {
+		return err
+	}
+
+	paramList := make([]string, 0, len(params))
+	for paramKey := range params {
+		paramValue := strings.TrimSpace(params.Get(paramKey))
+		if paramKey == kernel.AliPaySignNodeName || len(paramValue) == 0 {
+			continue
+		}
+		paramList = append(paramList, paramKey+"="+paramValue)
+	}
+	sort.Strings(paramList)
+
+	return kernel.Verify([]byte(strings.Join(paramList, "&")), signBytes, m.keys.merchantPublicKey, params.Get("sign_type"))
+}
+
+func (m *Server) writeSuccess(writer http.ResponseWriter, nodeName string, content map[string]interface{}) {
+	content["code"] = kernel.CodeSuccess
+	content["msg"] = "Success"
+	m.writeResponse(writer, nodeName, content)
+}
+
+func (m *Server) writeBizError(writer http.ResponseWriter, nodeName string, bizErr *bizError) {
+	m.writeResponse(writer, nodeName, map[string]interface{}{
+		"code":     bizErr.code,
+		"msg":      bizErr.msg,
+		"sub_code": bizErr.subCode,
+		"sub_msg":  bizErr.subMsg,
+	})
+}
+
+/**
+ * 输出签名后的响应，格式与支付宝网关一致
+ */
+func (m *Server) writeResponse(writer http.ResponseWriter, nodeName string, content map[string]interface{}) {
+	contentBytes, err := json.Marshal(content)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sign, err := m.sign(string(contentBytes))
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := `{"` + nodeName + `":` + string(contentBytes)
+	if len(m.aliPayCertSN) > 0 {
+		response += `,"` + kernel.AliPayCertSNNodeName + `":"` + m.aliPayCertSN + `"`
+	}
+	response += `,"` + kernel.AliPaySignNodeName + `":"` + sign + `"}`
+
+	writer.Header().Set("Content-Type", "application/json;charset=utf-8")
+	_, _ = writer.Write([]byte(response))
+}
+
+func (m *Server) sign(content string) (string, error) {
+	signBytes, err := kernel.Sign([]byte(content), m.keys.aliPayPrivateKey, m.options.SignType)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signBytes), nil
+}
+
+func (m *Server) now() string {
+	return time.Now().Format(kernel.AliPayTimeFormat)
+}
+
+/**
+ * 异步通知待签名字符串，与 AliPayClient.NotifyVerify 一致
+ */
+func notifyString(notifyData url.Values) string {
+	paramList := make([]string, 0, len(notifyData))
+	for paramKey := range notifyData {
+		if paramKey == kernel.AliPaySignNodeName || paramKey == kernel.AliPaySignTypeNodeName || paramKey == kernel.AliPayCertSNNodeName {
+			continue
+		}
+		paramValue := strings.TrimSpace(notifyData.Get(paramKey))
+		if len(paramValue) == 0 {
+			continue
+		}
+		paramList = append(paramList, paramKey+"="+paramValue)
+	}
+	sort.Strings(paramList)
+
+	return strings.Join(paramList, "&")
+}
@@ -0,0 +1,200 @@
+package gopaytest_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/shinmigo/gopay"
+	"github.com/shinmigo/gopay/alipay/gopaytest"
+	"github.com/shinmigo/gopay/alipay/kernel"
+	"github.com/shinmigo/gopay/alipay/payment"
+)
+
+/**
+ * 分别以公钥模式与公钥证书模式启动测试网关
+ */
+func runModes(t *testing.T, options gopaytest.Options, test func(t *testing.T, server *gopaytest.Server, pay *payment.Payment)) {
+	for _, certMode := range []bool{false, true} {
+		name := "PublicKey"
+		if certMode {
+			name = "Cert"
+		}
+		t.Run(name, func(t *testing.T) {
+			options.CertMode = certMode
+			server, err := gopaytest.NewServer(&options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+			client, err := server.Client()
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, server, &payment.Payment{Client: client})
+		})
+	}
+}
+
+func TestTradeQueryAndClose(t *testing.T) {
+	runModes(t, gopaytest.Options{}, func(t *testing.T, server *gopaytest.Server, pay *payment.Payment) {
+		order := server.CreateOrder("T1001", "测试", "1.00")
+
+		res, err := pay.TradeQuery(&payment.TradeQuery{OutTradeNo: "T1001"})
+		if err != nil {
+			t.Fatalf("TradeQuery: %v", err)
+		}
+		if res.Body.TradeNo != order.TradeNo || res.Body.TradeStatus != gopaytest.TradeStatusWaitBuyerPay || res.Body.TotalAmount != "1.00" || res.Body.Total != gopay.Fen(100) {
+			t.Errorf("TradeQuery = %+v", res.Body)
+		}
+		if len(res.Sign) == 0 {
+			t.Error("TradeQuery response has no sign")
+		}
+
+		closeRes, err := pay.TradeClose(&payment.TradeClose{TradeNo: order.TradeNo})
+		if err != nil {
+			t.Fatalf("TradeClose: %v", err)
+		}
+		if closeRes.Body.OutTradeNo != "T1001" {
+			t.Errorf("TradeClose out_trade_no = %q", closeRes.Body.OutTradeNo)
+		}
+		if order, _ = server.Order("T1001"); order.TradeStatus != gopaytest.TradeStatusClosed {
+			t.Errorf("trade status after close = %s", order.TradeStatus)
+		}
+	})
+}
+
+func TestTradeRefundAndRefundQuery(t *testing.T) {
+	runModes(t, gopaytest.Options{}, func(t *testing.T, server *gopaytest.Server, pay *payment.Payment) {
+		server.CreateOrder("T2001", "测试", "10.00")
+		if err := server.Pay("T2001"); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := pay.TradeRefund(&payment.TradeRefund{OutTradeNo: "T2001", OutRequestNo: "R1", Amount: gopay.Fen(350)})
+		if err != nil {
+			t.Fatalf("TradeRefund: %v", err)
+		}
+		if res.Body.RefundFee != "3.50" || res.Body.Refunded != gopay.Fen(350) || res.Body.FundChange != "Y" {
+			t.Errorf("TradeRefund = %+v", res.Body)
+		}
+
+		queryRes, err := pay.RefundQuery(&payment.RefundQuery{OutTradeNo: "T2001", OutRequestNo: "R1"})
+		if err != nil {
+			t.Fatalf("RefundQuery: %v", err)
+		}
+		if queryRes.Body.RefundAmount != "3.50" || queryRes.Body.Refund != gopay.Fen(350) || queryRes.Body.Total != gopay.Fen(1000) {
+			t.Errorf("RefundQuery = %+v", queryRes.Body)
+		}
+
+		_, err = pay.TradeRefund(&payment.TradeRefund{OutTradeNo: "T2001", OutRequestNo: "R2", RefundAmount: "7.00"})
+		if err == nil {
+			t.Error("refund over the order amount succeeded")
+		}
+	})
+}
+
+func TestOrderNotExist(t *testing.T) {
+	runModes(t, gopaytest.Options{}, func(t *testing.T, server *gopaytest.Server, pay *payment.Payment) {
+		_, err := pay.TradeQuery(&payment.TradeQuery{OutTradeNo: "NOT_EXIST"})
+		if !errors.Is(err, gopay.ErrOrderNotExist) {
+			t.Errorf("TradeQuery: got %v, want %v", err, gopay.ErrOrderNotExist)
+		}
+		_, err = pay.TradeClose(&payment.TradeClose{OutTradeNo: "NOT_EXIST"})
+		if !errors.Is(err, gopay.ErrOrderNotExist) {
+			t.Errorf("TradeClose: got %v, want %v", err, gopay.ErrOrderNotExist)
+		}
+	})
+}
+
+func TestPageForm(t *testing.T) {
+	runModes(t, gopaytest.Options{}, func(t *testing.T, server *gopaytest.Server, pay *payment.Payment) {
+		page := &payment.Page{
+			Trade:     payment.Trade{Subject: "测试", OutTradeNo: "T3001", Amount: gopay.Fen(100)},
+			ReturnUrl: "https://example.com/return",
+		}
+		form, err := pay.Page(page)
+		if err != nil {
+			t.Fatalf("Page: %v", err)
+		}
+		if !strings.Contains(form, `action="`+server.URL+`?charset=`+kernel.AliPayCharset+`"`) {
+			t.Errorf("form does not target the gateway: %s", form)
+		}
+
+		page.Output = payment.OutputParams
+		params, err := pay.Page(page)
+		if err != nil {
+			t.Fatalf("Page: %v", err)
+		}
+		formData, err := url.ParseQuery(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if formData.Get("return_url") != page.ReturnUrl {
+			t.Errorf("return_url = %q", formData.Get("return_url"))
+		}
+		response, err := http.PostForm(server.URL, formData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		_ = response.Body.Close()
+		if !strings.Contains(string(body), "gopaytest cashier T3001") {
+			t.Errorf("cashier page = %s", body)
+		}
+		if order, ok := server.Order("T3001"); !ok || order.TotalAmount != "1.00" {
+			t.Errorf("order = %+v, %v", order, ok)
+		}
+	})
+}
+
+func TestNotifyVerify(t *testing.T) {
+	var pay *payment.Payment
+	notifies := make(chan *payment.Notify, 4)
+	notifyServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if err := request.ParseForm(); err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		notify, err := pay.NotifyVerify(request.PostForm)
+		if err != nil {
+			_, _ = writer.Write([]byte(payment.NotifyFailure))
+			return
+		}
+		notifies <- notify
+		_, _ = writer.Write([]byte(payment.NotifySuccess))
+	}))
+	defer notifyServer.Close()
+
+	runModes(t, gopaytest.Options{NotifyUrl: notifyServer.URL}, func(t *testing.T, server *gopaytest.Server, client *payment.Payment) {
+		pay = client
+		order := server.CreateOrder("T4001", "测试", "0.01")
+		if err := server.Pay("T4001"); err != nil {
+			t.Fatalf("Pay: %v", err)
+		}
+		notify := <-notifies
+		if notify.OutTradeNo != "T4001" || notify.TradeNo != order.TradeNo || notify.TradeStatus != gopaytest.TradeStatusSuccess || notify.Total != gopay.Fen(1) {
+			t.Errorf("notify = %+v", notify)
+		}
+
+		if err := server.Finish("T4001"); err != nil {
+			t.Fatalf("Finish: %v", err)
+		}
+		if notify = <-notifies; notify.TradeStatus != gopaytest.TradeStatusFinished {
+			t.Errorf("trade_status = %s", notify.TradeStatus)
+		}
+
+		notifyData, err := server.NotifyValues(order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		notifyData.Set("total_amount", "100.00")
+		if _, err = pay.NotifyVerify(notifyData); err == nil {
+			t.Error("tampered notify passed verification")
+		}
+	})
+}
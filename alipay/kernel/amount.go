@@ -0,0 +1,66 @@
+package kernel
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/shinmigo/gopay"
+)
+
+var amountType = reflect.TypeOf(gopay.Amount(0))
+
+/**
+ * 按 amount 标签将响应节点中的元金额解析到 gopay.Amount 字段，如
+ *   TotalAmount string       `json:"total_amount"`
+ *   Total       gopay.Amount `json:"-" amount:"total_amount"`
+ */
+func decodeAmounts(content json.RawMessage, result interface{}) error {
+	value := reflect.ValueOf(result)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct || !hasAmountField(value.Elem().Type()) {
+		return nil
+	}
+	contentMap := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &contentMap); err != nil {
+		return err
+	}
+	return decodeAmountStruct(contentMap, value.Elem())
+}
+
+func decodeAmountStruct(contentMap map[string]json.RawMessage, value reflect.Value) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		fieldValue := value.Field(i)
+		if field.Anonymous && fieldValue.Kind() == reflect.Struct {
+			if err := decodeAmountStruct(contentMap, fieldValue); err != nil {
+				return err
+			}
+			continue
+		}
+		name, ok := field.Tag.Lookup("amount")
+		if !ok || field.PkgPath != "" || field.Type != amountType {
+			continue
+		}
+		amountValue, ok := contentMap[name]
+		if !ok {
+			continue
+		}
+		if err := fieldValue.Addr().Interface().(*gopay.Amount).UnmarshalJSON(amountValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasAmountField(valueType reflect.Type) bool {
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && hasAmountField(field.Type) {
+			return true
+		}
+		if _, ok := field.Tag.Lookup("amount"); ok && field.Type == amountType {
+			return true
+		}
+	}
+	return false
+}
@@ -1,24 +1,51 @@
 package kernel
 
 import (
+	"context"
 	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"math"
 	"net/http"
 	"net/url"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/shinmigo/gopay"
 )
 
 type Palmer interface {
 	GetAliPayMethod() string
 }
 
+/**
+ * 业务参数需放在公共请求参数中的接口，如 alipay.system.oauth.token
+ */
+type TextParamsPalmer interface {
+	Palmer
+	GetTextParams() map[string]string
+}
+
+/**
+ * 由客户端生成的公共请求参数，不能通过 GetTextParams 设置
+ */
+var reservedTextParams = map[string]bool{
+	"app_id":              true,
+	"method":              true,
+	"format":              true,
+	"charset":             true,
+	"sign_type":           true,
+	"sign":                true,
+	"timestamp":           true,
+	"version":             true,
+	"biz_content":         true,
+	"app_cert_sn":         true,
+	"alipay_root_cert_sn": true,
+}
+
 type AliPayClient struct {
 	gatewayHost         string                    //支付宝网关地址
 	appId               string                    //商户支付宝应用APPID
@@ -32,6 +59,10 @@ type AliPayClient struct {
 	localTimeZone       string                    //时区
 	isProd              bool                      //是否为生产环境
 	signType            string                    //签名类型
+	retryPolicy         *gopay.RetryPolicy        //幂等请求重试策略
+	middlewares         []gopay.Middleware        //网关调用中间件
+	ctx                 context.Context           //请求上下文
+	httpClient          *http.Client              //HTTP客户端
 }
 
 /**
@@ -52,17 +83,34 @@ func (m *ErrorRes) Error() string {
  * 初始化支付宝客户端
  */
 func NewAliPayClient(config *Config) (*AliPayClient, error) {
-	privateKey, err := ParsePrivateKey(config.MerchantPrivateKeyPath)
-	if err != nil {
-		return nil, err
+	if config == nil {
+		return nil, errors.New(InitializeDataErr)
+	}
+	var privateKey *rsa.PrivateKey
+	var err error
+	if len(config.MerchantPrivateKey) > 0 {
+		privateKey, err = ParsePrivateKeyContent([]byte(config.MerchantPrivateKey))
+	} else {
+		privateKey, err = ParsePrivateKey(config.MerchantPrivateKeyPath)
 	}
-	publicKey, err := ParsePublicKey(config.AliPayPublicKeyPath)
 	if err != nil {
 		return nil, err
 	}
 
+	//公钥证书模式下支付宝公钥从支付宝公钥证书中获取
 	aliPayPublicKeyList := make(map[string]*rsa.PublicKey, 8)
-	aliPayPublicKeyList[AliPayPublicKeySN] = publicKey
+	if len(config.AliPayPublicKey) > 0 || len(config.AliPayPublicKeyPath) > 0 || len(config.MerchantCertPath) == 0 {
+		var publicKey *rsa.PublicKey
+		if len(config.AliPayPublicKey) > 0 {
+			publicKey, err = ParsePublicKeyContent([]byte(config.AliPayPublicKey))
+		} else {
+			publicKey, err = ParsePublicKey(config.AliPayPublicKeyPath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		aliPayPublicKeyList[AliPayPublicKeySN] = publicKey
+	}
 	client := AliPayClient{
 		gatewayHost:         AliPayBoxURL,
 		appId:               config.AppId,
@@ -72,7 +120,9 @@ func NewAliPayClient(config *Config) (*AliPayClient, error) {
 		notifyUrl:           config.NotifyUrl,
 		encryptKey:          config.EncryptKey,
 		localTimeZone:       "Asia/Shanghai",
+		isProd:              config.IsProd,
 		signType:            AliPaySignType,
+		httpClient:          &http.Client{Timeout: config.Timeout},
 	}
 	if len(config.SignType) > 0 {
 		client.signType = config.SignType
@@ -80,6 +130,9 @@ func NewAliPayClient(config *Config) (*AliPayClient, error) {
 	if config.IsProd {
 		client.gatewayHost = AliPayProdURL
 	}
+	if len(config.GatewayHost) > 0 {
+		client.gatewayHost = config.GatewayHost
+	}
 	if len(config.LocalTimeZone) > 0 {
 		client.localTimeZone = config.LocalTimeZone
 	}
@@ -115,6 +168,27 @@ func (m *AliPayClient) GetGatewayHost() string {
 	return m.gatewayHost
 }
 
+/**
+ * 设置支付宝网关地址，如本地测试网关或代理
+ */
+func (m *AliPayClient) SetGatewayHost(gatewayHost string) {
+	m.gatewayHost = gatewayHost
+}
+
+/**
+ * 获取商户支付宝应用APPID
+ */
+func (m *AliPayClient) GetAppId() string {
+	return m.appId
+}
+
+/**
+ * 是否为生产环境
+ */
+func (m *AliPayClient) IsProd() bool {
+	return m.isProd
+}
+
 /**
  * 组装支付宝请求参数
  */
@@ -149,7 +223,22 @@ func (m *AliPayClient) UrlParams(param Palmer) (url.Values, error) {
 	urlMap.Add("timestamp", timestampStr)
 	urlMap.Add("version", AliPayVersion)
 	urlMap.Add("notify_url", notifyUrl)
-	urlMap.Add("biz_content", string(bizContentBytes))
+	if bizContent := string(bizContentBytes); bizContent != "{}" {
+		urlMap.Add("biz_content", bizContent)
+	}
+	if textParam, ok := param.(TextParamsPalmer); ok {
+		for paramKey, paramValue := range textParam.GetTextParams() {
+			if reservedTextParams[paramKey] {
+				return nil, fmt.Errorf("%s 为公共请求参数，不能通过 GetTextParams 设置", paramKey)
+			}
+			//notify_url 可覆盖客户端的默认异步通知地址
+			if paramKey == "notify_url" {
+				urlMap.Set(paramKey, paramValue)
+				continue
+			}
+			urlMap.Add(paramKey, paramValue)
+		}
+	}
 	if len(m.merchantCertSN) > 0 {
 		urlMap.Add("app_cert_sn", m.merchantCertSN)
 		urlMap.Add("alipay_root_cert_sn", m.aliPayRootCertSN)
@@ -171,78 +260,145 @@ func (m *AliPayClient) SendRequest(method string, param Palmer, result interface
 		return errors.New(InitializeDataErr)
 	}
 
+	//请求参数只生成一次，重试时使用相同的时间戳与签名
 	urlMap, err := m.UrlParams(param)
 	if err != nil {
 		return err
 	}
-	request, err := http.NewRequest(method, m.gatewayHost, strings.NewReader(urlMap.Encode()))
+	var retryPolicy *gopay.RetryPolicy
+	if idempotentParam, ok := param.(Idempotent); ok && idempotentParam.IsIdempotent() {
+		retryPolicy = m.retryPolicy
+	}
+
+	call := &gopay.Call{
+		Context:  m.context(),
+		Provider: gopay.ProviderAliPay,
+		Api:      param.GetAliPayMethod(),
+		Method:   method,
+		Params:   urlMap,
+	}
+	err = gopay.Chain(m.middlewares, func(call *gopay.Call) error {
+		startTime := time.Now()
+		defer func() {
+			call.Latency = time.Since(startTime)
+		}()
+		return retryPolicy.Do(call.Context, isRetryable, func() error {
+			if ctxErr := call.Context.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			call.Attempts++
+			body, requestErr := m.doRequest(call.Context, method, urlMap)
+			if requestErr != nil {
+				return requestErr
+			}
+			call.Response = body
+			return m.verifyResponse(param, body)
+		})
+	})(call)
 	if err != nil {
 		return err
 	}
-	request.Header.Set("Content-Type", ContentType)
-	client := &http.Client{}
-	response, err := client.Do(request)
+	err = json.Unmarshal(call.Response, result)
 	if err != nil {
 		return err
 	}
+
+	return err
+}
+
+/**
+ * 添加网关调用中间件，按添加顺序由外到内执行
+ */
+func (m *AliPayClient) Use(middlewares ...gopay.Middleware) {
+	m.middlewares = append(m.middlewares, middlewares...)
+}
+
+/**
+ * 返回使用指定上下文的客户端副本，用于超时控制与链路追踪
+ */
+func (m *AliPayClient) WithContext(ctx context.Context) *AliPayClient {
+	client := *m
+	client.ctx = ctx
+	return &client
+}
+
+func (m *AliPayClient) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+/**
+ * 设置幂等请求的重试策略，为空时不重试
+ */
+func (m *AliPayClient) SetRetryPolicy(retryPolicy *gopay.RetryPolicy) {
+	m.retryPolicy = retryPolicy
+}
+
+/**
+ * 发送请求到支付宝网关
+ */
+func (m *AliPayClient) doRequest(ctx context.Context, method string, urlMap url.Values) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, method, m.gatewayHost, strings.NewReader(urlMap.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", ContentType)
+	response, err := m.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
 	defer func() {
 		_ = response.Body.Close()
 	}()
 	responseByte, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	responseString := string(responseByte)
-
-	//以下是对支付宝返回json结果字符串验证签名
-	//首先判断有没有错误
-	var sign string
-	var certSN string
-	var content string
-	methodNodeName := strings.ReplaceAll(param.GetAliPayMethod(), ".", "_") + "_response"
-	methodNodeNameIndex := strings.LastIndex(responseString, methodNodeName)
-	methodErrorIndex := strings.LastIndex(responseString, AliPayErrorResponse)
-	if methodNodeNameIndex > 0 {
-		content, certSN, sign = m.parseJSONSource(responseString, methodNodeName, methodNodeNameIndex)
-		if sign == "" {
-			errorRes := &ErrorRes{}
-			if err = json.Unmarshal([]byte(content), errorRes); err != nil {
-				return err
-			}
-			if errorRes.Code != CodeSuccess {
-				if errorRes != nil {
-					return errorRes
-				}
-				return SignNotFound
-			}
-		}
-	} else if methodErrorIndex > 0 {
-		content, certSN, sign = m.parseJSONSource(responseString, AliPayErrorResponse, methodErrorIndex)
-		if sign == "" {
-			errorRes := &ErrorRes{}
-			if err = json.Unmarshal([]byte(content), errorRes); err != nil {
-				return err
-			}
-			return errorRes
-		}
-	} else {
+	if response.StatusCode >= http.StatusInternalServerError {
+		return nil, &StatusError{StatusCode: response.StatusCode, Body: responseByte}
+	}
+
+	return responseByte, nil
+}
+
+/**
+ * 验证支付宝响应签名，业务失败时返回错误
+ */
+func (m *AliPayClient) verifyResponse(param Palmer, responseByte []byte) error {
+	methodNodeName := strings.ReplaceAll(param.GetAliPayMethod(), ".", "_") + AliPayResponseSuffix
+	source, err := parseResponseSource(responseByte, methodNodeName)
+	if err != nil {
+		return parseError(err, responseByte)
+	}
+	if source.NodeName == "" {
 		return SignNotFound
 	}
-	if sign != "" {
-		aliPayPublicKey, err := m.getAliPayPublicKey(certSN)
+	isErrorResponse := source.NodeName == AliPayErrorResponse
+	//error_response 携带签名时同样验证
+	if source.Sign != "" {
+		aliPayPublicKey, err := m.getAliPayPublicKey(source.CertSN)
 		if err != nil {
 			return err
 		}
-		if ok, err := m.verifyData([]byte(content), sign, aliPayPublicKey); ok == false {
-			return err
+		if ok, err := m.verifyData(source.Content, source.Sign, aliPayPublicKey); ok == false {
+			return signatureError(err, responseByte)
 		}
 	}
-	err = json.Unmarshal(responseByte, result)
-	if err != nil {
-		return err
+	errorRes := &ErrorRes{}
+	if err := json.Unmarshal(source.Content, errorRes); err != nil {
+		return parseError(err, responseByte)
+	}
+	//部分接口成功时不返回code
+	if isErrorResponse || (errorRes.Code != "" && errorRes.Code != CodeSuccess) {
+		return errorRes.toError(responseByte)
+	}
+	if source.Sign == "" {
+		return SignNotFound
 	}
 
-	return err
+	return nil
 }
 
 /**
@@ -265,7 +421,12 @@ func (m *AliPayClient) NotifyVerify(notifyData url.Values) (bool, error) {
 	sort.Strings(paramList)
 	notifyParam := strings.Join(paramList, "&")
 
-	return m.verifyData([]byte(notifyParam), notifyData.Get(AliPaySignNodeName), m.aliPayPublicKeyList[m.aliPayCertSN])
+	ok, err := m.verifyData([]byte(notifyParam), notifyData.Get(AliPaySignNodeName), m.aliPayPublicKeyList[m.aliPayCertSN])
+	if err != nil {
+		return ok, signatureError(err, nil)
+	}
+
+	return ok, nil
 }
 
 /**
@@ -299,46 +460,6 @@ func (m *AliPayClient) getAliPayPublicKey(certSN string) (key *rsa.PublicKey, er
 	return key, nil
 }
 
-/**
- * 解析支付宝响应JSON字符串
- */
-func (m *AliPayClient) parseJSONSource(rawData, nodeName string, nodeIndex int) (content, certSN, sign string) {
-	var dataEndIndex int
-	dataStartIndex := nodeIndex + len(nodeName) + 2
-	signIndex := strings.LastIndex(rawData, "\""+AliPaySignNodeName+"\"")
-	certIndex := strings.LastIndex(rawData, "\""+AliPayCertSNNodeName+"\"")
-
-	if signIndex > 0 && certIndex > 0 {
-		dataEndIndex = int(math.Min(float64(signIndex), float64(certIndex))) - 1
-	} else if certIndex > 0 {
-		dataEndIndex = certIndex - 1
-	} else if signIndex > 0 {
-		dataEndIndex = signIndex - 1
-	} else {
-		dataEndIndex = len(rawData) - 1
-	}
-	indexLen := dataEndIndex - dataStartIndex
-	if indexLen < 0 {
-		return "", "", ""
-	}
-
-	if certIndex > 0 {
-		certStartIndex := certIndex + len(AliPayCertSNNodeName) + 4
-		certSN = rawData[certStartIndex:]
-		certEndIndex := strings.Index(certSN, "\"")
-		certSN = certSN[:certEndIndex]
-	}
-
-	if signIndex > 0 {
-		signStartIndex := signIndex + len(AliPaySignNodeName) + 4
-		sign = rawData[signStartIndex:]
-		signEndIndex := strings.LastIndex(sign, "\"")
-		sign = sign[:signEndIndex]
-	}
-
-	return rawData[dataStartIndex:dataEndIndex], certSN, sign
-}
-
 /**
  * 组装支付宝签名
  */
@@ -1,6 +1,10 @@
 package kernel
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/shinmigo/gopay"
+)
 
 const (
 	/**
@@ -79,11 +83,12 @@ const (
 	AliPaySignTypeNodeName = "sign_type"
 	AliPayCertSNNodeName   = "alipay_cert_sn"
 	AliPayErrorResponse    = "error_response"
+	AliPayResponseSuffix   = "_response"
 
 	CodeSuccess string = "10000" // 接口调用成功
 )
 
 var (
-	SignNotFound            = errors.New("alipay: sign content not found")
-	AliPayPublicKeyNotFound = errors.New("alipay: alipay public key not found")
+	SignNotFound            error = &gopay.Error{Provider: gopay.ProviderAliPay, Message: "sign content not found", Kind: gopay.ErrSignatureInvalid}
+	AliPayPublicKeyNotFound       = errors.New("alipay: alipay public key not found")
 )
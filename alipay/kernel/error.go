@@ -0,0 +1,75 @@
+package kernel
+
+import (
+	"github.com/shinmigo/gopay"
+)
+
+/**
+ * 业务返回码对应的错误分类，可按需补充
+ */
+var SubCodeErrors = map[string]error{
+	"ACQ.TRADE_HAS_SUCCESS":                 gopay.ErrOrderPaid,
+	"ACQ.TRADE_HAS_FINISHED":                gopay.ErrOrderPaid,
+	"ACQ.TRADE_NOT_EXIST":                   gopay.ErrOrderNotExist,
+	"ACQ.BUYER_BALANCE_NOT_ENOUGH":          gopay.ErrInsufficientBalance,
+	"ACQ.BUYER_BANKCARD_BALANCE_NOT_ENOUGH": gopay.ErrInsufficientBalance,
+	"ACQ.SELLER_BALANCE_NOT_ENOUGH":         gopay.ErrInsufficientBalance,
+	"isv.invalid-signature":                 gopay.ErrSignatureInvalid,
+	"ACQ.SYSTEM_ERROR":                      gopay.ErrSystemBusy,
+	"aop.ACQ.SYSTEM_ERROR":                  gopay.ErrSystemBusy,
+	"isp.unknow-error":                      gopay.ErrSystemBusy,
+	"isp.unknown-error":                     gopay.ErrSystemBusy,
+}
+
+/**
+ * 网关返回码对应的错误分类
+ */
+var codeErrors = map[string]error{
+	"20000": gopay.ErrSystemBusy,
+}
+
+/**
+ * 转换为统一的错误类型
+ */
+func (m *ErrorRes) toError(raw []byte) *gopay.Error {
+	message := m.SubMsg
+	if message == "" {
+		message = m.Msg
+	}
+	kind := SubCodeErrors[m.SubCode]
+	if kind == nil {
+		kind = codeErrors[m.Code]
+	}
+
+	return &gopay.Error{
+		Provider: gopay.ProviderAliPay,
+		Code:     m.Code,
+		SubCode:  m.SubCode,
+		Message:  message,
+		Raw:      raw,
+		Kind:     kind,
+	}
+}
+
+/**
+ * 签名验证失败
+ */
+func signatureError(err error, raw []byte) *gopay.Error {
+	return &gopay.Error{
+		Provider: gopay.ProviderAliPay,
+		Message:  err.Error(),
+		Raw:      raw,
+		Kind:     gopay.ErrSignatureInvalid,
+	}
+}
+
+/**
+ * 响应解析失败
+ */
+func parseError(err error, raw []byte) *gopay.Error {
+	return &gopay.Error{
+		Provider: gopay.ProviderAliPay,
+		Message:  err.Error(),
+		Raw:      raw,
+	}
+}
@@ -0,0 +1,93 @@
+package kernel
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+/**
+ * 通用请求，用于调用尚未封装的开放平台接口
+ */
+type Request struct {
+	Method     string            // 接口名称，如 alipay.trade.create
+	BizContent interface{}       // 业务参数 biz_content，结构体或map，为空时不传
+	TextParams map[string]string // 公共请求参数，如 notify_url、return_url、app_auth_token
+	Idempotent bool              // 是否为幂等请求，幂等请求按重试策略重试
+}
+
+func (m *Request) GetAliPayMethod() string {
+	return m.Method
+}
+
+func (m *Request) GetTextParams() map[string]string {
+	return m.TextParams
+}
+
+func (m *Request) IsIdempotent() bool {
+	return m.Idempotent
+}
+
+/**
+ * 业务参数序列化为 biz_content
+ */
+func (m *Request) MarshalJSON() ([]byte, error) {
+	if m.BizContent == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(m.BizContent)
+}
+
+/**
+ * 支付宝同步响应，Body 为接口响应节点
+ */
+type Response[T any] struct {
+	NodeName string          // 响应节点名称，如 alipay_trade_query_response
+	Body     T               // 响应节点
+	Raw      json.RawMessage // 响应节点的原始内容
+	Sign     string          // 签名
+	CertSN   string          // 支付宝公钥证书序列号，公钥模式下为空
+}
+
+func (m *Response[T]) UnmarshalJSON(data []byte) error {
+	source, err := parseResponseSource(data, "")
+	if err != nil {
+		return err
+	}
+	if source.NodeName == "" {
+		return errors.New("alipay: response node not found")
+	}
+	m.NodeName = source.NodeName
+	m.Raw = source.Content
+	m.Sign = source.Sign
+	m.CertSN = source.CertSN
+	if err = json.Unmarshal(source.Content, &m.Body); err != nil {
+		return err
+	}
+	return decodeAmounts(source.Content, &m.Body)
+}
+
+func (m Response[T]) MarshalJSON() ([]byte, error) {
+	response := map[string]interface{}{m.NodeName: m.Body}
+	if len(m.Sign) > 0 {
+		response[AliPaySignNodeName] = m.Sign
+	}
+	if len(m.CertSN) > 0 {
+		response[AliPayCertSNNodeName] = m.CertSN
+	}
+	return json.Marshal(response)
+}
+
+/**
+ * 调用任意开放平台接口，返回验签后的响应，响应节点解析为 T
+ */
+func Execute[T any](client *AliPayClient, request *Request) (*Response[T], error) {
+	if request == nil || len(request.Method) == 0 {
+		return nil, errors.New(InitializeDataErr)
+	}
+
+	result := &Response[T]{}
+	if err := client.SendRequest("POST", request, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
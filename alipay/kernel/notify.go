@@ -0,0 +1,94 @@
+package kernel
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/shinmigo/gopay"
+)
+
+/**
+ * 将异步通知参数按json标签解析到结构体，gopay.Amount 字段按元解析
+ * json 标签为 - 的 gopay.Amount 字段按 amount 标签取参数，如
+ *   TotalAmount float64      `json:"total_amount"`
+ *   Total       gopay.Amount `json:"-" amount:"total_amount"`
+ */
+func DecodeNotify(notifyData url.Values, result interface{}) error {
+	value := reflect.ValueOf(result)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return errors.New(InitializeDataErr)
+	}
+
+	return decodeNotifyStruct(notifyData, value.Elem())
+}
+
+func decodeNotifyStruct(notifyData url.Values, value reflect.Value) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		fieldValue := value.Field(i)
+		if field.Anonymous && fieldValue.Kind() == reflect.Struct {
+			if err := decodeNotifyStruct(notifyData, fieldValue); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if amountName, ok := field.Tag.Lookup("amount"); ok && field.Type == amountType {
+			name = amountName
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+		paramValue := strings.TrimSpace(notifyData.Get(name))
+		if len(paramValue) == 0 {
+			continue
+		}
+
+		if field.Type == amountType {
+			amount, err := gopay.ParseYuan(paramValue)
+			if err != nil {
+				return err
+			}
+			fieldValue.SetInt(int64(amount))
+			continue
+		}
+		switch fieldValue.Kind() {
+		case reflect.String:
+			fieldValue.SetString(paramValue)
+		case reflect.Float32, reflect.Float64:
+			floatValue, err := strconv.ParseFloat(paramValue, 64)
+			if err != nil {
+				return err
+			}
+			fieldValue.SetFloat(floatValue)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			intValue, err := strconv.ParseInt(paramValue, 10, 64)
+			if err != nil {
+				return err
+			}
+			fieldValue.SetInt(intValue)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			uintValue, err := strconv.ParseUint(paramValue, 10, 64)
+			if err != nil {
+				return err
+			}
+			fieldValue.SetUint(uintValue)
+		case reflect.Bool:
+			boolValue, err := strconv.ParseBool(paramValue)
+			if err != nil {
+				return err
+			}
+			fieldValue.SetBool(boolValue)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package kernel
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/shinmigo/gopay"
+)
+
+type notifyBase struct {
+	TradeNo string `json:"trade_no"`
+}
+
+type notifyContent struct {
+	notifyBase
+	OutTradeNo  string       `json:"out_trade_no"`
+	TotalAmount float64      `json:"total_amount"`
+	Total       gopay.Amount `json:"-" amount:"total_amount"`
+	Receipt     gopay.Amount `json:"receipt_amount"`
+	PointAmount int64        `json:"point_amount"`
+}
+
+func TestDecodeNotify(t *testing.T) {
+	cases := []struct {
+		name   string
+		data   url.Values
+		result notifyContent
+		err    error
+	}{
+		{
+			name: "amount fields",
+			data: url.Values{"trade_no": {"2021"}, "out_trade_no": {"T1"}, "total_amount": {"100.50"}, "receipt_amount": {"0.01"}, "point_amount": {"3"}},
+			result: notifyContent{
+				notifyBase:  notifyBase{TradeNo: "2021"},
+				OutTradeNo:  "T1",
+				TotalAmount: 100.5,
+				Total:       gopay.Fen(10050),
+				Receipt:     gopay.Fen(1),
+				PointAmount: 3,
+			},
+		},
+		{
+			name:   "missing amount",
+			data:   url.Values{"out_trade_no": {"T1"}},
+			result: notifyContent{OutTradeNo: "T1"},
+		},
+		{
+			name: "amount precision",
+			data: url.Values{"total_amount": {"0.001"}},
+			err:  gopay.ErrAmountPrecision,
+		},
+		{
+			name: "amount format",
+			data: url.Values{"receipt_amount": {"abc"}},
+			err:  gopay.ErrAmountFormat,
+		},
+	}
+	for _, item := range cases {
+		t.Run(item.name, func(t *testing.T) {
+			result := notifyContent{}
+			err := DecodeNotify(item.data, &result)
+			if item.err != nil {
+				if !errors.Is(err, item.err) {
+					t.Errorf("err = %v, want %v", err, item.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != item.result {
+				t.Errorf("result = %+v, want %+v", result, item.result)
+			}
+		})
+	}
+}
+
+func TestResponseDecodeAmounts(t *testing.T) {
+	type queryContent struct {
+		TotalAmount string       `json:"total_amount"`
+		Total       gopay.Amount `json:"-" amount:"total_amount"`
+		Refund      gopay.Amount `json:"-" amount:"refund_fee"`
+	}
+	response := &Response[queryContent]{}
+	data := `{"alipay_trade_query_response":{"code":"10000","total_amount":"88.88","refund_fee":1.5},"sign":"abc"}`
+	if err := response.UnmarshalJSON([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if response.Body.TotalAmount != "88.88" || response.Body.Total != gopay.Fen(8888) || response.Body.Refund != gopay.Fen(150) {
+		t.Errorf("body = %+v", response.Body)
+	}
+}
@@ -0,0 +1,92 @@
+package kernel
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/**
+ * 支付宝响应中参与验签的内容
+ */
+type responseSource struct {
+	NodeName string // 响应节点名称，如 alipay_trade_query_response、error_response
+	Content  []byte // 响应节点的原始字节，与支付宝签名时的内容完全一致
+	Sign     string // 签名
+	CertSN   string // 支付宝公钥证书序列号，公钥模式下为空
+}
+
+/**
+ * 逐个读取响应顶层JSON对象的成员，截取响应节点的原始字节与签名
+ * 优先使用接口响应节点，不存在时使用 error_response；成员值中包含节点名称或 sign 不影响解析
+ * methodNodeName 为空时使用第一个以 _response 结尾的成员作为接口响应节点
+ * 响应节点、sign、alipay_cert_sn 重复出现时视为非法响应
+ */
+func parseResponseSource(data []byte, methodNodeName string) (*responseSource, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, errors.New("response is not a json object")
+	}
+
+	var methodContent, errorContent json.RawMessage
+	var methodKey string
+	source := &responseSource{}
+	seen := make(map[string]bool, 4)
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, errors.New("invalid response member name")
+		}
+
+		var target interface{}
+		switch {
+		case key == AliPayErrorResponse:
+			target = &errorContent
+		case key == AliPaySignNodeName:
+			target = &source.Sign
+		case key == AliPayCertSNNodeName:
+			target = &source.CertSN
+		case key == methodNodeName || (methodNodeName == "" && methodKey == "" && strings.HasSuffix(key, AliPayResponseSuffix)):
+			target = &methodContent
+			methodKey = key
+		default:
+			var ignored json.RawMessage
+			if err = decoder.Decode(&ignored); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate response member %s", key)
+		}
+		seen[key] = true
+		if err = decoder.Decode(target); err != nil {
+			return nil, fmt.Errorf("invalid response member %s: %w", key, err)
+		}
+	}
+	if _, err = decoder.Token(); err != nil {
+		return nil, err
+	}
+	if _, err = decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after response")
+	}
+
+	switch {
+	case len(methodContent) > 0:
+		source.NodeName, source.Content = methodKey, methodContent
+	case len(errorContent) > 0:
+		source.NodeName, source.Content = AliPayErrorResponse, errorContent
+	}
+	return source, nil
+}
@@ -0,0 +1,148 @@
+package kernel
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/shinmigo/gopay"
+)
+
+const queryNodeName = "alipay_trade_query_response"
+
+func TestParseResponseSource(t *testing.T) {
+	cases := []struct {
+		name     string
+		data     string
+		nodeName string
+		content  string
+		sign     string
+		certSN   string
+	}{
+		{
+			name:     "subject contains sign",
+			data:     `{"alipay_trade_query_response":{"code":"10000","subject":"sign"},"sign":"abc"}`,
+			nodeName: queryNodeName,
+			content:  `{"code":"10000","subject":"sign"}`,
+			sign:     "abc",
+		},
+		{
+			name:     "subject contains alipay_cert_sn",
+			data:     `{"alipay_trade_query_response":{"subject":"\"alipay_cert_sn\":\"x\""},"alipay_cert_sn":"sn1","sign":"abc"}`,
+			nodeName: queryNodeName,
+			content:  `{"subject":"\"alipay_cert_sn\":\"x\""}`,
+			sign:     "abc",
+			certSN:   "sn1",
+		},
+		{
+			name:     "subject contains method node name",
+			data:     `{"alipay_trade_query_response":{"subject":"{\"alipay_trade_query_response\":{}}"},"sign":"abc"}`,
+			nodeName: queryNodeName,
+			content:  `{"subject":"{\"alipay_trade_query_response\":{}}"}`,
+			sign:     "abc",
+		},
+		{
+			name:     "sign before response node",
+			data:     `{"sign":"abc","alipay_trade_query_response":{"code":"10000"}}`,
+			nodeName: queryNodeName,
+			content:  `{"code":"10000"}`,
+			sign:     "abc",
+		},
+		{
+			name:     "whitespace and escapes are kept",
+			data:     "{\"alipay_trade_query_response\" : { \"code\" : \"10000\",\n\t\"url\":\"https:\\/\\/example.com\\/a\" } ,\"sign\":\"abc\"}",
+			nodeName: queryNodeName,
+			content:  "{ \"code\" : \"10000\",\n\t\"url\":\"https:\\/\\/example.com\\/a\" }",
+			sign:     "abc",
+		},
+		{
+			name:     "method node preferred over error_response",
+			data:     `{"error_response":{"code":"40002"},"alipay_trade_query_response":{"code":"10000"},"sign":"abc"}`,
+			nodeName: queryNodeName,
+			content:  `{"code":"10000"}`,
+			sign:     "abc",
+		},
+		{
+			name:     "error_response",
+			data:     `{"error_response":{"code":"40002","sub_code":"isv.invalid-app-id"},"sign":"abc"}`,
+			nodeName: AliPayErrorResponse,
+			content:  `{"code":"40002","sub_code":"isv.invalid-app-id"}`,
+			sign:     "abc",
+		},
+	}
+	for _, item := range cases {
+		t.Run(item.name, func(t *testing.T) {
+			source, err := parseResponseSource([]byte(item.data), queryNodeName)
+			if err != nil {
+				t.Fatalf("parseResponseSource: %v", err)
+			}
+			if source.NodeName != item.nodeName {
+				t.Errorf("NodeName = %q, want %q", source.NodeName, item.nodeName)
+			}
+			if string(source.Content) != item.content {
+				t.Errorf("Content = %q, want %q", source.Content, item.content)
+			}
+			if source.Sign != item.sign {
+				t.Errorf("Sign = %q, want %q", source.Sign, item.sign)
+			}
+			if source.CertSN != item.certSN {
+				t.Errorf("CertSN = %q, want %q", source.CertSN, item.certSN)
+			}
+		})
+	}
+}
+
+func TestParseResponseSourceInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+	}{
+		{"duplicate response node", `{"alipay_trade_query_response":{"code":"10000"},"alipay_trade_query_response":{"code":"40004"},"sign":"abc"}`},
+		{"duplicate sign", `{"alipay_trade_query_response":{"code":"10000"},"sign":"abc","sign":"def"}`},
+		{"duplicate alipay_cert_sn", `{"alipay_trade_query_response":{},"alipay_cert_sn":"a","alipay_cert_sn":"b","sign":"abc"}`},
+		{"duplicate error_response", `{"error_response":{"code":"40002"},"error_response":{"code":"10000"}}`},
+		{"array", `[{"alipay_trade_query_response":{}}]`},
+		{"string", `"alipay_trade_query_response"`},
+		{"trailing object", `{"alipay_trade_query_response":{},"sign":"abc"}{"sign":"def"}`},
+		{"trailing garbage", `{"alipay_trade_query_response":{},"sign":"abc"} x`},
+		{"truncated", `{"alipay_trade_query_response":{"code":"10000"}`},
+	}
+	for _, item := range cases {
+		t.Run(item.name, func(t *testing.T) {
+			if _, err := parseResponseSource([]byte(item.data), queryNodeName); err == nil {
+				t.Fatalf("parseResponseSource(%s) succeeded, want error", item.data)
+			}
+		})
+	}
+}
+
+func TestVerifyErrorResponse(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &AliPayClient{
+		signType:            AliPaySignType2,
+		aliPayPublicKeyList: map[string]*rsa.PublicKey{"": &privateKey.PublicKey},
+	}
+	content := `{"code":"40004","msg":"Business Failed","sub_code":"ACQ.TRADE_NOT_EXIST","sub_msg":"交易不存在"}`
+	signBytes, err := Sign([]byte(content), privateKey, AliPaySignType2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := base64.StdEncoding.EncodeToString(signBytes)
+	param := &Request{Method: "alipay.trade.query"}
+
+	err = client.verifyResponse(param, []byte(`{"error_response":`+content+`,"sign":"`+sign+`"}`))
+	if !errors.Is(err, gopay.ErrOrderNotExist) {
+		t.Errorf("signed error_response: got %v, want %v", err, gopay.ErrOrderNotExist)
+	}
+
+	tampered := `{"code":"40004","msg":"Business Failed","sub_code":"ACQ.TRADE_HAS_SUCCESS","sub_msg":"交易不存在"}`
+	err = client.verifyResponse(param, []byte(`{"error_response":`+tampered+`,"sign":"`+sign+`"}`))
+	if !errors.Is(err, gopay.ErrSignatureInvalid) {
+		t.Errorf("tampered error_response: got %v, want %v", err, gopay.ErrSignatureInvalid)
+	}
+}
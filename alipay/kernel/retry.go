@@ -0,0 +1,44 @@
+package kernel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/shinmigo/gopay"
+)
+
+/**
+ * 可安全重复提交的请求，如查询、关闭交易、相同退款请求号的退款
+ * 实现该接口的请求才会按重试策略重试
+ */
+type Idempotent interface {
+	IsIdempotent() bool
+}
+
+/**
+ * 支付宝网关响应了5xx状态码
+ */
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (m *StatusError) Error() string {
+	return fmt.Sprintf("alipay: gateway responded with status %d", m.StatusCode)
+}
+
+/**
+ * 默认错误分类：网络错误、网关5xx与系统繁忙类业务错误可重试
+ */
+func isRetryable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return true
+	}
+	return errors.Is(err, gopay.ErrSystemBusy)
+}
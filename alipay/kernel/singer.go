@@ -28,6 +28,14 @@ func ParsePrivateKey(merchantPrivateKeyPath string) (*rsa.PrivateKey, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	return ParsePrivateKeyContent(byteContent)
+}
+
+/**
+ *	解析PKCS8格式私钥内容
+ */
+func ParsePrivateKeyContent(byteContent []byte) (*rsa.PrivateKey, error) {
 	byteContentLen := len(byteContent)
 	if byteContentLen == 0 {
 		return nil, errors.New(CertEmpty)
@@ -64,6 +72,14 @@ func ParsePublicKey(aliPayPublicKeyPath string) (*rsa.PublicKey, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	return ParsePublicKeyContent(byteContent)
+}
+
+/**
+ *	解析支付宝公钥内容
+ */
+func ParsePublicKeyContent(byteContent []byte) (*rsa.PublicKey, error) {
 	byteContentLen := len(byteContent)
 	if byteContentLen == 0 {
 		return nil, errors.New(CertEmpty)
@@ -151,6 +167,9 @@ func GetAliPayRootCertSN(certPath string) (string, error) {
 	certSnSlice := make([]string, 0, byteContentLen)
 	aliPayRootCertSlice := strings.Split(string(byteContent), AliPayRootCertEnd)
 	for _, certContent := range aliPayRootCertSlice {
+		if len(strings.TrimSpace(certContent)) == 0 {
+			continue
+		}
 		certContent = certContent + AliPayRootCertEnd
 
 		cert, err := ParseAliPayCert([]byte(certContent))
@@ -0,0 +1,78 @@
+package oauth
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/shinmigo/gopay/alipay/kernel"
+)
+
+type OAuth struct {
+	Client *kernel.AliPayClient
+}
+
+/**
+ * 使用授权码换取授权访问令牌
+ */
+func (m *OAuth) GetToken(code string) (result *TokenRes, err error) {
+	if len(code) == 0 {
+		return nil, errors.New(kernel.InitializeDataErr)
+	}
+
+	param := &Token{GrantType: GrantTypeAuthorizationCode, Code: code}
+	err = m.Client.SendRequest("POST", param, &result)
+	return result, err
+}
+
+/**
+ * 使用刷新令牌刷新授权访问令牌
+ */
+func (m *OAuth) RefreshToken(refreshToken string) (result *TokenRes, err error) {
+	if len(refreshToken) == 0 {
+		return nil, errors.New(kernel.InitializeDataErr)
+	}
+
+	param := &Token{GrantType: GrantTypeRefreshToken, RefreshToken: refreshToken}
+	err = m.Client.SendRequest("POST", param, &result)
+	return result, err
+}
+
+/**
+ * 支付宝会员授权信息查询接口
+ */
+func (m *OAuth) UserInfoShare(authToken string) (result *UserInfoShareRes, err error) {
+	if len(authToken) == 0 {
+		return nil, errors.New(kernel.InitializeDataErr)
+	}
+
+	param := &UserInfoShare{AuthToken: authToken}
+	err = m.Client.SendRequest("POST", param, &result)
+	return result, err
+}
+
+/**
+ * 生成用户信息授权页面地址
+ */
+func (m *OAuth) AuthorizeUrl(param *Authorize) string {
+	if param == nil {
+		return ""
+	}
+
+	scope := param.Scope
+	if len(scope) == 0 {
+		scope = ScopeAuthBase
+	}
+	urlMap := url.Values{}
+	urlMap.Set("app_id", m.Client.GetAppId())
+	urlMap.Set("scope", scope)
+	urlMap.Set("redirect_uri", param.RedirectUri)
+	if len(param.State) > 0 {
+		urlMap.Set("state", param.State)
+	}
+
+	authorizeUrl := AuthorizeBoxURL
+	if m.Client.IsProd() {
+		authorizeUrl = AuthorizeProdURL
+	}
+	return authorizeUrl + "?" + urlMap.Encode()
+}
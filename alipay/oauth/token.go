@@ -0,0 +1,105 @@
+package oauth
+
+import (
+	"encoding/json"
+
+	"github.com/shinmigo/gopay/alipay/kernel"
+)
+
+const (
+	/**
+	 * 用户信息授权页面地址
+	 */
+	AuthorizeBoxURL  = "https://openauth.alipaydev.com/oauth2/publicAppAuthorize.htm"
+	AuthorizeProdURL = "https://openauth.alipay.com/oauth2/publicAppAuthorize.htm"
+
+	ScopeAuthBase = "auth_base" // 静默授权，仅能获取用户的user_id
+	ScopeAuthUser = "auth_user" // 主动授权，可获取用户的基本信息
+
+	GrantTypeAuthorizationCode = "authorization_code" // 使用授权码换取令牌
+	GrantTypeRefreshToken      = "refresh_token"      // 使用刷新令牌换取令牌
+)
+
+/**
+ * 用户信息授权页面参数
+ */
+type Authorize struct {
+	Scope       string // 接口权限值，auth_base 或 auth_user，多个用逗号分隔
+	RedirectUri string // 授权回调地址
+	State       string // 商户自定义参数，用户授权后重定向到redirect_uri时会原样回传
+}
+
+/**
+ * 换取授权访问令牌
+ */
+type Token struct {
+	GrantType    string `json:"-"` // 授权方式，authorization_code 或 refresh_token
+	Code         string `json:"-"` // 授权码，与RefreshToken二选一
+	RefreshToken string `json:"-"` // 刷新令牌，与Code二选一
+}
+
+func (m *Token) GetAliPayMethod() string {
+	return "alipay.system.oauth.token"
+}
+
+func (m *Token) GetTextParams() map[string]string {
+	textParams := map[string]string{"grant_type": m.GrantType}
+	if len(m.Code) > 0 {
+		textParams["code"] = m.Code
+	}
+	if len(m.RefreshToken) > 0 {
+		textParams["refresh_token"] = m.RefreshToken
+	}
+	return textParams
+}
+
+type TokenResContent struct {
+	Code         string      `json:"code"`          //网关返回码
+	Msg          string      `json:"msg"`           //网关返回描述
+	SubCode      string      `json:"sub_code"`      //业务返回码
+	SubMsg       string      `json:"sub_msg"`       //业务返回码描述
+	UserId       string      `json:"user_id"`       // 支付宝用户的唯一userId
+	OpenId       string      `json:"open_id"`       // 支付宝用户在应用下的唯一标识
+	AccessToken  string      `json:"access_token"`  // 访问令牌，通过该令牌调用需要授权类接口
+	ExpiresIn    json.Number `json:"expires_in"`    // 访问令牌的有效时间，单位是秒
+	RefreshToken string      `json:"refresh_token"` // 刷新令牌，通过该令牌可以刷新access_token
+	ReExpiresIn  json.Number `json:"re_expires_in"` // 刷新令牌的有效时间，单位是秒
+	AuthStart    string      `json:"auth_start"`    // 授权token开始时间，作为有效期计算的起点
+}
+
+type TokenRes = kernel.Response[TokenResContent]
+
+/**
+ * 支付宝会员授权信息查询接口
+ */
+type UserInfoShare struct {
+	AuthToken string `json:"-"` // 用户授权访问令牌
+}
+
+func (m *UserInfoShare) GetAliPayMethod() string {
+	return "alipay.user.info.share"
+}
+
+func (m *UserInfoShare) GetTextParams() map[string]string {
+	return map[string]string{"auth_token": m.AuthToken}
+}
+
+type UserInfoShareResContent struct {
+	Code               string `json:"code"`                 //网关返回码
+	Msg                string `json:"msg"`                  //网关返回描述
+	SubCode            string `json:"sub_code"`             //业务返回码
+	SubMsg             string `json:"sub_msg"`              //业务返回码描述
+	UserId             string `json:"user_id"`              // 支付宝用户的userId
+	OpenId             string `json:"open_id"`              // 支付宝用户在应用下的唯一标识
+	Avatar             string `json:"avatar"`               // 用户头像地址
+	Province           string `json:"province"`             // 省份名称
+	City               string `json:"city"`                 // 市名称
+	NickName           string `json:"nick_name"`            // 用户昵称
+	IsStudentCertified string `json:"is_student_certified"` // 是否是学生，T为是，F为否
+	UserType           string `json:"user_type"`            // 用户类型，1代表公司账户，2代表个人账户
+	UserStatus         string `json:"user_status"`          // 用户状态，Q代表快速注册用户，T代表已认证用户，B代表被冻结账户，W代表已注册未激活用户
+	IsCertified        string `json:"is_certified"`         // 是否通过实名认证，T是通过，F是没有实名认证
+	Gender             string `json:"gender"`               // 性别，F：女性；M：男性
+}
+
+type UserInfoShareRes = kernel.Response[UserInfoShareResContent]
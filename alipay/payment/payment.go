@@ -2,12 +2,18 @@ package payment
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"net/url"
+	"sort"
 	"strings"
 
+	"github.com/shinmigo/gopay"
 	"github.com/shinmigo/gopay/alipay/kernel"
+	"github.com/shinmigo/gopay/store"
 )
 
 type Payment struct {
@@ -22,6 +28,10 @@ func (m *Payment) App(param *App) (string, error) {
 		return "", errors.New(kernel.InitializeDataErr)
 	}
 
+	param.setAmount()
+	if err := param.Validate(); err != nil {
+		return "", err
+	}
 	urlMap, err := m.Client.UrlParams(param)
 	if err != nil {
 		return "", err
@@ -37,12 +47,16 @@ func (m *Payment) Wap(param *Wap) (string, error) {
 		return "", errors.New(kernel.InitializeDataErr)
 	}
 
+	param.setAmount()
 	param.ProductCode = "QUICK_WAP_WAY"
+	if err := param.Validate(); err != nil {
+		return "", err
+	}
 	urlMap, err := m.Client.UrlParams(param)
 	if err != nil {
 		return "", err
 	}
-	return buildForm(m.Client.GetGatewayHost(), urlMap), nil
+	return buildPage(m.Client.GetGatewayHost(), urlMap, param.Output, param.Nonce), nil
 }
 
 /*
@@ -53,12 +67,32 @@ func (m *Payment) Page(param *Page) (string, error) {
 		return "", errors.New(kernel.InitializeDataErr)
 	}
 
+	param.setAmount()
 	param.ProductCode = "FAST_INSTANT_TRADE_PAY"
+	if err := param.Validate(); err != nil {
+		return "", err
+	}
 	urlMap, err := m.Client.UrlParams(param)
 	if err != nil {
 		return "", err
 	}
-	return buildForm(m.Client.GetGatewayHost(), urlMap), nil
+	return buildPage(m.Client.GetGatewayHost(), urlMap, param.Output, param.Nonce), nil
+}
+
+/**
+ * 统一收单交易支付接口，传入AuthNo时从资金预授权冻结金额中支付
+ */
+func (m *Payment) TradePay(param *TradePay) (result *TradePayRes, err error) {
+	if param == nil {
+		return nil, errors.New(kernel.InitializeDataErr)
+	}
+
+	param.setAmount()
+	if err = param.Validate(); err != nil {
+		return nil, err
+	}
+	err = m.Client.SendRequest("POST", param, &result)
+	return result, err
 }
 
 /**
@@ -69,6 +103,9 @@ func (m *Payment) TradeQuery(param *TradeQuery) (result *TradeQueryRes, err erro
 		return nil, errors.New(kernel.InitializeDataErr)
 	}
 
+	if err = param.Validate(); err != nil {
+		return nil, err
+	}
 	err = m.Client.SendRequest("POST", param, &result)
 	return result, err
 }
@@ -81,6 +118,9 @@ func (m *Payment) TradeClose(param *TradeClose) (result *TradeCloseRes, err erro
 		return nil, errors.New(kernel.InitializeDataErr)
 	}
 
+	if err = param.Validate(); err != nil {
+		return nil, err
+	}
 	err = m.Client.SendRequest("POST", param, &result)
 	return result, err
 }
@@ -93,6 +133,10 @@ func (m *Payment) TradeRefund(param *TradeRefund) (result *TradeRefundRes, err e
 		return nil, errors.New(kernel.InitializeDataErr)
 	}
 
+	param.setAmount()
+	if err = param.Validate(); err != nil {
+		return nil, err
+	}
 	err = m.Client.SendRequest("POST", param, &result)
 	return result, err
 }
@@ -105,27 +149,118 @@ func (m *Payment) RefundQuery(param *RefundQuery) (result *RefundQueryRes, err e
 		return nil, errors.New(kernel.InitializeDataErr)
 	}
 
+	if err = param.Validate(); err != nil {
+		return nil, err
+	}
 	err = m.Client.SendRequest("POST", param, &result)
 	return result, err
 }
 
-/*
- *生成页面类请求所需URL或Form表单
+/**
+ * 调用尚未封装的开放平台接口，request.Idempotent 为 true 时按重试策略重试
+ * 返回验签后的响应节点原始内容，result 不为空时将响应节点解析到 result
  */
-func buildForm(actionUrl string, parameters url.Values) string {
-	buffers := &bytes.Buffer{}
-	buffers.WriteString(fmt.Sprintf("<form id='alipaysubmit' name='alipaysubmit' action='%s?charset=%s' method='POST'>", actionUrl, kernel.AliPayCharset))
+func (m *Payment) Execute(request *kernel.Request, result interface{}) (json.RawMessage, error) {
+	response, err := kernel.Execute[json.RawMessage](m.Client, request)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		if err = json.Unmarshal(response.Raw, result); err != nil {
+			return nil, err
+		}
+	}
+	return response.Raw, nil
+}
+
+/**
+ * 交易异步通知验证签名
+ */
+func (m *Payment) NotifyVerify(notifyData url.Values) (*Notify, error) {
+	if ok, err := m.Client.NotifyVerify(notifyData); ok == false {
+		return nil, err
+	}
+
+	res := &Notify{}
+	if err := kernel.DecodeNotify(notifyData, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+/**
+ * 交易异步通知验证签名，并按 notify_id 幂等更新订单状态
+ * 首次收到的通知执行 handler，重复通知直接确认；返回需要响应给支付宝的内容
+ */
+func (m *Payment) NotifyProcess(ctx context.Context, orderStore store.Store, notifyData url.Values, handler func(ctx context.Context, notify *Notify, event *store.Event) error) (string, error) {
+	notify, err := m.NotifyVerify(notifyData)
+	if err != nil {
+		return NotifyFailure, err
+	}
+	state, err := store.AliPayState(notify.TradeStatus)
+	if err != nil {
+		return NotifyFailure, err
+	}
+
+	notification := &store.Notification{
+		Provider:      gopay.ProviderAliPay,
+		NotifyId:      notify.NotifyId,
+		OutTradeNo:    notify.OutTradeNo,
+		TransactionId: notify.TradeNo,
+		State:         state,
+		Amount:        notify.Total,
+	}
+	_, err = store.Process(ctx, orderStore, notification, func(ctx context.Context, event *store.Event) error {
+		if handler == nil {
+			return nil
+		}
+		return handler(ctx, notify, event)
+	})
+	if err != nil {
+		return NotifyFailure, err
+	}
+	return NotifySuccess, nil
+}
 
+/**
+ * 按返回格式生成页面类请求所需的表单、跳转地址或请求参数
+ */
+func buildPage(gatewayHost string, urlMap url.Values, output, nonce string) string {
+	switch output {
+	case OutputUrl:
+		return gatewayHost + "?" + urlMap.Encode()
+	case OutputParams:
+		return urlMap.Encode()
+	}
+	return buildForm(gatewayHost, urlMap, nonce)
+}
+
+/*
+ *生成页面类请求所需Form表单，参数名与参数值按HTML属性转义
+ */
+func buildForm(actionUrl string, parameters url.Values, nonce string) string {
+	names := make([]string, 0, len(parameters))
 	for name := range parameters {
-		value := parameters.Get(name)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buffers := &bytes.Buffer{}
+	buffers.WriteString(fmt.Sprintf(`<form id="alipaysubmit" name="alipaysubmit" action="%s" method="POST">`, html.EscapeString(actionUrl+"?charset="+kernel.AliPayCharset)))
+	for _, name := range names {
+		value := strings.TrimSpace(parameters.Get(name))
 		if value == "" {
 			continue
 		}
-		value = strings.ReplaceAll(strings.Trim(value, " "), "'", "&apos;")
-		buffers.WriteString(fmt.Sprintf("<input type='hidden' name='%s' value='%s'/>", name, value))
+		buffers.WriteString(fmt.Sprintf(`<input type="hidden" name="%s" value="%s"/>`, html.EscapeString(name), html.EscapeString(value)))
 	}
 
-	buffers.WriteString("<input type='submit' value='ok' style='display:none;'/></form>")
-	buffers.WriteString("<script>document.forms['alipaysubmit'].submit();</script>")
+	buffers.WriteString(`<input type="submit" value="ok" style="display:none;"/></form>`)
+	if len(nonce) > 0 {
+		buffers.WriteString(fmt.Sprintf(`<script nonce="%s">`, html.EscapeString(nonce)))
+	} else {
+		buffers.WriteString("<script>")
+	}
+	buffers.WriteString("document.forms['alipaysubmit'].submit();</script>")
 	return buffers.String()
 }
@@ -1,5 +1,10 @@
 package payment
 
+import (
+	"github.com/shinmigo/gopay"
+	"github.com/shinmigo/gopay/alipay/kernel"
+)
+
 type GoodsDetail struct {
 	GoodsId        string `json:"goods_id"`
 	AliPayGoodsId  string `json:"alipay_goods_id,omitempty"`
@@ -13,22 +18,47 @@ type GoodsDetail struct {
 }
 
 type Trade struct {
-	Subject           string         `json:"subject"`                   //商品的标题/交易标题/订单标题/订单关键字等。
-	OutTradeNo        string         `json:"out_trade_no"`              //商户网站唯一订单号
-	TotalAmount       string         `json:"total_amount"`              //订单总金额，单位为元，精确到小数点后两位，取值范围[0.01,100000000]
-	TimeoutExpress    string         `json:"timeout_express,omitempty"` //该笔订单允许的最晚付款时间，逾期将关闭交易。取值范围：5m～15d。m-分钟，h-小时，d-天，1c-当天（1c-当天的情况下，无论交易何时创建，都在0点关闭）。 该参数数值不接受小数点， 如 1.5h，可转换为 90m。
-	TimeExpire        string         `json:"time_expire,omitempty"`     //绝对超时时间，格式为yyyy-MM-dd HH:mm。
-	AuthToken         string         `json:"auth_token,omitempty"`
-	GoodsType         string         `json:"goods_type,omitempty"` // 商品主类型：0—虚拟类商品，1—实物类商品 注：虚拟类商品不支持使用花呗渠道
-	QuitUrl           string         `json:"quit_url,omitempty"`
-	Body              string         `json:"body,omitempty"`            //对一笔交易的具体描述信息。如果是多种商品，请将商品描述字符串累加传给body
-	PromoParams       string         `json:"promo_params,omitempty"`    // 优惠参数 注：仅与支付宝协商后可用
-	PassbackParams    string         `json:"passback_params,omitempty"` //公用回传参数，如果请求时传递了该参数，则返回给商户时会回传该参数
-	GoodsDetail       []*GoodsDetail `json:"goods_detail,omitempty"`
-	EnablePayChannels string         `json:"enable_pay_channels,omitempty"` //可用渠道，用户只能在指定渠道范围内支付 当有多个渠道时用“,”分隔 注，与disable_pay_channels互斥
-	StoreId           string         `json:"store_id,omitempty"`            // 商户门店编号。该参数用于请求参数中以区分各门店，非必传项
-	SpecifiedChannel  string         `json:"specified_channel,omitempty"`   // 指定渠道，目前仅支持传入pcredit  若由于用户原因渠道不可用，用户可选择是否用其他渠道支付。  注：该参数不可与花呗分期参数同时传入
-	BusinessParams    string         `json:"business_params,omitempty"`     // 商户传入业务信息，具体值要和支付宝约定，应用于安全，营销等参数直传场景，格式为json格式
+	Subject           string           `json:"subject"`                   //商品的标题/交易标题/订单标题/订单关键字等。
+	OutTradeNo        string           `json:"out_trade_no"`              //商户网站唯一订单号
+	TotalAmount       string           `json:"total_amount"`              //订单总金额，单位为元，精确到小数点后两位，取值范围[0.01,100000000]
+	TimeoutExpress    string           `json:"timeout_express,omitempty"` //该笔订单允许的最晚付款时间，逾期将关闭交易。取值范围：5m～15d。m-分钟，h-小时，d-天，1c-当天（1c-当天的情况下，无论交易何时创建，都在0点关闭）。 该参数数值不接受小数点， 如 1.5h，可转换为 90m。
+	TimeExpire        string           `json:"time_expire,omitempty"`     //绝对超时时间，格式为yyyy-MM-dd HH:mm。
+	AuthToken         string           `json:"auth_token,omitempty"`
+	GoodsType         string           `json:"goods_type,omitempty"` // 商品主类型：0—虚拟类商品，1—实物类商品 注：虚拟类商品不支持使用花呗渠道
+	QuitUrl           string           `json:"quit_url,omitempty"`
+	Body              string           `json:"body,omitempty"`            //对一笔交易的具体描述信息。如果是多种商品，请将商品描述字符串累加传给body
+	PromoParams       string           `json:"promo_params,omitempty"`    // 优惠参数 注：仅与支付宝协商后可用
+	PassbackParams    string           `json:"passback_params,omitempty"` //公用回传参数，如果请求时传递了该参数，则返回给商户时会回传该参数
+	GoodsDetail       []*GoodsDetail   `json:"goods_detail,omitempty"`
+	EnablePayChannels string           `json:"enable_pay_channels,omitempty"` //可用渠道，用户只能在指定渠道范围内支付 当有多个渠道时用“,”分隔 注，与disable_pay_channels互斥
+	StoreId           string           `json:"store_id,omitempty"`            // 商户门店编号。该参数用于请求参数中以区分各门店，非必传项
+	SpecifiedChannel  string           `json:"specified_channel,omitempty"`   // 指定渠道，目前仅支持传入pcredit  若由于用户原因渠道不可用，用户可选择是否用其他渠道支付。  注：该参数不可与花呗分期参数同时传入
+	BusinessParams    string           `json:"business_params,omitempty"`     // 商户传入业务信息，具体值要和支付宝约定，应用于安全，营销等参数直传场景，格式为json格式
+	AgreementParams   *AgreementParams `json:"agreement_params,omitempty"`    // 代扣信息，代扣业务需要传入协议相关信息
+	Amount            gopay.Amount     `json:"-"`                             // 订单总金额，TotalAmount 为空时使用
+}
+
+/**
+ * 订单总金额，TotalAmount 为空时使用 Amount
+ */
+func (m *Trade) totalAmount() string {
+	if m.TotalAmount == "" && m.Amount != 0 {
+		return m.Amount.String()
+	}
+	return m.TotalAmount
+}
+
+/**
+ * 使用 Amount 填充订单总金额
+ */
+func (m *Trade) setAmount() {
+	m.TotalAmount = m.totalAmount()
+}
+
+type AgreementParams struct {
+	AgreementNo   string `json:"agreement_no"`              // 支付宝系统中用以唯一标识用户签约记录的编号（用户签约成功后的协议号）
+	AuthConfirmNo string `json:"auth_confirm_no,omitempty"` // 鉴权确认码，在需要做支付鉴权校验时，该参数不能为空
+	ApplyToken    string `json:"apply_token,omitempty"`     // 鉴权申请token，其格式和内容，由支付宝定义
 }
 
 type App struct {
@@ -42,28 +72,136 @@ func (m *App) GetAliPayMethod() string {
 	return "alipay.trade.app.pay"
 }
 
+/**
+ * 手机网站支付、PC网站支付的返回格式
+ */
+const (
+	OutputForm   = "form"   // 自动提交的POST表单，默认
+	OutputUrl    = "url"    // GET跳转地址
+	OutputParams = "params" // 签名后的请求参数，格式同APP支付
+)
+
+/**
+ * PC网站支付的二维码模式
+ */
+const (
+	QrPayModeSimple = "0" // 订单码-简约前置模式，对应 iframe 宽度不能小于600px，高度不能小于300px
+	QrPayModeFront  = "1" // 订单码-前置模式，对应iframe 宽度不能小于 300px，高度不能小于600px
+	QrPayModeJump   = "2" // 订单码-跳转模式
+	QrPayModeMini   = "3" // 订单码-迷你前置模式，对应 iframe 宽度不能小于 75px，高度不能小于75px
+	QrPayModeCustom = "4" // 订单码-可定义宽度的嵌入式二维码，需传入 QrcodeWidth
+)
+
 type Wap struct {
 	Trade
 	NotifyUrl   string `json:"-"`                      //异步通知地址
 	ReturnUrl   string `json:"-"`                      //支付返回地址
 	ProductCode string `json:"product_code,omitempty"` //销售产品码，商家和支付宝签约的产品码
+	Output      string `json:"-"`                      //返回格式 form、url、params，默认form
+	Nonce       string `json:"-"`                      //表单中script标签的CSP nonce（可选）
 }
 
 func (m *Wap) GetAliPayMethod() string {
 	return "alipay.trade.wap.pay"
 }
 
+func (m *Wap) GetTextParams() map[string]string {
+	return pageTextParams(m.NotifyUrl, m.ReturnUrl)
+}
+
 type Page struct {
 	Trade
-	NotifyUrl   string `json:"-"`                      //异步通知地址
-	ReturnUrl   string `json:"-"`                      //支付返回地址
-	ProductCode string `json:"product_code,omitempty"` //销售产品码，商家和支付宝签约的产品码
+	NotifyUrl       string `json:"-"`                          //异步通知地址
+	ReturnUrl       string `json:"-"`                          //支付返回地址
+	ProductCode     string `json:"product_code,omitempty"`     //销售产品码，商家和支付宝签约的产品码
+	QrPayMode       string `json:"qr_pay_mode,omitempty"`      //PC扫码支付的方式，见 QrPayMode 常量
+	QrcodeWidth     int64  `json:"qrcode_width,omitempty"`     //商户自定义二维码宽度，qr_pay_mode=4时有效
+	IntegrationType string `json:"integration_type,omitempty"` //请求后页面的集成方式，ALIAPP：支付宝钱包内，PCWEB：PC端访问，默认PCWEB
+	RequestFromUrl  string `json:"request_from_url,omitempty"` //请求来源地址，integration_type=ALIAPP时，用户在收银台点击返回时跳回的地址
+	Output          string `json:"-"`                          //返回格式 form、url、params，默认form
+	Nonce           string `json:"-"`                          //表单中script标签的CSP nonce（可选）
 }
 
 func (m *Page) GetAliPayMethod() string {
 	return "alipay.trade.page.pay"
 }
 
+func (m *Page) GetTextParams() map[string]string {
+	return pageTextParams(m.NotifyUrl, m.ReturnUrl)
+}
+
+/**
+ * 页面跳转类接口的异步通知地址与支付返回地址，为空时使用客户端配置
+ */
+func pageTextParams(notifyUrl, returnUrl string) map[string]string {
+	textParams := make(map[string]string, 2)
+	if len(notifyUrl) > 0 {
+		textParams["notify_url"] = notifyUrl
+	}
+	if len(returnUrl) > 0 {
+		textParams["return_url"] = returnUrl
+	}
+	return textParams
+}
+
+/**
+ * 统一收单交易支付接口
+ */
+type TradePay struct {
+	Trade
+	NotifyUrl       string `json:"-"`                           //异步通知地址
+	ReturnUrl       string `json:"-"`                           //支付返回地址
+	Scene           string `json:"scene,omitempty"`             // 支付场景 条码支付，取值：bar_code 声波支付，取值：wave_code
+	AuthCode        string `json:"auth_code,omitempty"`         // 支付授权码，25~30开头的长度为16~24位的数字，实际字符串长度以开发者获取的付款码长度为准
+	ProductCode     string `json:"product_code,omitempty"`      // 销售产品码，资金预授权转支付时取值PRE_AUTH_ONLINE（线上）或PRE_AUTH（当面）
+	BuyerId         string `json:"buyer_id,omitempty"`          // 买家的支付宝用户id，资金预授权转支付时为冻结资金的付款方
+	SellerId        string `json:"seller_id,omitempty"`         // 如果该值为空，则默认为商户签约账号对应的支付宝用户ID
+	AuthNo          string `json:"auth_no,omitempty"`           // 资金预授权单号，传入该参数表示从冻结金额中支付
+	AuthConfirmMode string `json:"auth_confirm_mode,omitempty"` // 预授权确认模式，COMPLETE：转交易支付完成结束预授权，解冻剩余金额; NOT_COMPLETE：转交易支付完成不结束预授权，剩余金额可继续操作
+	TerminalId      string `json:"terminal_id,omitempty"`       // 商户机具终端编号
+	OperatorId      string `json:"operator_id,omitempty"`       // 商户操作员编号
+}
+
+func (m *TradePay) GetAliPayMethod() string {
+	return "alipay.trade.pay"
+}
+
+type TradePayResContent struct {
+	Code                string       `json:"code"`                        // 网关返回码
+	Msg                 string       `json:"msg"`                         // 网关返回描述
+	SubCode             string       `json:"sub_code"`                    // 业务返回码
+	SubMsg              string       `json:"sub_msg"`                     // 业务返回码描述
+	TradeNo             string       `json:"trade_no"`                    // 支付宝交易号
+	OutTradeNo          string       `json:"out_trade_no"`                // 商户订单号
+	BuyerLogonId        string       `json:"buyer_logon_id"`              // 买家支付宝账号
+	SettleAmount        string       `json:"settle_amount"`               // 结算币种订单金额
+	PayCurrency         string       `json:"pay_currency"`                // 支付币种
+	PayAmount           string       `json:"pay_amount"`                  // 支付币种订单金额
+	SettleTransRate     string       `json:"settle_trans_rate"`           // 结算币种兑换标价币种汇率
+	TransPayRate        string       `json:"trans_pay_rate"`              // 标价币种兑换支付币种汇率
+	TotalAmount         string       `json:"total_amount"`                // 交易金额
+	Total               gopay.Amount `json:"-" amount:"total_amount"`     // 交易金额
+	TransCurrency       string       `json:"trans_currency"`              // 标价币种
+	SettleCurrency      string       `json:"settle_currency"`             // 商户指定的结算币种
+	ReceiptAmount       string       `json:"receipt_amount"`              // 实收金额
+	Receipt             gopay.Amount `json:"-" amount:"receipt_amount"`   // 实收金额
+	BuyerPayAmount      string       `json:"buyer_pay_amount"`            // 买家付款的金额
+	BuyerPay            gopay.Amount `json:"-" amount:"buyer_pay_amount"` // 买家付款的金额
+	PointAmount         string       `json:"point_amount"`                // 使用集分宝付款的金额
+	InvoiceAmount       string       `json:"invoice_amount"`              // 交易中可给用户开具发票的金额
+	GmtPayment          string       `json:"gmt_payment"`                 // 交易支付时间
+	FundBillList        []*FundBill  `json:"fund_bill_list"`              // 交易支付使用的资金渠道
+	CardBalance         string       `json:"card_balance"`                // 支付宝卡余额
+	StoreName           string       `json:"store_name"`                  // 发生支付交易的商户门店名称
+	BuyerUserId         string       `json:"buyer_user_id"`               // 买家在支付宝的用户id
+	DiscountGoodsDetail string       `json:"discount_goods_detail"`       // 本次交易支付所使用的单品券优惠的商品优惠信息
+	AuthTradePayMode    string       `json:"auth_trade_pay_mode"`         // 预授权支付模式，该参数仅在信用预授权支付场景下返回。信用预授权支付：CREDIT_PREAUTH_PAY
+	MdiscountAmount     string       `json:"mdiscount_amount"`            // 商家优惠金额
+	DiscountAmount      string       `json:"discount_amount"`             // 平台优惠金额
+}
+
+type TradePayRes = kernel.Response[TradePayResContent]
+
 /**
  * 统一收单线下交易查询
  */
@@ -78,6 +216,10 @@ func (m *TradeQuery) GetAliPayMethod() string {
 	return "alipay.trade.query"
 }
 
+func (m *TradeQuery) IsIdempotent() bool {
+	return true
+}
+
 type FundBill struct {
 	FundChannel string  `json:"fund_channel"`       // 交易使用的资金渠道，详见 支付渠道列表
 	Amount      string  `json:"amount"`             // 该支付工具类型所使用的金额
@@ -107,6 +249,7 @@ type TradeQueryResContent struct {
 	BuyerLogonId        string           `json:"buyer_logon_id"`              // 买家支付宝账号
 	TradeStatus         string           `json:"trade_status"`                // 交易状态
 	TotalAmount         string           `json:"total_amount"`                // 交易的订单金额
+	Total               gopay.Amount     `json:"-" amount:"total_amount"`     // 交易金额
 	TransCurrency       string           `json:"trans_currency"`              // 标价币种
 	SettleCurrency      string           `json:"settle_currency"`             // 订单结算币种
 	SettleAmount        string           `json:"settle_amount"`               // 结算币种订单金额
@@ -115,10 +258,12 @@ type TradeQueryResContent struct {
 	SettleTransRate     string           `json:"settle_trans_rate"`           // 结算币种兑换标价币种汇率
 	TransPayRate        string           `json:"trans_pay_rate"`              // 标价币种兑换支付币种汇率
 	BuyerPayAmount      string           `json:"buyer_pay_amount"`            // 买家实付金额，单位为元，两位小数。
+	BuyerPay            gopay.Amount     `json:"-" amount:"buyer_pay_amount"` // 买家付款的金额
 	PointAmount         string           `json:"point_amount"`                // 积分支付的金额，单位为元，两位小数。
 	InvoiceAmount       string           `json:"invoice_amount"`              // 交易中用户支付的可开具发票的金额，单位为元，两位小数。
 	SendPayDate         string           `json:"send_pay_date"`               // 本次交易打款给卖家的时间
 	ReceiptAmount       string           `json:"receipt_amount"`              // 实收金额，单位为元，两位小数
+	Receipt             gopay.Amount     `json:"-" amount:"receipt_amount"`   // 实收金额
 	StoreId             string           `json:"store_id"`                    // 商户门店编号
 	TerminalId          string           `json:"terminal_id"`                 // 商户机具终端编号
 	FundBillList        []*FundBill      `json:"fund_bill_list"`              // 交易支付使用的资金渠道
@@ -138,10 +283,7 @@ type TradeQueryResContent struct {
 	ExtInfos            string           `json:"ext_infos"`                   // 交易额外信息，特殊场景下与支付宝约定返回。
 }
 
-type TradeQueryRes struct {
-	Body TradeQueryResContent `json:"alipay_trade_query_response"`
-	Sign string               `json:"sign"`
-}
+type TradeQueryRes = kernel.Response[TradeQueryResContent]
 
 /**
  * 统一收单交易关闭
@@ -158,40 +300,65 @@ func (m *TradeClose) GetAliPayMethod() string {
 	return "alipay.trade.close"
 }
 
-type TradeCloseRes struct {
-	Body struct {
-		Code       string `json:"code"`         //网关返回码
-		Msg        string `json:"msg"`          //网关返回描述
-		SubCode    string `json:"sub_code"`     //业务返回码
-		SubMsg     string `json:"sub_msg"`      //业务返回码描述
-		TradeNo    string `json:"trade_no"`     //支付宝交易号
-		OutTradeNo string `json:"out_trade_no"` //创建交易传入的商户订单号
-	} `json:"alipay_trade_close_response"`
-	Sign string `json:"sign"`
+func (m *TradeClose) IsIdempotent() bool {
+	return true
+}
+
+type TradeCloseResContent struct {
+	Code       string `json:"code"`         //网关返回码
+	Msg        string `json:"msg"`          //网关返回描述
+	SubCode    string `json:"sub_code"`     //业务返回码
+	SubMsg     string `json:"sub_msg"`      //业务返回码描述
+	TradeNo    string `json:"trade_no"`     //支付宝交易号
+	OutTradeNo string `json:"out_trade_no"` //创建交易传入的商户订单号
 }
 
+type TradeCloseRes = kernel.Response[TradeCloseResContent]
+
 /**
  * 统一收单交易退款接口
  */
 type TradeRefund struct {
-	NotifyUrl      string   `json:"-"`                         //异步通知地址
-	ReturnUrl      string   `json:"-"`                         //支付返回地址
-	OutTradeNo     string   `json:"out_trade_no,omitempty"`    // 与 TradeNo 二选一
-	TradeNo        string   `json:"trade_no,omitempty"`        // 与 OutTradeNo 二选一
-	RefundAmount   string   `json:"refund_amount"`             // 需要退款的金额，该金额不能大于订单金额,单位为元，支持两位小数
-	RefundCurrency string   `json:"refund_currency,omitempty"` // 订单退款币种信息
-	RefundReason   string   `json:"refund_reason,omitempty"`   // 退款的原因说明
-	OutRequestNo   string   `json:"out_request_no,omitempty"`  // 标识一次退款请求，同一笔交易多次退款需要保证唯一，如需部分退款，则此参数必传。
-	OperatorId     string   `json:"operator_id,omitempty"`     // 商户的操作员编号
-	StoreId        string   `json:"store_id,omitempty"`        // 商户的门店编号
-	TerminalId     string   `json:"terminal_id,omitempty"`     // 商户的终端编号
-	QueryOptions   []string `json:"query_options,omitempty"`   //查询选项，商户通过上送该参数来定制同步需要额外返回的信息字段，数组格式。如：["refund_detail_item_list"]
+	NotifyUrl      string       `json:"-"`                         //异步通知地址
+	ReturnUrl      string       `json:"-"`                         //支付返回地址
+	OutTradeNo     string       `json:"out_trade_no,omitempty"`    // 与 TradeNo 二选一
+	TradeNo        string       `json:"trade_no,omitempty"`        // 与 OutTradeNo 二选一
+	RefundAmount   string       `json:"refund_amount"`             // 需要退款的金额，该金额不能大于订单金额,单位为元，支持两位小数
+	RefundCurrency string       `json:"refund_currency,omitempty"` // 订单退款币种信息
+	RefundReason   string       `json:"refund_reason,omitempty"`   // 退款的原因说明
+	OutRequestNo   string       `json:"out_request_no,omitempty"`  // 标识一次退款请求，同一笔交易多次退款需要保证唯一，如需部分退款，则此参数必传。
+	OperatorId     string       `json:"operator_id,omitempty"`     // 商户的操作员编号
+	StoreId        string       `json:"store_id,omitempty"`        // 商户的门店编号
+	TerminalId     string       `json:"terminal_id,omitempty"`     // 商户的终端编号
+	QueryOptions   []string     `json:"query_options,omitempty"`   //查询选项，商户通过上送该参数来定制同步需要额外返回的信息字段，数组格式。如：["refund_detail_item_list"]
+	Amount         gopay.Amount `json:"-"`                         // 退款金额，RefundAmount 为空时使用
 }
 
 func (m *TradeRefund) GetAliPayMethod() string {
 	return "alipay.trade.refund"
 }
 
+/**
+ * 退款金额，RefundAmount 为空时使用 Amount
+ */
+func (m *TradeRefund) refundAmount() string {
+	if m.RefundAmount == "" && m.Amount != 0 {
+		return m.Amount.String()
+	}
+	return m.RefundAmount
+}
+
+/**
+ * 使用 Amount 填充退款金额
+ */
+func (m *TradeRefund) setAmount() {
+	m.RefundAmount = m.refundAmount()
+}
+
+func (m *TradeRefund) IsIdempotent() bool {
+	return len(m.OutRequestNo) > 0
+}
+
 type RefundDetailItem struct {
 	FundChannel string `json:"fund_channel"` // 交易使用的资金渠道，详见 支付渠道列表
 	BankCode    string `json:"bank_code"`    //银行卡支付时的银行代码
@@ -200,31 +367,31 @@ type RefundDetailItem struct {
 	FundType    string `json:"fund_type"`    //渠道所使用的资金类型,目前只在资金渠道(fund_channel)是银行卡渠道(BANKCARD)的情况下才返回该信息(DEBIT_CARD:借记卡,CREDIT_CARD:信用卡,MIXED_CARD:借贷合一卡)
 }
 
-type TradeRefundRes struct {
-	Body struct {
-		Code                         string              `json:"code"`
-		Msg                          string              `json:"msg"`
-		SubCode                      string              `json:"sub_code"`
-		SubMsg                       string              `json:"sub_msg"`
-		TradeNo                      string              `json:"trade_no"`                        // 支付宝交易号
-		OutTradeNo                   string              `json:"out_trade_no"`                    // 商户订单号
-		BuyerLogonId                 string              `json:"buyer_logon_id"`                  // 用户的登录id
-		FundChange                   string              `json:"fund_change"`                     // 本次退款是否发生了资金变化
-		RefundFee                    string              `json:"refund_fee"`                      // 退款总金额
-		RefundCurrency               string              `json:"refund_currency"`                 // 退款币种信息
-		GmtRefundPay                 string              `json:"gmt_refund_pay"`                  // 退款支付时间
-		RefundDetailItemList         []*RefundDetailItem `json:"refund_detail_item_list"`         // 退款使用的资金渠道
-		StoreName                    string              `json:"store_name"`                      // 交易在支付时候的门店名称
-		BuyerUserId                  string              `json:"buyer_user_id"`                   // 买家在支付宝的用户id
-		RefundSettlementId           string              `json:"refund_settlement_id"`            // 退款清算编号，用于清算对账使用；只在银行间联交易场景下返回该信息；
-		PresentRefundBuyerAmount     string              `json:"present_refund_buyer_amount"`     // 本次退款金额中买家退款金额
-		PresentRefundDiscountAmount  string              `json:"present_refund_discount_amount"`  // 本次退款金额中平台优惠退款金额
-		PresentRefundMdiscountAmount string              `json:"present_refund_mdiscount_amount"` // 本次退款金额中商家优惠退款金额
-		HasDepositBack               string              `json:"has_deposit_back"`                //是否有银行卡冲退
-	} `json:"alipay_trade_refund_response"`
-	Sign string `json:"sign"`
+type TradeRefundResContent struct {
+	Code                         string              `json:"code"`
+	Msg                          string              `json:"msg"`
+	SubCode                      string              `json:"sub_code"`
+	SubMsg                       string              `json:"sub_msg"`
+	TradeNo                      string              `json:"trade_no"`                        // 支付宝交易号
+	OutTradeNo                   string              `json:"out_trade_no"`                    // 商户订单号
+	BuyerLogonId                 string              `json:"buyer_logon_id"`                  // 用户的登录id
+	FundChange                   string              `json:"fund_change"`                     // 本次退款是否发生了资金变化
+	RefundFee                    string              `json:"refund_fee"`                      // 退款总金额
+	Refunded                     gopay.Amount        `json:"-" amount:"refund_fee"`           // 退款总金额
+	RefundCurrency               string              `json:"refund_currency"`                 // 退款币种信息
+	GmtRefundPay                 string              `json:"gmt_refund_pay"`                  // 退款支付时间
+	RefundDetailItemList         []*RefundDetailItem `json:"refund_detail_item_list"`         // 退款使用的资金渠道
+	StoreName                    string              `json:"store_name"`                      // 交易在支付时候的门店名称
+	BuyerUserId                  string              `json:"buyer_user_id"`                   // 买家在支付宝的用户id
+	RefundSettlementId           string              `json:"refund_settlement_id"`            // 退款清算编号，用于清算对账使用；只在银行间联交易场景下返回该信息；
+	PresentRefundBuyerAmount     string              `json:"present_refund_buyer_amount"`     // 本次退款金额中买家退款金额
+	PresentRefundDiscountAmount  string              `json:"present_refund_discount_amount"`  // 本次退款金额中平台优惠退款金额
+	PresentRefundMdiscountAmount string              `json:"present_refund_mdiscount_amount"` // 本次退款金额中商家优惠退款金额
+	HasDepositBack               string              `json:"has_deposit_back"`                //是否有银行卡冲退
 }
 
+type TradeRefundRes = kernel.Response[TradeRefundResContent]
+
 /**
  * 交易退款查询接口
  */
@@ -242,55 +409,74 @@ func (m *RefundQuery) GetAliPayMethod() string {
 	return "alipay.trade.fastpay.refund.query"
 }
 
-type RefundQueryRes struct {
-	Body struct {
-		Code                 string              `json:"code"`
-		Msg                  string              `json:"msg"`
-		SubCode              string              `json:"sub_code"`
-		SubMsg               string              `json:"sub_msg"`
-		TradeNo              string              `json:"trade_no"`                // 支付宝交易号
-		OutTradeNo           string              `json:"out_trade_no"`            // 创建交易传入的商户订单号
-		OutRequestNo         string              `json:"out_request_no"`          // 本笔退款对应的退款请求号
-		RefundReason         string              `json:"refund_reason"`           // 发起退款时，传入的退款原因
-		TotalAmount          string              `json:"total_amount"`            // 发该笔退款所对应的交易的订单金额
-		RefundAmount         string              `json:"refund_amount"`           // 本次退款请求，对应的退款金额
-		RefundDetailItemList []*RefundDetailItem `json:"refund_detail_item_list"` // 本次退款使用的资金渠道；
-	} `json:"alipay_trade_fastpay_refund_query_response"`
-	Sign string `json:"sign"`
+func (m *RefundQuery) IsIdempotent() bool {
+	return true
+}
+
+type RefundQueryResContent struct {
+	Code                 string              `json:"code"`
+	Msg                  string              `json:"msg"`
+	SubCode              string              `json:"sub_code"`
+	SubMsg               string              `json:"sub_msg"`
+	TradeNo              string              `json:"trade_no"`                 // 支付宝交易号
+	OutTradeNo           string              `json:"out_trade_no"`             // 创建交易传入的商户订单号
+	OutRequestNo         string              `json:"out_request_no"`           // 本笔退款对应的退款请求号
+	RefundReason         string              `json:"refund_reason"`            // 发起退款时，传入的退款原因
+	TotalAmount          string              `json:"total_amount"`             // 发该笔退款所对应的交易的订单金额
+	Total                gopay.Amount        `json:"-" amount:"total_amount"`  // 交易的订单金额
+	RefundAmount         string              `json:"refund_amount"`            // 本次退款请求，对应的退款金额
+	Refund               gopay.Amount        `json:"-" amount:"refund_amount"` // 本次退款请求对应的退款金额
+	RefundDetailItemList []*RefundDetailItem `json:"refund_detail_item_list"`  // 本次退款使用的资金渠道；
 }
 
+type RefundQueryRes = kernel.Response[RefundQueryResContent]
+
+/**
+ * 异步通知的响应内容，未响应 success 时支付宝会重新发送通知
+ */
+const (
+	NotifySuccess = "success"
+	NotifyFailure = "failure"
+)
+
 /**
  * 异步通知参数
  */
 type Notify struct {
-	NotifyTime        string  `json:"notify_time"`         //通知的发送时间。格式为yyyy-MM-dd HH:mm:ss
-	NotifyType        string  `json:"notify_type"`         //通知的类型
-	NotifyId          string  `json:"notify_id"`           //通知校验ID
-	Charset           string  `json:"charset"`             //编码格式，如utf-8、gbk、gb2312等
-	Version           string  `json:"version"`             //调用的接口版本，固定为：1.0
-	SignType          string  `json:"sign_type"`           //签名算法类型，目前支持RSA2和RSA，推荐使用RSA2
-	Sign              string  `json:"sign"`                //请参考异步返回结果的验签
-	AuthAppId         string  `json:"auth_app_id"`         //授权方的appid，由于本接口暂不开放第三方应用授权，因此auth_app_id=app_id
-	TradeNo           string  `json:"trade_no"`            //支付宝交易号
-	AppId             string  `json:"app_id"`              //开发者APP_ID
-	OutTradeNo        string  `json:"out_trade_no"`        //商户订单号
-	OutBizNo          string  `json:"out_biz_no"`          //商户业务号
-	BuyerId           string  `json:"buyer_id"`            //买家支付宝用户号
-	SellerId          string  `json:"seller_id"`           //卖家支付宝用户号
-	TradeStatus       string  `json:"trade_status"`        //交易状态
-	TotalAmount       float64 `json:"total_amount"`        //订单金额
-	ReceiptAmount     float64 `json:"receipt_amount"`      //实收金额
-	InvoiceAmount     float64 `json:"invoice_amount"`      //开票金额
-	BuyerPayAmount    float64 `json:"buyer_pay_amount"`    //付款金额
-	PointAmount       float64 `json:"point_amount"`        //集分宝金额
-	RefundFee         float64 `json:"refund_fee"`          //总退款金额
-	Subject           string  `json:"subject"`             //订单标题
-	Body              string  `json:"body"`                //商品描述
-	GmtCreate         string  `json:"gmt_create"`          //交易创建时间
-	GmtPayment        string  `json:"gmt_payment"`         //交易付款时间
-	GmtRefund         string  `json:"gmt_refund"`          //交易退款时间
-	GmtClose          string  `json:"gmt_close"`           //交易结束时间
-	FundBillList      string  `json:"fund_bill_list"`      //支付金额信息
-	VoucherDetailList string  `json:"voucher_detail_list"` //优惠券信息
-	PassbackParams    string  `json:"passback_params"`     //回传参数
+	NotifyTime        string       `json:"notify_time"`                 //通知的发送时间。格式为yyyy-MM-dd HH:mm:ss
+	NotifyType        string       `json:"notify_type"`                 //通知的类型
+	NotifyId          string       `json:"notify_id"`                   //通知校验ID
+	Charset           string       `json:"charset"`                     //编码格式，如utf-8、gbk、gb2312等
+	Version           string       `json:"version"`                     //调用的接口版本，固定为：1.0
+	SignType          string       `json:"sign_type"`                   //签名算法类型，目前支持RSA2和RSA，推荐使用RSA2
+	Sign              string       `json:"sign"`                        //请参考异步返回结果的验签
+	AuthAppId         string       `json:"auth_app_id"`                 //授权方的appid，由于本接口暂不开放第三方应用授权，因此auth_app_id=app_id
+	TradeNo           string       `json:"trade_no"`                    //支付宝交易号
+	AppId             string       `json:"app_id"`                      //开发者APP_ID
+	OutTradeNo        string       `json:"out_trade_no"`                //商户订单号
+	OutBizNo          string       `json:"out_biz_no"`                  //商户业务号
+	BuyerId           string       `json:"buyer_id"`                    //买家支付宝用户号
+	SellerId          string       `json:"seller_id"`                   //卖家支付宝用户号
+	TradeStatus       string       `json:"trade_status"`                //交易状态
+	TotalAmount       float64      `json:"total_amount"`                //订单金额
+	Total             gopay.Amount `json:"-" amount:"total_amount"`     //订单金额，不经过浮点数转换
+	ReceiptAmount     float64      `json:"receipt_amount"`              //实收金额
+	Receipt           gopay.Amount `json:"-" amount:"receipt_amount"`   //实收金额，不经过浮点数转换
+	InvoiceAmount     float64      `json:"invoice_amount"`              //开票金额
+	Invoice           gopay.Amount `json:"-" amount:"invoice_amount"`   //开票金额，不经过浮点数转换
+	BuyerPayAmount    float64      `json:"buyer_pay_amount"`            //付款金额
+	BuyerPay          gopay.Amount `json:"-" amount:"buyer_pay_amount"` //付款金额，不经过浮点数转换
+	PointAmount       float64      `json:"point_amount"`                //集分宝金额
+	Point             gopay.Amount `json:"-" amount:"point_amount"`     //集分宝金额，不经过浮点数转换
+	RefundFee         float64      `json:"refund_fee"`                  //总退款金额
+	Refunded          gopay.Amount `json:"-" amount:"refund_fee"`       //总退款金额，不经过浮点数转换
+	Subject           string       `json:"subject"`                     //订单标题
+	Body              string       `json:"body"`                        //商品描述
+	GmtCreate         string       `json:"gmt_create"`                  //交易创建时间
+	GmtPayment        string       `json:"gmt_payment"`                 //交易付款时间
+	GmtRefund         string       `json:"gmt_refund"`                  //交易退款时间
+	GmtClose          string       `json:"gmt_close"`                   //交易结束时间
+	FundBillList      string       `json:"fund_bill_list"`              //支付金额信息
+	VoucherDetailList string       `json:"voucher_detail_list"`         //优惠券信息
+	PassbackParams    string       `json:"passback_params"`             //回传参数
 }
@@ -0,0 +1,144 @@
+package payment
+
+import (
+	"regexp"
+
+	"github.com/shinmigo/gopay"
+)
+
+var (
+	outTradeNoPattern     = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	timeoutExpressPattern = regexp.MustCompile(`^([1-9][0-9]*[mhd]|1c)$`)
+	timeExpirePattern     = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2} [0-9]{2}:[0-9]{2}(:[0-9]{2})?$`)
+)
+
+func (m *GoodsDetail) validate(validator *gopay.Validator) {
+	validator.Required("goods_detail.goods_id", m.GoodsId)
+	validator.MaxLength("goods_detail.goods_id", m.GoodsId, 64)
+	validator.Required("goods_detail.goods_name", m.GoodsName)
+	validator.MaxLength("goods_detail.goods_name", m.GoodsName, 256)
+	validator.Required("goods_detail.quantity", m.Quantity)
+	validator.Required("goods_detail.price", m.Price)
+	validator.Yuan("goods_detail.price", m.Price, 0, gopay.AliPayMaxAmount)
+}
+
+func (m *Trade) validate(validator *gopay.Validator) {
+	validator.Required("subject", m.Subject)
+	validator.MaxLength("subject", m.Subject, 256)
+	validator.Required("out_trade_no", m.OutTradeNo)
+	validator.MaxLength("out_trade_no", m.OutTradeNo, 64)
+	validator.Match("out_trade_no", m.OutTradeNo, outTradeNoPattern)
+	totalAmount := m.totalAmount()
+	validator.Required("total_amount", totalAmount)
+	validator.Yuan("total_amount", totalAmount, gopay.AliPayMinAmount, gopay.AliPayMaxAmount)
+	validator.Match("timeout_express", m.TimeoutExpress, timeoutExpressPattern)
+	validator.Match("time_expire", m.TimeExpire, timeExpirePattern)
+	validator.MaxLength("body", m.Body, 128)
+	validator.OneOf("goods_type", m.GoodsType, "0", "1")
+	validator.MaxLength("passback_params", m.PassbackParams, 512)
+	validator.MaxLength("store_id", m.StoreId, 32)
+	for _, goodsDetail := range m.GoodsDetail {
+		if goodsDetail != nil {
+			goodsDetail.validate(validator)
+		}
+	}
+	if m.AgreementParams != nil {
+		validator.Required("agreement_params.agreement_no", m.AgreementParams.AgreementNo)
+	}
+}
+
+/**
+ * 校验订单参数
+ */
+func (m *Trade) Validate() error {
+	validator := &gopay.Validator{}
+	m.validate(validator)
+	return validator.Err()
+}
+
+func (m *App) Validate() error {
+	return m.Trade.Validate()
+}
+
+func (m *Wap) Validate() error {
+	validator := &gopay.Validator{}
+	m.Trade.validate(validator)
+	validator.OneOf("output", m.Output, OutputForm, OutputUrl, OutputParams)
+	return validator.Err()
+}
+
+func (m *Page) Validate() error {
+	validator := &gopay.Validator{}
+	m.Trade.validate(validator)
+	validator.OneOf("qr_pay_mode", m.QrPayMode, QrPayModeSimple, QrPayModeFront, QrPayModeJump, QrPayModeMini, QrPayModeCustom)
+	if m.QrPayMode == QrPayModeCustom {
+		validator.Check(m.QrcodeWidth > 0, "qrcode_width", "is required when qr_pay_mode is 4")
+	}
+	validator.Check(m.QrcodeWidth >= 0, "qrcode_width", "must not be negative")
+	validator.OneOf("integration_type", m.IntegrationType, "ALIAPP", "PCWEB")
+	if m.IntegrationType == "ALIAPP" {
+		validator.Required("request_from_url", m.RequestFromUrl)
+	}
+	validator.OneOf("output", m.Output, OutputForm, OutputUrl, OutputParams)
+	return validator.Err()
+}
+
+func (m *TradePay) Validate() error {
+	validator := &gopay.Validator{}
+	m.Trade.validate(validator)
+	if len(m.AuthNo) == 0 {
+		validator.Required("scene", m.Scene)
+		validator.Required("auth_code", m.AuthCode)
+	} else {
+		validator.OneOf("product_code", m.ProductCode, "PRE_AUTH_ONLINE", "PRE_AUTH")
+	}
+	validator.OneOf("scene", m.Scene, "bar_code", "wave_code", "security_code")
+	validator.MaxLength("auth_code", m.AuthCode, 32)
+	validator.OneOf("auth_confirm_mode", m.AuthConfirmMode, "COMPLETE", "NOT_COMPLETE")
+	validator.MaxLength("terminal_id", m.TerminalId, 32)
+	validator.MaxLength("operator_id", m.OperatorId, 28)
+	return validator.Err()
+}
+
+func (m *TradeQuery) Validate() error {
+	validator := &gopay.Validator{}
+	validator.ExactlyOne("out_trade_no", m.OutTradeNo, "trade_no", m.TradeNo)
+	validator.MaxLength("out_trade_no", m.OutTradeNo, 64)
+	validator.MaxLength("trade_no", m.TradeNo, 64)
+	return validator.Err()
+}
+
+func (m *TradeClose) Validate() error {
+	validator := &gopay.Validator{}
+	validator.ExactlyOne("out_trade_no", m.OutTradeNo, "trade_no", m.TradeNo)
+	validator.MaxLength("out_trade_no", m.OutTradeNo, 64)
+	validator.MaxLength("trade_no", m.TradeNo, 64)
+	validator.MaxLength("operator_id", m.OperatorId, 28)
+	return validator.Err()
+}
+
+func (m *TradeRefund) Validate() error {
+	validator := &gopay.Validator{}
+	validator.ExactlyOne("out_trade_no", m.OutTradeNo, "trade_no", m.TradeNo)
+	validator.MaxLength("out_trade_no", m.OutTradeNo, 64)
+	validator.MaxLength("trade_no", m.TradeNo, 64)
+	refundAmount := m.refundAmount()
+	validator.Required("refund_amount", refundAmount)
+	validator.Yuan("refund_amount", refundAmount, gopay.AliPayMinAmount, gopay.AliPayMaxAmount)
+	validator.MaxLength("refund_reason", m.RefundReason, 256)
+	validator.MaxLength("out_request_no", m.OutRequestNo, 64)
+	validator.MaxLength("operator_id", m.OperatorId, 30)
+	validator.MaxLength("store_id", m.StoreId, 32)
+	validator.MaxLength("terminal_id", m.TerminalId, 32)
+	return validator.Err()
+}
+
+func (m *RefundQuery) Validate() error {
+	validator := &gopay.Validator{}
+	validator.ExactlyOne("out_trade_no", m.OutTradeNo, "trade_no", m.TradeNo)
+	validator.MaxLength("out_trade_no", m.OutTradeNo, 64)
+	validator.MaxLength("trade_no", m.TradeNo, 64)
+	validator.Required("out_request_no", m.OutRequestNo)
+	validator.MaxLength("out_request_no", m.OutRequestNo, 64)
+	return validator.Err()
+}
@@ -0,0 +1,210 @@
+package gopay
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	AliPayMinAmount Amount = 1           // 支付宝最小金额 0.01元
+	AliPayMaxAmount Amount = 10000000000 // 支付宝最大金额 100000000元
+	WxPayMinAmount  Amount = 1           // 微信支付最小金额 1分
+)
+
+var (
+	ErrAmountFormat     = errors.New("gopay: invalid amount format")
+	ErrAmountPrecision  = errors.New("gopay: amount supports at most two decimal places")
+	ErrAmountOutOfRange = errors.New("gopay: amount out of range")
+)
+
+/**
+ * 金额，单位为分，可直接进行加减与比较
+ * JSON 编码为支付宝使用的元字符串，如 "0.01"；XML 编码为微信支付使用的分，如 1
+ */
+type Amount int64
+
+/**
+ * 分转换为金额
+ */
+func Fen(fen int64) Amount {
+	return Amount(fen)
+}
+
+/**
+ * 解析以元为单位的十进制字符串，如 "100.5"，最多两位小数，不经过浮点数转换
+ */
+func ParseYuan(yuan string) (Amount, error) {
+	yuan = strings.TrimSpace(yuan)
+	negative := strings.HasPrefix(yuan, "-")
+	if negative {
+		yuan = yuan[1:]
+	}
+	integerPart, decimalPart := yuan, ""
+	if index := strings.IndexByte(yuan, '.'); index >= 0 {
+		integerPart, decimalPart = yuan[:index], yuan[index+1:]
+	}
+	if integerPart == "" || !isDigits(integerPart) || !isDigits(decimalPart) {
+		return 0, fmt.Errorf("%w: %q", ErrAmountFormat, yuan)
+	}
+	decimalPart = strings.TrimRight(decimalPart, "0")
+	if len(decimalPart) > 2 {
+		return 0, fmt.Errorf("%w: %q", ErrAmountPrecision, yuan)
+	}
+	decimalPart += strings.Repeat("0", 2-len(decimalPart))
+
+	integer, err := strconv.ParseInt(integerPart, 10, 64)
+	if err != nil || integer > math.MaxInt64/100-1 {
+		return 0, fmt.Errorf("%w: %q", ErrAmountOutOfRange, yuan)
+	}
+	decimal, _ := strconv.ParseInt(decimalPart, 10, 64)
+	amount := Amount(integer*100 + decimal)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+/**
+ * 以元为单位的浮点数转换为金额，按分四舍五入，用于支付宝异步通知中的 float64 金额
+ */
+func YuanFloat(yuan float64) Amount {
+	return Amount(math.Round(yuan * 100))
+}
+
+/**
+ * 金额 分
+ */
+func (m Amount) Fen() int64 {
+	return int64(m)
+}
+
+/**
+ * 以元为单位的字符串，固定两位小数，如 "0.01"
+ */
+func (m Amount) String() string {
+	fen := int64(m)
+	sign := ""
+	if fen < 0 {
+		sign, fen = "-", -fen
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, fen/100, fen%100)
+}
+
+/**
+ * 加法
+ */
+func (m Amount) Add(n Amount) Amount {
+	return m + n
+}
+
+/**
+ * 减法，结果为负数时返回错误，如计算剩余可退款金额
+ */
+func (m Amount) Sub(n Amount) (Amount, error) {
+	if n > m {
+		return 0, fmt.Errorf("%w: %s - %s", ErrAmountOutOfRange, m, n)
+	}
+	return m - n, nil
+}
+
+/**
+ * 校验是否在 [min, max] 范围内，max 为0时不限制上限
+ */
+func (m Amount) Validate(min, max Amount) error {
+	if m < min || (max > 0 && m > max) {
+		return fmt.Errorf("%w: %s", ErrAmountOutOfRange, m)
+	}
+	return nil
+}
+
+/**
+ * 校验支付宝金额范围 [0.01, 100000000]
+ */
+func (m Amount) ValidateAliPay() error {
+	return m.Validate(AliPayMinAmount, AliPayMaxAmount)
+}
+
+/**
+ * 校验微信支付金额，最少1分
+ */
+func (m Amount) ValidateWxPay() error {
+	return m.Validate(WxPayMinAmount, 0)
+}
+
+func (m Amount) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Amount) UnmarshalText(text []byte) error {
+	amount, err := ParseYuan(string(text))
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+/**
+ * JSON 编码为元字符串
+ */
+func (m Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+/**
+ * 支持元字符串与数字两种格式
+ */
+func (m *Amount) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var yuan string
+		if err := json.Unmarshal(data, &yuan); err != nil {
+			return err
+		}
+		data = []byte(yuan)
+	}
+	return m.UnmarshalText(data)
+}
+
+/**
+ * XML 编码为分
+ */
+func (m Amount) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error {
+	return encoder.EncodeElement(int64(m), start)
+}
+
+func (m *Amount) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	var fen string
+	if err := decoder.DecodeElement(&fen, &start); err != nil {
+		return err
+	}
+	fen = strings.TrimSpace(fen)
+	if fen == "" {
+		*m = 0
+		return nil
+	}
+	value, err := strconv.ParseInt(fen, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrAmountFormat, fen)
+	}
+	*m = Amount(value)
+	return nil
+}
+
+func isDigits(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
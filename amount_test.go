@@ -0,0 +1,184 @@
+package gopay
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"testing"
+)
+
+func TestParseYuan(t *testing.T) {
+	cases := []struct {
+		yuan   string
+		amount Amount
+		err    error
+	}{
+		{"0.01", 1, nil},
+		{"1", 100, nil},
+		{"100.5", 10050, nil},
+		{"100.50", 10050, nil},
+		{"100.500", 10050, nil},
+		{" 12.34 ", 1234, nil},
+		{"0.1", 10, nil},
+		{"-3.20", -320, nil},
+		{"100000000.00", 10000000000, nil},
+		{"0.001", 0, ErrAmountPrecision},
+		{"1.234", 0, ErrAmountPrecision},
+		{"", 0, ErrAmountFormat},
+		{".5", 0, ErrAmountFormat},
+		{"1.2.3", 0, ErrAmountFormat},
+		{"1e2", 0, ErrAmountFormat},
+		{"abc", 0, ErrAmountFormat},
+		{"+1", 0, ErrAmountFormat},
+		{"99999999999999999999", 0, ErrAmountOutOfRange},
+	}
+	for _, item := range cases {
+		amount, err := ParseYuan(item.yuan)
+		if !errors.Is(err, item.err) {
+			t.Errorf("ParseYuan(%q) err = %v, want %v", item.yuan, err, item.err)
+			continue
+		}
+		if amount != item.amount {
+			t.Errorf("ParseYuan(%q) = %d, want %d", item.yuan, amount, item.amount)
+		}
+	}
+}
+
+func TestAmountFenAndString(t *testing.T) {
+	cases := []struct {
+		amount Amount
+		fen    int64
+		yuan   string
+	}{
+		{Fen(0), 0, "0.00"},
+		{Fen(1), 1, "0.01"},
+		{Fen(10), 10, "0.10"},
+		{Fen(10050), 10050, "100.50"},
+		{Fen(-320), -320, "-3.20"},
+		{YuanFloat(0.07), 7, "0.07"},
+		{YuanFloat(19.99), 1999, "19.99"},
+	}
+	for _, item := range cases {
+		if item.amount.Fen() != item.fen {
+			t.Errorf("Fen() = %d, want %d", item.amount.Fen(), item.fen)
+		}
+		if item.amount.String() != item.yuan {
+			t.Errorf("String() = %q, want %q", item.amount.String(), item.yuan)
+		}
+	}
+}
+
+func TestAmountSub(t *testing.T) {
+	cases := []struct {
+		amount, sub, result Amount
+		err                 error
+	}{
+		{Fen(1000), Fen(350), Fen(650), nil},
+		{Fen(1000), Fen(1000), Fen(0), nil},
+		{Fen(1000), Fen(1001), Fen(0), ErrAmountOutOfRange},
+	}
+	for _, item := range cases {
+		result, err := item.amount.Sub(item.sub)
+		if !errors.Is(err, item.err) || result != item.result {
+			t.Errorf("%s - %s = %s, %v, want %s, %v", item.amount, item.sub, result, err, item.result, item.err)
+		}
+	}
+	if sum := Fen(650).Add(Fen(350)); sum != Fen(1000) {
+		t.Errorf("Add = %s, want 10.00", sum)
+	}
+}
+
+func TestAmountValidate(t *testing.T) {
+	cases := []struct {
+		amount Amount
+		aliPay bool
+		wxPay  bool
+	}{
+		{Fen(0), false, false},
+		{Fen(1), true, true},
+		{AliPayMaxAmount, true, true},
+		{AliPayMaxAmount + 1, false, true},
+		{Fen(-1), false, false},
+	}
+	for _, item := range cases {
+		if err := item.amount.ValidateAliPay(); (err == nil) != item.aliPay {
+			t.Errorf("ValidateAliPay(%s) = %v", item.amount, err)
+		}
+		if err := item.amount.ValidateWxPay(); (err == nil) != item.wxPay {
+			t.Errorf("ValidateWxPay(%s) = %v", item.amount, err)
+		}
+	}
+}
+
+func TestAmountText(t *testing.T) {
+	text, err := Fen(10050).MarshalText()
+	if err != nil || string(text) != "100.50" {
+		t.Errorf("MarshalText = %q, %v", text, err)
+	}
+	var amount Amount
+	if err = amount.UnmarshalText([]byte("0.01")); err != nil || amount != Fen(1) {
+		t.Errorf("UnmarshalText = %d, %v", amount, err)
+	}
+	if err = amount.UnmarshalText([]byte("0.001")); !errors.Is(err, ErrAmountPrecision) {
+		t.Errorf("UnmarshalText(0.001) err = %v", err)
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	type content struct {
+		TotalAmount Amount  `json:"total_amount"`
+		Refund      *Amount `json:"refund,omitempty"`
+	}
+	data, err := json.Marshal(content{TotalAmount: Fen(10050)})
+	if err != nil || string(data) != `{"total_amount":"100.50"}` {
+		t.Errorf("Marshal = %s, %v", data, err)
+	}
+
+	cases := []struct {
+		data   string
+		amount Amount
+		ok     bool
+	}{
+		{`{"total_amount":"100.50"}`, 10050, true},
+		{`{"total_amount":100.5}`, 10050, true},
+		{`{"total_amount":null}`, 0, true},
+		{`{"total_amount":"0.001"}`, 0, false},
+		{`{"total_amount":"abc"}`, 0, false},
+	}
+	for _, item := range cases {
+		result := content{}
+		err = json.Unmarshal([]byte(item.data), &result)
+		if (err == nil) != item.ok || (item.ok && result.TotalAmount != item.amount) {
+			t.Errorf("Unmarshal(%s) = %d, %v", item.data, result.TotalAmount, err)
+		}
+	}
+}
+
+func TestAmountXML(t *testing.T) {
+	type content struct {
+		XMLName  xml.Name `xml:"xml"`
+		TotalFee Amount   `xml:"total_fee"`
+	}
+	data, err := xml.Marshal(content{TotalFee: Fen(10050)})
+	if err != nil || string(data) != `<xml><total_fee>10050</total_fee></xml>` {
+		t.Errorf("Marshal = %s, %v", data, err)
+	}
+
+	cases := []struct {
+		data   string
+		amount Amount
+		ok     bool
+	}{
+		{`<xml><total_fee>10050</total_fee></xml>`, 10050, true},
+		{`<xml><total_fee><![CDATA[1]]></total_fee></xml>`, 1, true},
+		{`<xml><total_fee></total_fee></xml>`, 0, true},
+		{`<xml><total_fee>1.00</total_fee></xml>`, 0, false},
+	}
+	for _, item := range cases {
+		result := content{}
+		err = xml.Unmarshal([]byte(item.data), &result)
+		if (err == nil) != item.ok || (item.ok && result.TotalFee != item.amount) {
+			t.Errorf("Unmarshal(%s) = %d, %v", item.data, result.TotalFee, err)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/shinmigo/gopay"
+	alipay "github.com/shinmigo/gopay/alipay/kernel"
+	"github.com/shinmigo/gopay/registry"
+	wxpay "github.com/shinmigo/gopay/wxpay/kernel"
+)
+
+const (
+	EnvProd    = "prod"    // 生产环境
+	EnvSandbox = "sandbox" // 沙箱环境
+)
+
+/**
+ * 多商户支付配置，可从JSON、YAML文件或环境变量加载
+ */
+type Config struct {
+	AliPay []*AliPay `json:"alipay" yaml:"alipay"` // 支付宝应用
+	WxPay  []*WxPay  `json:"wxpay" yaml:"wxpay"`   // 微信支付商户
+}
+
+/**
+ * 支付宝应用配置，密钥可直接填写内容或填写文件路径
+ */
+type AliPay struct {
+	AppId               string   `json:"app_id" yaml:"app_id"`                                 // 支付宝应用APPID
+	PrivateKey          string   `json:"private_key" yaml:"private_key"`                       // 商户应用私钥内容，与 PrivateKeyPath 二选一
+	PrivateKeyPath      string   `json:"private_key_path" yaml:"private_key_path"`             // 商户应用私钥路径
+	AliPayPublicKey     string   `json:"alipay_public_key" yaml:"alipay_public_key"`           // 支付宝公钥内容，公钥模式下与 AliPayPublicKeyPath 二选一
+	AliPayPublicKeyPath string   `json:"alipay_public_key_path" yaml:"alipay_public_key_path"` // 支付宝公钥路径
+	AppCertPath         string   `json:"app_cert_path" yaml:"app_cert_path"`                   // 商户应用公钥证书路径，设置后使用公钥证书模式
+	AliPayCertPath      string   `json:"alipay_cert_path" yaml:"alipay_cert_path"`             // 支付宝公钥证书路径
+	AliPayRootCertPath  string   `json:"alipay_root_cert_path" yaml:"alipay_root_cert_path"`   // 支付宝根证书路径
+	SignType            string   `json:"sign_type" yaml:"sign_type"`                           // 签名类型 RSA2、RSA，默认RSA
+	Env                 string   `json:"env" yaml:"env"`                                       // 环境 prod、sandbox，默认sandbox
+	NotifyUrl           string   `json:"notify_url" yaml:"notify_url"`                         // 异步通知地址
+	EncryptKey          string   `json:"encrypt_key" yaml:"encrypt_key"`                       // AES密钥（可选）
+	LocalTimeZone       string   `json:"local_time_zone" yaml:"local_time_zone"`               // 时区，默认Asia/Shanghai
+	GatewayHost         string   `json:"gateway_host" yaml:"gateway_host"`                     // 自定义网关地址（可选）
+	Timeout             Duration `json:"timeout" yaml:"timeout"`                               // 网关请求超时时间，如 10s
+}
+
+/**
+ * 微信支付商户配置，证书可直接填写内容或填写文件路径
+ */
+type WxPay struct {
+	AppId     string   `json:"app_id" yaml:"app_id"`         // 应用ID
+	MchId     string   `json:"mch_id" yaml:"mch_id"`         // 商户号
+	Md5Key    string   `json:"md5_key" yaml:"md5_key"`       // API密钥
+	Cert      string   `json:"cert" yaml:"cert"`             // 商户API证书内容，与 CertPath 二选一（可选）
+	Key       string   `json:"key" yaml:"key"`               // 商户API证书私钥内容（可选）
+	CertPath  string   `json:"cert_path" yaml:"cert_path"`   // 商户API证书路径（可选）
+	KeyPath   string   `json:"key_path" yaml:"key_path"`     // 商户API证书私钥路径（可选）
+	Env       string   `json:"env" yaml:"env"`               // 环境 prod、sandbox，默认sandbox
+	NotifyUrl string   `json:"notify_url" yaml:"notify_url"` // 默认异步通知地址
+	Endpoints []string `json:"endpoints" yaml:"endpoints"`   // 自定义网关地址（可选）
+	Timeout   Duration `json:"timeout" yaml:"timeout"`       // 网关请求超时时间，如 10s
+	SignType  string   `json:"sign_type" yaml:"sign_type"`   // 签名类型 MD5、HMAC-SHA256，默认MD5
+	SubMchId  string   `json:"sub_mch_id" yaml:"sub_mch_id"` // 服务商模式的子商户号（可选）
+	SubAppId  string   `json:"sub_appid" yaml:"sub_appid"`   // 服务商模式的子商户应用ID（可选）
+}
+
+/**
+ * 时长，支持 10s、1m30s 格式
+ */
+type Duration time.Duration
+
+func (m Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(m).String()), nil
+}
+
+func (m *Duration) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*m = 0
+		return nil
+	}
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*m = Duration(duration)
+	return nil
+}
+
+/**
+ * 校验配置，返回的 *gopay.ValidationError 包含所有不合法的配置项
+ */
+func (m *Config) Validate() error {
+	validator := &gopay.Validator{}
+	appIds := make(map[string]bool, len(m.AliPay))
+	for i, item := range m.AliPay {
+		prefix := fmt.Sprintf("alipay[%d].", i)
+		if item == nil {
+			validator.Fail(prefix[:len(prefix)-1], "is empty")
+			continue
+		}
+		item.validate(validator, prefix)
+		validator.Check(item.AppId == "" || !appIds[item.AppId], prefix+"app_id", "is duplicated")
+		appIds[item.AppId] = true
+	}
+	mchIds := make(map[string]bool, len(m.WxPay))
+	for i, item := range m.WxPay {
+		prefix := fmt.Sprintf("wxpay[%d].", i)
+		if item == nil {
+			validator.Fail(prefix[:len(prefix)-1], "is empty")
+			continue
+		}
+		item.validate(validator, prefix)
+		validator.Check(item.MchId == "" || !mchIds[item.MchId], prefix+"mch_id", "is duplicated")
+		mchIds[item.MchId] = true
+	}
+	return validator.Err()
+}
+
+func (m *AliPay) validate(validator *gopay.Validator, prefix string) {
+	validator.Required(prefix+"app_id", m.AppId)
+	validator.ExactlyOne(prefix+"private_key", m.PrivateKey, prefix+"private_key_path", m.PrivateKeyPath)
+	if len(m.AppCertPath) > 0 {
+		validator.Required(prefix+"alipay_cert_path", m.AliPayCertPath)
+		validator.Required(prefix+"alipay_root_cert_path", m.AliPayRootCertPath)
+	} else {
+		validator.ExactlyOne(prefix+"alipay_public_key", m.AliPayPublicKey, prefix+"alipay_public_key_path", m.AliPayPublicKeyPath)
+	}
+	validator.OneOf(prefix+"sign_type", m.SignType, "RSA2", "RSA")
+	validator.OneOf(prefix+"env", m.Env, EnvProd, EnvSandbox)
+	validateUrl(validator, prefix+"notify_url", m.NotifyUrl)
+	validateUrl(validator, prefix+"gateway_host", m.GatewayHost)
+	if len(m.LocalTimeZone) > 0 {
+		_, err := time.LoadLocation(m.LocalTimeZone)
+		validator.Check(err == nil, prefix+"local_time_zone", "is not a valid time zone")
+	}
+	validator.Check(m.Timeout >= 0, prefix+"timeout", "must not be negative")
+}
+
+func (m *WxPay) validate(validator *gopay.Validator, prefix string) {
+	validator.Required(prefix+"app_id", m.AppId)
+	validator.Required(prefix+"mch_id", m.MchId)
+	validator.Required(prefix+"md5_key", m.Md5Key)
+	validator.Check(m.Md5Key == "" || len(m.Md5Key) == 32, prefix+"md5_key", "must be 32 characters")
+	validator.OneOf(prefix+"sign_type", m.SignType, "MD5", "HMAC-SHA256")
+	validator.Check(m.SignType != "HMAC-SHA256" || m.Env == EnvProd, prefix+"sign_type", "sandbox only supports MD5")
+	validator.Check((m.Cert == "") == (m.Key == ""), prefix+"cert|key", "must be set together")
+	validator.Check((m.CertPath == "") == (m.KeyPath == ""), prefix+"cert_path|key_path", "must be set together")
+	validator.Check(m.Cert == "" || m.CertPath == "", prefix+"cert|cert_path", "are mutually exclusive")
+	validator.Check(m.SubAppId == "" || m.SubMchId != "", prefix+"sub_appid", "requires sub_mch_id")
+	validator.OneOf(prefix+"env", m.Env, EnvProd, EnvSandbox)
+	validateUrl(validator, prefix+"notify_url", m.NotifyUrl)
+	for j, endpoint := range m.Endpoints {
+		validateUrl(validator, fmt.Sprintf("%sendpoints[%d]", prefix, j), endpoint)
+	}
+	validator.Check(m.Timeout >= 0, prefix+"timeout", "must not be negative")
+}
+
+func validateUrl(validator *gopay.Validator, field, value string) {
+	if value == "" {
+		return
+	}
+	parsedUrl, err := url.Parse(value)
+	validator.Check(err == nil && (parsedUrl.Scheme == "http" || parsedUrl.Scheme == "https") && parsedUrl.Host != "", field, "is not a valid http(s) URL")
+}
+
+/**
+ * 转换为支付宝客户端配置
+ */
+func (m *AliPay) KernelConfig() *alipay.Config {
+	return &alipay.Config{
+		AppId:                  m.AppId,
+		AliPayPublicKeyPath:    m.AliPayPublicKeyPath,
+		MerchantPrivateKeyPath: m.PrivateKeyPath,
+		AliPayCertPath:         m.AliPayCertPath,
+		AliPayRootCertPath:     m.AliPayRootCertPath,
+		MerchantCertPath:       m.AppCertPath,
+		NotifyUrl:              m.NotifyUrl,
+		EncryptKey:             m.EncryptKey,
+		IsProd:                 m.Env == EnvProd,
+		LocalTimeZone:          m.LocalTimeZone,
+		SignType:               m.SignType,
+		GatewayHost:            m.GatewayHost,
+		MerchantPrivateKey:     m.PrivateKey,
+		AliPayPublicKey:        m.AliPayPublicKey,
+		Timeout:                time.Duration(m.Timeout),
+	}
+}
+
+/**
+ * 转换为微信支付客户端配置
+ */
+func (m *WxPay) KernelConfig() *wxpay.Config {
+	return &wxpay.Config{
+		AppId:     m.AppId,
+		MchId:     m.MchId,
+		Md5Key:    m.Md5Key,
+		IsProd:    m.Env == EnvProd,
+		CertPath:  m.CertPath,
+		KeyPath:   m.KeyPath,
+		Endpoints: m.Endpoints,
+		Cert:      m.Cert,
+		Key:       m.Key,
+		NotifyUrl: m.NotifyUrl,
+		Timeout:   time.Duration(m.Timeout),
+		SignType:  m.SignType,
+		SubMchId:  m.SubMchId,
+		SubAppId:  m.SubAppId,
+	}
+}
+
+/**
+ * 创建支付宝客户端
+ */
+func (m *AliPay) NewClient() (*alipay.AliPayClient, error) {
+	return alipay.NewAliPayClient(m.KernelConfig())
+}
+
+/**
+ * 创建微信支付客户端
+ */
+func (m *WxPay) NewClient() (*wxpay.WxClient, error) {
+	return wxpay.NewWxClientWithConfig(m.KernelConfig())
+}
+
+/**
+ * 校验配置后替换注册表中的所有客户端
+ */
+func (m *Config) Reload(clientRegistry *registry.Registry) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	aliPayConfigs := make([]*alipay.Config, 0, len(m.AliPay))
+	for _, item := range m.AliPay {
+		aliPayConfigs = append(aliPayConfigs, item.KernelConfig())
+	}
+	wxPayConfigs := make([]*wxpay.Config, 0, len(m.WxPay))
+	for _, item := range m.WxPay {
+		wxPayConfigs = append(wxPayConfigs, item.KernelConfig())
+	}
+	return clientRegistry.Reload(aliPayConfigs, wxPayConfigs)
+}
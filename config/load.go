@@ -0,0 +1,147 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/**
+ * 按文件扩展名加载配置，支持 .json、.yaml、.yml，加载后校验
+ */
+func LoadFile(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return LoadJSON(data)
+	case ".yaml", ".yml":
+		return LoadYAML(data)
+	}
+	return nil, fmt.Errorf("config: unsupported file extension %q, use .json, .yaml or .yml", filepath.Ext(path))
+}
+
+/**
+ * 加载JSON格式的配置，未知字段视为错误，加载后校验
+ */
+func LoadJSON(data []byte) (*Config, error) {
+	config := &Config{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("config: parse json: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+/**
+ * 加载YAML格式的配置，未知字段视为错误，加载后校验
+ */
+func LoadYAML(data []byte) (*Config, error) {
+	config := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("config: parse yaml: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+/**
+ * 从环境变量加载配置，加载后校验
+ * 变量名为 前缀_渠道_序号_字段，序号从0开始连续编号，如 GOPAY_ALIPAY_0_APP_ID、GOPAY_WXPAY_1_MCH_ID
+ * endpoints 使用逗号分隔多个地址
+ */
+func LoadEnv(prefix string) (*Config, error) {
+	if prefix == "" {
+		prefix = "GOPAY"
+	}
+	environ := make(map[string]string)
+	for _, item := range os.Environ() {
+		if index := strings.IndexByte(item, '='); index > 0 {
+			environ[item[:index]] = item[index+1:]
+		}
+	}
+
+	config := &Config{}
+	for i := 0; ; i++ {
+		item := &AliPay{}
+		found, err := loadEnvFields(environ, fmt.Sprintf("%s_ALIPAY_%d_", prefix, i), item)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			break
+		}
+		config.AliPay = append(config.AliPay, item)
+	}
+	for i := 0; ; i++ {
+		item := &WxPay{}
+		found, err := loadEnvFields(environ, fmt.Sprintf("%s_WXPAY_%d_", prefix, i), item)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			break
+		}
+		config.WxPay = append(config.WxPay, item)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+/**
+ * 按 json 标签的大写形式读取环境变量，返回是否读取到任一字段
+ */
+func loadEnvFields(environ map[string]string, prefix string, target interface{}) (bool, error) {
+	found := false
+	value := reflect.ValueOf(target).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := prefix + strings.ToUpper(strings.Split(field.Tag.Get("json"), ",")[0])
+		item, ok := environ[name]
+		if !ok {
+			continue
+		}
+		found = true
+
+		fieldValue := value.Field(i)
+		if unmarshaler, ok := fieldValue.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := unmarshaler.UnmarshalText([]byte(item)); err != nil {
+				return false, fmt.Errorf("config: parse %s: %w", name, err)
+			}
+			continue
+		}
+		switch fieldValue.Kind() {
+		case reflect.String:
+			fieldValue.SetString(item)
+		case reflect.Slice:
+			var items []string
+			for _, part := range strings.Split(item, ",") {
+				if part = strings.TrimSpace(part); len(part) > 0 {
+					items = append(items, part)
+				}
+			}
+			fieldValue.Set(reflect.ValueOf(items))
+		}
+	}
+	return found, nil
+}
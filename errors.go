@@ -0,0 +1,48 @@
+package gopay
+
+import (
+	"errors"
+	"fmt"
+)
+
+/**
+ * 常见错误分类，可使用 errors.Is 判断支付渠道返回的错误
+ */
+var (
+	ErrOrderPaid           = errors.New("gopay: order paid")
+	ErrOrderNotExist       = errors.New("gopay: order not exist")
+	ErrInsufficientBalance = errors.New("gopay: insufficient balance")
+	ErrSignatureInvalid    = errors.New("gopay: signature invalid")
+	ErrSystemBusy          = errors.New("gopay: system busy")
+)
+
+const (
+	ProviderAliPay = "alipay"
+	ProviderWxPay  = "wxpay"
+)
+
+/**
+ * 支付渠道返回的错误
+ */
+type Error struct {
+	Provider string // 支付渠道 alipay、wxpay
+	Code     string // 网关返回码，支付宝为code，微信支付为return_code或result_code
+	SubCode  string // 业务返回码，支付宝为sub_code，微信支付为err_code
+	Message  string // 错误描述
+	Raw      []byte // 原始响应
+	Kind     error  // 错误分类，为上述 Err 开头的错误之一，无法分类时为空
+}
+
+func (m *Error) Error() string {
+	if m.SubCode != "" {
+		return fmt.Sprintf("%s: %s %s - %s", m.Provider, m.Code, m.SubCode, m.Message)
+	}
+	if m.Code != "" {
+		return fmt.Sprintf("%s: %s - %s", m.Provider, m.Code, m.Message)
+	}
+	return fmt.Sprintf("%s: %s", m.Provider, m.Message)
+}
+
+func (m *Error) Unwrap() error {
+	return m.Kind
+}
@@ -0,0 +1,170 @@
+package gopay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"time"
+)
+
+/**
+ * 一次网关调用，中间件在调用前可读取请求参数，调用后可读取响应、耗时与错误
+ */
+type Call struct {
+	Context  context.Context // 调用上下文，中间件可替换为携带追踪信息的上下文
+	Provider string          // 支付渠道 alipay、wxpay
+	Api      string          // 支付宝为接口名称，如 alipay.trade.query；微信支付为接口路径，如 pay/orderquery
+	Method   string          // HTTP请求方法
+	Params   url.Values      // 签名后的请求参数，不可修改
+	Response []byte          // 原始响应
+	Attempts int             // 请求次数，包含重试
+	Latency  time.Duration   // 网关调用耗时，包含重试
+}
+
+/**
+ * 处理网关调用
+ */
+type Handler func(call *Call) error
+
+/**
+ * 中间件，包装下一个处理函数
+ */
+type Middleware func(next Handler) Handler
+
+/**
+ * 按顺序组合中间件，第一个中间件在最外层
+ */
+func Chain(middlewares []Middleware, handler Handler) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+/**
+ * 调用结果，用于日志与监控
+ */
+const (
+	OutcomeSuccess       = "success"        // 调用成功
+	OutcomeBusinessError = "business_error" // 支付渠道返回了错误
+	OutcomeInvalidParam  = "invalid_param"  // 请求参数校验失败
+	OutcomeError         = "error"          // 网络等其它错误
+)
+
+/**
+ * 调用结果分类
+ */
+func Outcome(err error) string {
+	if err == nil {
+		return OutcomeSuccess
+	}
+	if errors.Is(err, ErrInvalidParam) {
+		return OutcomeInvalidParam
+	}
+	var payErr *Error
+	if errors.As(err, &payErr) {
+		return OutcomeBusinessError
+	}
+	return OutcomeError
+}
+
+/**
+ * 结构化日志
+ */
+type Logger interface {
+	Log(ctx context.Context, message string, fields map[string]interface{})
+}
+
+/**
+ * 使用标准库 log.Logger 输出JSON格式的字段
+ */
+type StdLogger struct {
+	Logger *log.Logger
+}
+
+func (m *StdLogger) Log(ctx context.Context, message string, fields map[string]interface{}) {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(fields); err != nil {
+		m.Logger.Printf("%s %v", message, fields)
+		return
+	}
+	m.Logger.Printf("%s %s", message, bytes.TrimSpace(buffer.Bytes()))
+}
+
+/**
+ * 日志中间件，记录接口、脱敏后的请求参数与响应、耗时与错误
+ */
+func LoggingMiddleware(logger Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(call *Call) error {
+			err := next(call)
+			fields := map[string]interface{}{
+				"provider": call.Provider,
+				"api":      call.Api,
+				"method":   call.Method,
+				"params":   RedactValues(call.Params).Encode(),
+				"response": string(Redact(call.Response)),
+				"attempts": call.Attempts,
+				"latency":  call.Latency.String(),
+				"outcome":  Outcome(err),
+			}
+			if err != nil {
+				fields["error"] = err.Error()
+			}
+			logger.Log(call.Context, "gopay call", fields)
+			return err
+		}
+	}
+}
+
+/**
+ * 监控指标
+ */
+type Metrics interface {
+	IncCounter(name string, labels map[string]string)
+	ObserveHistogram(name string, value float64, labels map[string]string)
+}
+
+const (
+	MetricRequestsTotal   = "gopay_requests_total"           // 调用次数
+	MetricRequestDuration = "gopay_request_duration_seconds" // 调用耗时 秒
+)
+
+/**
+ * 监控中间件，按支付渠道、接口与调用结果统计调用次数与耗时
+ */
+func MetricsMiddleware(metrics Metrics) Middleware {
+	return func(next Handler) Handler {
+		return func(call *Call) error {
+			err := next(call)
+			labels := map[string]string{
+				"provider": call.Provider,
+				"api":      call.Api,
+				"outcome":  Outcome(err),
+			}
+			metrics.IncCounter(MetricRequestsTotal, labels)
+			metrics.ObserveHistogram(MetricRequestDuration, call.Latency.Seconds(), labels)
+			return err
+		}
+	}
+}
+
+/**
+ * 追踪中间件，start 创建span并返回携带span的上下文，调用结束时执行返回的 end
+ */
+func TraceMiddleware(start func(ctx context.Context, call *Call) (context.Context, func(err error))) Middleware {
+	return func(next Handler) Handler {
+		return func(call *Call) error {
+			ctx, end := start(call.Context, call)
+			call.Context = ctx
+			err := next(call)
+			end(err)
+			return err
+		}
+	}
+}
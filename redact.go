@@ -0,0 +1,120 @@
+package gopay
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const RedactedValue = "***"
+
+/**
+ * 日志中需要脱敏的参数：签名、密钥、买家标识与授权信息，可按需补充
+ */
+var RedactKeys = map[string]bool{
+	"sign":            true,
+	"paySign":         true,
+	"sandbox_signkey": true,
+	"key":             true,
+	"encrypt_key":     true,
+	"openid":          true,
+	"sub_openid":      true,
+	"buyer_id":        true,
+	"buyer_user_id":   true,
+	"buyer_logon_id":  true,
+	"buyer_open_id":   true,
+	"user_id":         true,
+	"alipay_user_id":  true,
+	"alipay_logon_id": true,
+	"auth_code":       true,
+	"auth_token":      true,
+	"access_token":    true,
+	"refresh_token":   true,
+	"app_auth_token":  true,
+}
+
+var xmlElementPattern = regexp.MustCompile(`<([A-Za-z_][A-Za-z0-9_]*)>(<!\[CDATA\[[\s\S]*?\]\]>|[^<]*)</([A-Za-z_][A-Za-z0-9_]*)>`)
+
+/**
+ * 返回脱敏后的请求参数副本，JSON格式的参数值（如 biz_content）同样脱敏
+ */
+func RedactValues(values url.Values) url.Values {
+	redacted := make(url.Values, len(values))
+	for key, items := range values {
+		redactedItems := make([]string, 0, len(items))
+		for _, item := range items {
+			if RedactKeys[key] {
+				item = RedactedValue
+			} else if strings.HasPrefix(strings.TrimSpace(item), "{") {
+				item = string(redactJSON([]byte(item)))
+			}
+			redactedItems = append(redactedItems, item)
+		}
+		redacted[key] = redactedItems
+	}
+	return redacted
+}
+
+/**
+ * 返回脱敏后的响应，支持JSON、XML与表单格式，其它格式原样返回
+ */
+func Redact(data []byte) []byte {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return data
+	}
+	switch trimmed[0] {
+	case '{':
+		return redactJSON(trimmed)
+	case '<':
+		return redactXML(trimmed)
+	}
+	if values, err := url.ParseQuery(string(trimmed)); err == nil && strings.Contains(string(trimmed), "=") {
+		return []byte(RedactValues(values).Encode())
+	}
+	return data
+}
+
+func redactJSON(data []byte) []byte {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return data
+	}
+	redacted, err := json.Marshal(redactJSONValue(value))
+	if err != nil {
+		return data
+	}
+	return redacted
+}
+
+func redactJSONValue(value interface{}) interface{} {
+	switch item := value.(type) {
+	case map[string]interface{}:
+		for key, child := range item {
+			if RedactKeys[key] {
+				item[key] = RedactedValue
+			} else {
+				item[key] = redactJSONValue(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range item {
+			item[i] = redactJSONValue(child)
+		}
+	}
+	return value
+}
+
+func redactXML(data []byte) []byte {
+	return xmlElementPattern.ReplaceAllFunc(data, func(element []byte) []byte {
+		match := xmlElementPattern.FindSubmatch(element)
+		if string(match[1]) != string(match[3]) || !RedactKeys[string(match[1])] {
+			return element
+		}
+		return []byte("<" + string(match[1]) + ">" + RedactedValue + "</" + string(match[1]) + ">")
+	})
+}
@@ -0,0 +1,214 @@
+package registry
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+
+	alipay "github.com/shinmigo/gopay/alipay/kernel"
+	wxpay "github.com/shinmigo/gopay/wxpay/kernel"
+)
+
+var (
+	ErrClientNotFound = errors.New("registry: client not found")
+	ErrDuplicateId    = errors.New("registry: duplicate app id or merchant id")
+	ErrInvalidConfig  = errors.New("registry: app id or merchant id is required")
+)
+
+/**
+ * 创建客户端后的初始化，如添加中间件、设置重试策略
+ */
+type Options struct {
+	AliPaySetup func(client *alipay.AliPayClient) // 支付宝客户端初始化，按 app_id 区分
+	WxPaySetup  func(client *wxpay.WxClient)      // 微信支付客户端初始化，按 mch_id 区分
+}
+
+/**
+ * 多商户客户端注册表，支付宝按 app_id，微信支付按 mch_id 区分客户端
+ * 更新配置时整体替换客户端，已取出的旧客户端可以继续完成进行中的请求
+ */
+type Registry struct {
+	mutex   sync.RWMutex
+	options *Options
+	aliPay  map[string]*alipay.AliPayClient
+	wxPay   map[string]*wxpay.WxClient
+}
+
+func New(options *Options) *Registry {
+	if options == nil {
+		options = &Options{}
+	}
+	return &Registry{
+		options: options,
+		aliPay:  make(map[string]*alipay.AliPayClient),
+		wxPay:   make(map[string]*wxpay.WxClient),
+	}
+}
+
+/**
+ * 添加或替换支付宝客户端，创建失败时保留原客户端
+ */
+func (m *Registry) SetAliPay(config *alipay.Config) error {
+	client, err := m.newAliPayClient(config)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	m.aliPay[config.AppId] = client
+	m.mutex.Unlock()
+	return nil
+}
+
+/**
+ * 添加或替换微信支付客户端，创建失败时保留原客户端
+ */
+func (m *Registry) SetWxPay(config *wxpay.Config) error {
+	client, err := m.newWxClient(config)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	m.wxPay[config.MchId] = client
+	m.mutex.Unlock()
+	return nil
+}
+
+func (m *Registry) RemoveAliPay(appId string) {
+	m.mutex.Lock()
+	delete(m.aliPay, appId)
+	m.mutex.Unlock()
+}
+
+func (m *Registry) RemoveWxPay(mchId string) {
+	m.mutex.Lock()
+	delete(m.wxPay, mchId)
+	m.mutex.Unlock()
+}
+
+/**
+ * 使用完整的配置列表替换所有客户端，任一配置创建失败时不做任何修改
+ */
+func (m *Registry) Reload(aliPayConfigs []*alipay.Config, wxPayConfigs []*wxpay.Config) error {
+	aliPay := make(map[string]*alipay.AliPayClient, len(aliPayConfigs))
+	for _, config := range aliPayConfigs {
+		client, err := m.newAliPayClient(config)
+		if err != nil {
+			return err
+		}
+		if _, ok := aliPay[config.AppId]; ok {
+			return fmt.Errorf("%w: alipay app_id %s", ErrDuplicateId, config.AppId)
+		}
+		aliPay[config.AppId] = client
+	}
+	wxPay := make(map[string]*wxpay.WxClient, len(wxPayConfigs))
+	for _, config := range wxPayConfigs {
+		client, err := m.newWxClient(config)
+		if err != nil {
+			return err
+		}
+		if _, ok := wxPay[config.MchId]; ok {
+			return fmt.Errorf("%w: wxpay mch_id %s", ErrDuplicateId, config.MchId)
+		}
+		wxPay[config.MchId] = client
+	}
+
+	m.mutex.Lock()
+	m.aliPay = aliPay
+	m.wxPay = wxPay
+	m.mutex.Unlock()
+	return nil
+}
+
+/**
+ * 获取支付宝客户端
+ */
+func (m *Registry) AliPay(appId string) (*alipay.AliPayClient, error) {
+	m.mutex.RLock()
+	client, ok := m.aliPay[appId]
+	m.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: alipay app_id %s", ErrClientNotFound, appId)
+	}
+	return client, nil
+}
+
+/**
+ * 获取微信支付客户端
+ */
+func (m *Registry) WxPay(mchId string) (*wxpay.WxClient, error) {
+	m.mutex.RLock()
+	client, ok := m.wxPay[mchId]
+	m.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: wxpay mch_id %s", ErrClientNotFound, mchId)
+	}
+	return client, nil
+}
+
+/**
+ * 按异步通知中的 app_id 找到支付宝客户端并验证签名
+ */
+func (m *Registry) AliPayNotify(notifyData url.Values) (*alipay.AliPayClient, error) {
+	client, err := m.AliPay(notifyData.Get("app_id"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = client.NotifyVerify(notifyData); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+/**
+ * 按异步通知中的 mch_id 找到微信支付客户端并验证签名
+ * 服务商模式的通知返回对应子商户的客户端副本，可用于后续查询、退款等请求
+ */
+func (m *Registry) WxPayNotify(notifyBody []byte) (*wxpay.WxClient, error) {
+	xmlHandler := make(wxpay.XmlToMap)
+	if err := xml.Unmarshal(notifyBody, &xmlHandler); err != nil {
+		return nil, err
+	}
+	client, err := m.WxPay(xmlHandler.Get("mch_id"))
+	if err != nil {
+		return nil, err
+	}
+	if err = client.VerifyNotify(notifyBody); err != nil {
+		return nil, err
+	}
+	if subMchId := xmlHandler.Get("sub_mch_id"); len(subMchId) > 0 {
+		client = client.WithSubMerchant(subMchId, xmlHandler.Get("sub_appid"))
+	}
+	return client, nil
+}
+
+func (m *Registry) newAliPayClient(config *alipay.Config) (*alipay.AliPayClient, error) {
+	if config == nil || len(config.AppId) == 0 {
+		return nil, ErrInvalidConfig
+	}
+	client, err := alipay.NewAliPayClient(config)
+	if err != nil {
+		return nil, err
+	}
+	if m.options.AliPaySetup != nil {
+		m.options.AliPaySetup(client)
+	}
+	return client, nil
+}
+
+func (m *Registry) newWxClient(config *wxpay.Config) (*wxpay.WxClient, error) {
+	if config == nil || len(config.MchId) == 0 {
+		return nil, ErrInvalidConfig
+	}
+	client, err := wxpay.NewWxClientWithConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if m.options.WxPaySetup != nil {
+		m.options.WxPaySetup(client)
+	}
+	return client, nil
+}
@@ -0,0 +1,78 @@
+package gopay
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+/**
+ * 网关请求重试策略，仅用于可安全重复提交的请求
+ */
+type RetryPolicy struct {
+	MaxAttempts int                  // 最大请求次数（含首次请求），小于等于1时不重试
+	BaseDelay   time.Duration        // 首次重试前的等待时间
+	MaxDelay    time.Duration        // 单次等待时间上限
+	Multiplier  float64              // 退避倍数，小于等于1时为2
+	Jitter      float64              // 随机抖动比例，取值[0,1]，等待时间在 (1-Jitter, 1] 倍之间浮动
+	Retryable   func(err error) bool // 自定义错误分类，为空时使用各支付渠道的默认分类
+}
+
+/**
+ * 默认重试策略：最多请求3次，首次等待200毫秒，指数退避，上限2秒
+ */
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    2 * time.Second,
+		Multiplier:  2,
+		Jitter:      0.2,
+	}
+}
+
+/**
+ * 第attempt次请求失败后的等待时间
+ */
+func (m *RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := m.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+	delay := float64(m.BaseDelay) * math.Pow(multiplier, float64(attempt-1))
+	if m.MaxDelay > 0 && delay > float64(m.MaxDelay) {
+		delay = float64(m.MaxDelay)
+	}
+	if m.Jitter > 0 {
+		jitter := math.Min(m.Jitter, 1)
+		delay = delay * (1 - jitter*rand.Float64())
+	}
+	return time.Duration(delay)
+}
+
+/**
+ * 执行请求，失败且错误可重试时按退避时间重试，retryable 为支付渠道的默认错误分类
+ * 等待重试期间 ctx 结束时返回 ctx 的错误
+ */
+func (m *RetryPolicy) Do(ctx context.Context, retryable func(err error) bool, fn func() error) error {
+	if m == nil || m.MaxAttempts <= 1 {
+		return fn()
+	}
+	if m.Retryable != nil {
+		retryable = m.Retryable
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= m.MaxAttempts || retryable == nil || !retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.Backoff(attempt)):
+		}
+	}
+}
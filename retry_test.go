@@ -0,0 +1,143 @@
+package gopay
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errTemporary = errors.New("temporary")
+
+func retryableTemporary(err error) bool {
+	return errors.Is(err, errTemporary)
+}
+
+func TestRetryPolicyMaxAttempts(t *testing.T) {
+	cases := []struct {
+		name     string
+		policy   *RetryPolicy
+		attempts int
+	}{
+		{"nil policy", nil, 1},
+		{"max attempts 1", &RetryPolicy{MaxAttempts: 1}, 1},
+		{"max attempts 3", &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, 3},
+	}
+	for _, item := range cases {
+		t.Run(item.name, func(t *testing.T) {
+			attempts := 0
+			err := item.policy.Do(context.Background(), retryableTemporary, func() error {
+				attempts++
+				return errTemporary
+			})
+			if !errors.Is(err, errTemporary) {
+				t.Errorf("err = %v, want %v", err, errTemporary)
+			}
+			if attempts != item.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, item.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyStopsOnSuccess(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond}
+	attempts := 0
+	err := policy.Do(context.Background(), retryableTemporary, func() error {
+		attempts++
+		if attempts < 2 {
+			return errTemporary
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Errorf("err = %v, attempts = %d, want nil, 2", err, attempts)
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	errPermanent := errors.New("permanent")
+	cases := []struct {
+		name      string
+		policy    *RetryPolicy
+		retryable func(err error) bool
+		err       error
+		attempts  int
+	}{
+		{"retryable error", &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, retryableTemporary, errTemporary, 3},
+		{"permanent error", &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, retryableTemporary, errPermanent, 1},
+		{"no classifier", &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, nil, errTemporary, 1},
+		{"policy classifier overrides", &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Retryable: func(err error) bool {
+			return errors.Is(err, errPermanent)
+		}}, retryableTemporary, errPermanent, 3},
+	}
+	for _, item := range cases {
+		t.Run(item.name, func(t *testing.T) {
+			attempts := 0
+			err := item.policy.Do(context.Background(), item.retryable, func() error {
+				attempts++
+				return item.err
+			})
+			if !errors.Is(err, item.err) {
+				t.Errorf("err = %v, want %v", err, item.err)
+			}
+			if attempts != item.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, item.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for index, delay := range want {
+		if backoff := policy.Backoff(index + 1); backoff != delay {
+			t.Errorf("Backoff(%d) = %v, want %v", index+1, backoff, delay)
+		}
+	}
+
+	//倍数小于等于1时按2倍退避
+	policy = &RetryPolicy{BaseDelay: 100 * time.Millisecond}
+	if backoff := policy.Backoff(3); backoff != 400*time.Millisecond {
+		t.Errorf("Backoff(3) with default multiplier = %v, want %v", backoff, 400*time.Millisecond)
+	}
+
+	policy = &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2, Jitter: 0.2}
+	for attempt := 1; attempt <= 6; attempt++ {
+		upper := want[attempt-1]
+		lower := time.Duration(float64(upper) * 0.8)
+		for i := 0; i < 100; i++ {
+			if backoff := policy.Backoff(attempt); backoff < lower || backoff > upper {
+				t.Fatalf("Backoff(%d) = %v, want in [%v, %v]", attempt, backoff, lower, upper)
+			}
+		}
+	}
+}
+
+func TestRetryPolicyContextCancel(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- policy.Do(ctx, retryableTemporary, func() error {
+			attempts++
+			return errTemporary
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want %v", err, context.Canceled)
+		}
+		if attempts != 1 {
+			t.Errorf("attempts = %d, want 1", attempts)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Do did not return after ctx was cancelled")
+	}
+}
@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+)
+
+/**
+ * 时钟，测试时可使用 FakeClock 控制轮询、限流与过期判断
+ */
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+/**
+ * 手动推进的时钟，用于测试
+ */
+type FakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	channel  chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (m *FakeClock) Now() time.Time {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.now
+}
+
+func (m *FakeClock) After(d time.Duration) <-chan time.Time {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	channel := make(chan time.Time, 1)
+	if d <= 0 {
+		channel <- m.now
+		return channel
+	}
+	m.waiters = append(m.waiters, &fakeWaiter{deadline: m.now.Add(d), channel: channel})
+	return channel
+}
+
+/**
+ * 推进时钟，触发到期的 After
+ */
+func (m *FakeClock) Advance(d time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.now = m.now.Add(d)
+	waiters := m.waiters[:0]
+	for _, waiter := range m.waiters {
+		if waiter.deadline.After(m.now) {
+			waiters = append(waiters, waiter)
+			continue
+		}
+		waiter.channel <- m.now
+	}
+	m.waiters = waiters
+}
+
+/**
+ * 等待中的 After 数量，测试时可用于判断调度器已进入等待
+ */
+func (m *FakeClock) Waiters() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.waiters)
+}
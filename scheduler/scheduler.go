@@ -0,0 +1,384 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shinmigo/gopay"
+	alipayment "github.com/shinmigo/gopay/alipay/payment"
+	"github.com/shinmigo/gopay/store"
+	wxpayment "github.com/shinmigo/gopay/wxpay/payment"
+)
+
+const (
+	DefaultInterval    = time.Minute // 默认轮询间隔
+	DefaultBatchSize   = 100         // 默认每次取出的订单数
+	DefaultConcurrency = 4           // 默认并发查询数
+)
+
+var (
+	ErrRunning         = errors.New("scheduler: already running")
+	ErrUnknownProvider = errors.New("scheduler: no payment for provider")
+)
+
+/**
+ * 待补偿的订单
+ */
+type PendingOrder struct {
+	Provider   string      // 支付渠道 alipay、wxpay
+	OutTradeNo string      // 商户订单号
+	State      store.State // 本地订单状态，为空时按 PENDING 处理
+	ExpireAt   time.Time   // 过期时间，过期后仍未支付时关闭订单，为零时不关闭
+}
+
+/**
+ * 待补偿订单来源，如查询本地数据库中超过一定时间仍未支付的订单
+ */
+type Source interface {
+	Pending(ctx context.Context, limit int) ([]*PendingOrder, error)
+}
+
+/**
+ * 使用函数作为订单来源
+ */
+type SourceFunc func(ctx context.Context, limit int) ([]*PendingOrder, error)
+
+func (m SourceFunc) Pending(ctx context.Context, limit int) ([]*PendingOrder, error) {
+	return m(ctx, limit)
+}
+
+/**
+ * 订单状态变更
+ */
+type Change struct {
+	Order         *PendingOrder // 待补偿的订单
+	Previous      store.State   // 本地订单状态
+	State         store.State   // 渠道查询到的订单状态
+	TransactionId string        // 渠道交易号
+	Closed        bool          // 是否由调度器关闭
+}
+
+type Options struct {
+	Source          Source                                          // 待补偿订单来源
+	AliPay          *alipayment.Payment                             // 支付宝支付，为空时不处理支付宝订单
+	WxPay           *wxpayment.Payment                              // 微信支付，为空时不处理微信支付订单
+	Interval        time.Duration                                   // 轮询间隔，默认1分钟
+	BatchSize       int                                             // 每次取出的订单数，默认100
+	Concurrency     int                                             // 并发查询数，默认4
+	RequestInterval time.Duration                                   // 同一支付渠道相邻两次网关请求的最小间隔，为0时不限流
+	Clock           Clock                                           // 时钟，默认系统时钟
+	OnChange        func(ctx context.Context, change *Change) error // 订单状态变更回调，返回错误时交给 OnError
+	OnError         func(order *PendingOrder, err error)            // 错误回调，取出订单失败时 order 为空
+}
+
+/**
+ * 订单补偿调度器，定期查询未支付订单的渠道状态，并关闭已过期的订单
+ */
+type Scheduler struct {
+	options  Options
+	limiters map[string]*limiter
+	mutex    sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+	cancel   context.CancelFunc
+}
+
+func New(options *Options) *Scheduler {
+	scheduler := &Scheduler{}
+	if options != nil {
+		scheduler.options = *options
+	}
+	if scheduler.options.Interval <= 0 {
+		scheduler.options.Interval = DefaultInterval
+	}
+	if scheduler.options.BatchSize <= 0 {
+		scheduler.options.BatchSize = DefaultBatchSize
+	}
+	if scheduler.options.Concurrency <= 0 {
+		scheduler.options.Concurrency = DefaultConcurrency
+	}
+	if scheduler.options.Clock == nil {
+		scheduler.options.Clock = systemClock{}
+	}
+	scheduler.limiters = map[string]*limiter{
+		gopay.ProviderAliPay: {clock: scheduler.options.Clock, interval: scheduler.options.RequestInterval},
+		gopay.ProviderWxPay:  {clock: scheduler.options.Clock, interval: scheduler.options.RequestInterval},
+	}
+	return scheduler
+}
+
+/**
+ * 按轮询间隔持续补偿，直到 ctx 结束或调用 Shutdown
+ */
+func (m *Scheduler) Run(ctx context.Context) error {
+	m.mutex.Lock()
+	if m.stop != nil {
+		m.mutex.Unlock()
+		return ErrRunning
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	workCtx, cancel := context.WithCancel(ctx)
+	m.stop, m.done, m.cancel = stop, done, cancel
+	m.mutex.Unlock()
+
+	defer func() {
+		cancel()
+		m.mutex.Lock()
+		m.stop, m.done, m.cancel = nil, nil, nil
+		m.mutex.Unlock()
+		close(done)
+	}()
+
+	for {
+		m.runOnce(workCtx, stop)
+		select {
+		case <-stop:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.options.Clock.After(m.options.Interval):
+		}
+	}
+}
+
+/**
+ * 停止取出新的订单，并等待进行中的查询完成；ctx 结束时取消进行中的请求
+ */
+func (m *Scheduler) Shutdown(ctx context.Context) error {
+	m.mutex.Lock()
+	stop, done, cancel := m.stop, m.done, m.cancel
+	if stop != nil {
+		select {
+		case <-stop:
+		default:
+			close(stop)
+		}
+	}
+	m.mutex.Unlock()
+	if stop == nil {
+		return nil
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+/**
+ * 执行一次补偿，取出一批订单并等待处理完成
+ */
+func (m *Scheduler) RunOnce(ctx context.Context) {
+	m.runOnce(ctx, nil)
+}
+
+func (m *Scheduler) runOnce(ctx context.Context, stop <-chan struct{}) {
+	if m.options.Source == nil {
+		return
+	}
+	orders, err := m.options.Source.Pending(ctx, m.options.BatchSize)
+	if err != nil {
+		m.onError(nil, err)
+		return
+	}
+
+	semaphore := make(chan struct{}, m.options.Concurrency)
+	wait := sync.WaitGroup{}
+	for _, order := range orders {
+		if stopped(ctx, stop) {
+			break
+		}
+		semaphore <- struct{}{}
+		wait.Add(1)
+		go func(order *PendingOrder) {
+			defer func() {
+				<-semaphore
+				wait.Done()
+			}()
+			m.process(ctx, order)
+		}(order)
+	}
+	wait.Wait()
+}
+
+func (m *Scheduler) process(ctx context.Context, order *PendingOrder) {
+	change, err := m.check(ctx, order)
+	if err != nil {
+		m.onError(order, err)
+		return
+	}
+	if change == nil || m.options.OnChange == nil {
+		return
+	}
+	if err = m.options.OnChange(ctx, change); err != nil {
+		m.onError(order, err)
+	}
+}
+
+/**
+ * 查询渠道订单状态，未支付且已过期时关闭订单，状态与本地一致时返回空
+ */
+func (m *Scheduler) check(ctx context.Context, order *PendingOrder) (*Change, error) {
+	previous := order.State
+	if len(previous) == 0 {
+		previous = store.StatePending
+	}
+	change := &Change{Order: order, Previous: previous}
+
+	var err error
+	change.State, change.TransactionId, err = m.query(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	if change.State == store.StatePending && !order.ExpireAt.IsZero() && !m.options.Clock.Now().Before(order.ExpireAt) {
+		err = m.close(ctx, order)
+		if errors.Is(err, gopay.ErrOrderPaid) {
+			//关闭前用户完成支付，重新查询支付结果
+			change.State, change.TransactionId, err = m.query(ctx, order)
+		} else if err == nil || errors.Is(err, gopay.ErrOrderNotExist) {
+			change.State, change.Closed, err = store.StateClosed, true, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if change.State == previous {
+		return nil, nil
+	}
+	return change, nil
+}
+
+/**
+ * 查询渠道订单状态，渠道订单不存在时按未支付处理
+ */
+func (m *Scheduler) query(ctx context.Context, order *PendingOrder) (store.State, string, error) {
+	if err := m.wait(ctx, order.Provider); err != nil {
+		return "", "", err
+	}
+	switch order.Provider {
+	case gopay.ProviderAliPay:
+		aliPayment := &alipayment.Payment{Client: m.options.AliPay.Client.WithContext(ctx)}
+		result, err := aliPayment.TradeQuery(&alipayment.TradeQuery{OutTradeNo: order.OutTradeNo})
+		if errors.Is(err, gopay.ErrOrderNotExist) {
+			return store.StatePending, "", nil
+		}
+		if err != nil {
+			return "", "", err
+		}
+		state, err := store.AliPayState(result.Body.TradeStatus)
+		return state, result.Body.TradeNo, err
+	case gopay.ProviderWxPay:
+		wxPayment := &wxpayment.Payment{Client: m.options.WxPay.Client.WithContext(ctx)}
+		result, err := wxPayment.Query(&wxpayment.TradeQuery{OutTradeNo: order.OutTradeNo})
+		if errors.Is(err, gopay.ErrOrderNotExist) {
+			return store.StatePending, "", nil
+		}
+		if err != nil {
+			return "", "", err
+		}
+		state, err := store.WxPayState(result.TradeState)
+		return state, result.TransactionId, err
+	}
+	return "", "", fmt.Errorf("%w: %s", ErrUnknownProvider, order.Provider)
+}
+
+func (m *Scheduler) close(ctx context.Context, order *PendingOrder) error {
+	if err := m.wait(ctx, order.Provider); err != nil {
+		return err
+	}
+	var err error
+	switch order.Provider {
+	case gopay.ProviderAliPay:
+		aliPayment := &alipayment.Payment{Client: m.options.AliPay.Client.WithContext(ctx)}
+		_, err = aliPayment.TradeClose(&alipayment.TradeClose{OutTradeNo: order.OutTradeNo})
+	case gopay.ProviderWxPay:
+		wxPayment := &wxpayment.Payment{Client: m.options.WxPay.Client.WithContext(ctx)}
+		_, err = wxPayment.Close(&wxpayment.TradeClose{OutTradeNo: order.OutTradeNo})
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnknownProvider, order.Provider)
+	}
+	return err
+}
+
+/**
+ * 按支付渠道限流，未配置对应渠道的支付时返回错误
+ */
+func (m *Scheduler) wait(ctx context.Context, provider string) error {
+	if (provider == gopay.ProviderAliPay && m.options.AliPay == nil) || (provider == gopay.ProviderWxPay && m.options.WxPay == nil) {
+		return fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+	limiter, ok := m.limiters[provider]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+	return limiter.wait(ctx)
+}
+
+func (m *Scheduler) onError(order *PendingOrder, err error) {
+	if m.options.OnError != nil {
+		m.options.OnError(order, err)
+	}
+}
+
+func stopped(ctx context.Context, stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+/**
+ * 使用调度器的状态变更更新订单存储
+ */
+func TransitStore(orderStore store.Store) func(ctx context.Context, change *Change) error {
+	return func(ctx context.Context, change *Change) error {
+		_, _, err := orderStore.Transit(ctx, change.Order.Provider, change.Order.OutTradeNo, change.State, change.TransactionId)
+		return err
+	}
+}
+
+/**
+ * 相邻两次请求的最小间隔
+ */
+type limiter struct {
+	mutex    sync.Mutex
+	clock    Clock
+	interval time.Duration
+	next     time.Time
+}
+
+func (m *limiter) wait(ctx context.Context) error {
+	if m.interval <= 0 {
+		return ctx.Err()
+	}
+	m.mutex.Lock()
+	now := m.clock.Now()
+	if m.next.Before(now) {
+		m.next = now
+	}
+	delay := m.next.Sub(now)
+	m.next = m.next.Add(m.interval)
+	m.mutex.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.clock.After(delay):
+		return nil
+	}
+}
@@ -0,0 +1,601 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shinmigo/gopay"
+	alipaytest "github.com/shinmigo/gopay/alipay/gopaytest"
+	alipayment "github.com/shinmigo/gopay/alipay/payment"
+	"github.com/shinmigo/gopay/scheduler"
+	"github.com/shinmigo/gopay/store"
+	wxpaytest "github.com/shinmigo/gopay/wxpay/gopaytest"
+	wxpayment "github.com/shinmigo/gopay/wxpay/payment"
+)
+
+var now = time.Date(2022, 1, 1, 12, 0, 0, 0, time.Local)
+
+/**
+ * 记录经过客户端中间件的网关调用，block 不为空时调用阻塞到 block 关闭或请求取消
+ */
+type recorder struct {
+	mutex       sync.Mutex
+	apis        []string
+	inFlight    int
+	maxInFlight int
+	block       chan struct{}
+}
+
+func (m *recorder) middleware(next gopay.Handler) gopay.Handler {
+	return func(call *gopay.Call) error {
+		m.mutex.Lock()
+		m.apis = append(m.apis, call.Api)
+		m.inFlight++
+		if m.inFlight > m.maxInFlight {
+			m.maxInFlight = m.inFlight
+		}
+		block := m.block
+		m.mutex.Unlock()
+		defer func() {
+			m.mutex.Lock()
+			m.inFlight--
+			m.mutex.Unlock()
+		}()
+
+		if block != nil {
+			select {
+			case <-block:
+			case <-call.Context.Done():
+				return call.Context.Err()
+			}
+		}
+		return next(call)
+	}
+}
+
+func (m *recorder) reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.apis, m.maxInFlight = nil, 0
+}
+
+func (m *recorder) calls() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]string(nil), m.apis...)
+}
+
+func (m *recorder) current() (int, int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.inFlight, m.maxInFlight
+}
+
+/**
+ * 收集状态变更与错误回调
+ */
+type collector struct {
+	mutex   sync.Mutex
+	changes map[string]*scheduler.Change
+	errs    []error
+}
+
+func newCollector() *collector {
+	return &collector{changes: make(map[string]*scheduler.Change)}
+}
+
+func (m *collector) onChange(ctx context.Context, change *scheduler.Change) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.changes[change.Order.OutTradeNo] = change
+	return nil
+}
+
+func (m *collector) onError(order *scheduler.PendingOrder, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.errs = append(m.errs, err)
+}
+
+func (m *collector) change(outTradeNo string) *scheduler.Change {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.changes[outTradeNo]
+}
+
+func (m *collector) errors() []error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]error(nil), m.errs...)
+}
+
+func newAliPay(t *testing.T, record *recorder) (*alipaytest.Server, *alipayment.Payment) {
+	server, err := alipaytest.NewServer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	client, err := server.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record != nil {
+		client.Use(record.middleware)
+	}
+	return server, &alipayment.Payment{Client: client}
+}
+
+func newWxPay(t *testing.T, record *recorder) (*wxpaytest.Server, *wxpayment.Payment) {
+	notifyServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, _ = writer.Write([]byte("<xml><return_code><![CDATA[SUCCESS]]></return_code></xml>"))
+	}))
+	t.Cleanup(notifyServer.Close)
+	server := wxpaytest.NewServer(&wxpaytest.Options{NotifyUrl: notifyServer.URL})
+	t.Cleanup(server.Close)
+	client := server.Client()
+	if record != nil {
+		client.Use(record.middleware)
+	}
+	return server, &wxpayment.Payment{Client: client}
+}
+
+func wxOrder(t *testing.T, pay *wxpayment.Payment, outTradeNo string) {
+	_, err := pay.Pay(&wxpayment.Trade{
+		Body:           "测试",
+		OutTradeNo:     outTradeNo,
+		TotalFee:       100,
+		SpbillCreateIp: "127.0.0.1",
+		NotifyUrl:      "https://example.com/notify",
+		TradeType:      "NATIVE",
+		ProductId:      "P1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func fixedSource(orders ...*scheduler.PendingOrder) scheduler.Source {
+	return scheduler.SourceFunc(func(ctx context.Context, limit int) ([]*scheduler.PendingOrder, error) {
+		return orders, nil
+	})
+}
+
+/**
+ * 等待条件成立，超时后测试失败
+ */
+func waitFor(t *testing.T, message string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", message)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestRunOnceReportsChange(t *testing.T) {
+	aliPayServer, aliPay := newAliPay(t, nil)
+	wxPayServer, wxPay := newWxPay(t, nil)
+	aliPayServer.CreateOrder("A1", "测试", "1.00")
+	aliPayServer.CreateOrder("A2", "测试", "1.00")
+	if err := aliPayServer.Pay("A1"); err != nil {
+		t.Fatal(err)
+	}
+	wxOrder(t, wxPay, "W1")
+	wxOrder(t, wxPay, "W2")
+	if err := wxPayServer.Transit("W1", wxpaytest.TradeStateUserPaying); err != nil {
+		t.Fatal(err)
+	}
+	wxOrder(t, wxPay, "W3")
+	if err := wxPayServer.Pay("W3"); err != nil {
+		t.Fatal(err)
+	}
+
+	results := newCollector()
+	schedule := scheduler.New(&scheduler.Options{
+		Source: fixedSource(
+			&scheduler.PendingOrder{Provider: gopay.ProviderAliPay, OutTradeNo: "A1"},
+			&scheduler.PendingOrder{Provider: gopay.ProviderAliPay, OutTradeNo: "A2"},
+			&scheduler.PendingOrder{Provider: gopay.ProviderAliPay, OutTradeNo: "A3"},
+			&scheduler.PendingOrder{Provider: gopay.ProviderWxPay, OutTradeNo: "W1"},
+			&scheduler.PendingOrder{Provider: gopay.ProviderWxPay, OutTradeNo: "W2"},
+			&scheduler.PendingOrder{Provider: gopay.ProviderWxPay, OutTradeNo: "W3", State: store.StatePaying},
+		),
+		AliPay:   aliPay,
+		WxPay:    wxPay,
+		Clock:    scheduler.NewFakeClock(now),
+		OnChange: results.onChange,
+		OnError:  results.onError,
+	})
+	schedule.RunOnce(context.Background())
+
+	if errs := results.errors(); len(errs) > 0 {
+		t.Fatalf("errors = %v", errs)
+	}
+	aliPayOrder, _ := aliPayServer.Order("A1")
+	wxPayOrder1, _ := wxPayServer.Order("W1")
+	wxPayOrder3, _ := wxPayServer.Order("W3")
+	cases := []struct {
+		outTradeNo    string
+		previous      store.State
+		state         store.State
+		transactionId string
+	}{
+		{"A1", store.StatePending, store.StatePaid, aliPayOrder.TradeNo},
+		{"W1", store.StatePending, store.StatePaying, wxPayOrder1.TransactionId},
+		{"W3", store.StatePaying, store.StatePaid, wxPayOrder3.TransactionId},
+	}
+	for _, item := range cases {
+		change := results.change(item.outTradeNo)
+		if change == nil {
+			t.Errorf("%s: no change", item.outTradeNo)
+			continue
+		}
+		if change.Previous != item.previous || change.State != item.state || change.TransactionId != item.transactionId || change.Closed {
+			t.Errorf("%s: change = %+v", item.outTradeNo, change)
+		}
+	}
+	//状态未变化或渠道订单不存在且未过期时不回调
+	for _, outTradeNo := range []string{"A2", "A3", "W2"} {
+		if change := results.change(outTradeNo); change != nil {
+			t.Errorf("%s: unexpected change %+v", outTradeNo, change)
+		}
+	}
+}
+
+func TestRunOnceOnChangeError(t *testing.T) {
+	aliPayServer, aliPay := newAliPay(t, nil)
+	aliPayServer.CreateOrder("A1", "测试", "1.00")
+	if err := aliPayServer.Pay("A1"); err != nil {
+		t.Fatal(err)
+	}
+
+	errChange := errors.New("change failed")
+	results := newCollector()
+	schedule := scheduler.New(&scheduler.Options{
+		Source: fixedSource(
+			&scheduler.PendingOrder{Provider: gopay.ProviderAliPay, OutTradeNo: "A1"},
+			&scheduler.PendingOrder{Provider: gopay.ProviderWxPay, OutTradeNo: "W1"},
+		),
+		AliPay: aliPay,
+		OnChange: func(ctx context.Context, change *scheduler.Change) error {
+			return errChange
+		},
+		OnError: results.onError,
+	})
+	schedule.RunOnce(context.Background())
+
+	errs := results.errors()
+	if len(errs) != 2 {
+		t.Fatalf("errors = %v", errs)
+	}
+	var changeErr, providerErr bool
+	for _, err := range errs {
+		changeErr = changeErr || errors.Is(err, errChange)
+		providerErr = providerErr || errors.Is(err, scheduler.ErrUnknownProvider)
+	}
+	if !changeErr || !providerErr {
+		t.Errorf("errors = %v", errs)
+	}
+}
+
+func TestRunOnceClosesExpired(t *testing.T) {
+	aliPayServer, aliPay := newAliPay(t, nil)
+	wxPayServer, wxPay := newWxPay(t, nil)
+	aliPayServer.CreateOrder("A1", "测试", "1.00")
+	aliPayServer.CreateOrder("A2", "测试", "1.00")
+	wxOrder(t, wxPay, "W1")
+
+	results := newCollector()
+	clock := scheduler.NewFakeClock(now)
+	schedule := scheduler.New(&scheduler.Options{
+		Source: fixedSource(
+			&scheduler.PendingOrder{Provider: gopay.ProviderAliPay, OutTradeNo: "A1", ExpireAt: now},
+			&scheduler.PendingOrder{Provider: gopay.ProviderAliPay, OutTradeNo: "A2", ExpireAt: now.Add(time.Hour)},
+			&scheduler.PendingOrder{Provider: gopay.ProviderAliPay, OutTradeNo: "A3", ExpireAt: now.Add(-time.Hour)},
+			&scheduler.PendingOrder{Provider: gopay.ProviderWxPay, OutTradeNo: "W1", ExpireAt: now.Add(-time.Minute)},
+		),
+		AliPay:   aliPay,
+		WxPay:    wxPay,
+		Clock:    clock,
+		OnChange: results.onChange,
+		OnError:  results.onError,
+	})
+	schedule.RunOnce(context.Background())
+
+	if errs := results.errors(); len(errs) > 0 {
+		t.Fatalf("errors = %v", errs)
+	}
+	//渠道订单不存在时同样视为已关闭
+	for _, outTradeNo := range []string{"A1", "A3", "W1"} {
+		change := results.change(outTradeNo)
+		if change == nil || !change.Closed || change.State != store.StateClosed || change.Previous != store.StatePending {
+			t.Errorf("%s: change = %+v", outTradeNo, change)
+		}
+	}
+	if change := results.change("A2"); change != nil {
+		t.Errorf("A2: unexpected change %+v", change)
+	}
+	if order, _ := aliPayServer.Order("A1"); order.TradeStatus != alipaytest.TradeStatusClosed {
+		t.Errorf("A1 trade_status = %s", order.TradeStatus)
+	}
+	if order, _ := aliPayServer.Order("A2"); order.TradeStatus != alipaytest.TradeStatusWaitBuyerPay {
+		t.Errorf("A2 trade_status = %s", order.TradeStatus)
+	}
+	if order, _ := wxPayServer.Order("W1"); order.TradeState != wxpaytest.TradeStateClosed {
+		t.Errorf("W1 trade_state = %s", order.TradeState)
+	}
+
+	//时钟推进到过期时间后关闭
+	clock.Advance(time.Hour)
+	schedule.RunOnce(context.Background())
+	if change := results.change("A2"); change == nil || !change.Closed {
+		t.Errorf("A2: change = %+v", change)
+	}
+}
+
+func TestRunOnceRequeriesPaidOnClose(t *testing.T) {
+	record := &recorder{}
+	wxPayServer, wxPay := newWxPay(t, record)
+	wxOrder(t, wxPay, "W1")
+	record.reset()
+
+	results := newCollector()
+	clock := scheduler.NewFakeClock(now)
+	schedule := scheduler.New(&scheduler.Options{
+		Source:          fixedSource(&scheduler.PendingOrder{Provider: gopay.ProviderWxPay, OutTradeNo: "W1", ExpireAt: now}),
+		WxPay:           wxPay,
+		RequestInterval: time.Second,
+		Clock:           clock,
+		OnChange:        results.onChange,
+		OnError:         results.onError,
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		schedule.RunOnce(context.Background())
+	}()
+
+	//查询到未支付后，关闭请求等待限流时用户完成支付
+	waitFor(t, "close request to wait", func() bool {
+		return clock.Waiters() == 1
+	})
+	if err := wxPayServer.Pay("W1"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	//关闭返回 ORDERPAID 后重新查询
+	waitFor(t, "re-query to wait", func() bool {
+		return clock.Waiters() == 1 && len(record.calls()) == 2
+	})
+	clock.Advance(time.Second)
+	<-done
+
+	if errs := results.errors(); len(errs) > 0 {
+		t.Fatalf("errors = %v", errs)
+	}
+	order, _ := wxPayServer.Order("W1")
+	change := results.change("W1")
+	if change == nil || change.Closed || change.State != store.StatePaid || change.TransactionId != order.TransactionId {
+		t.Errorf("change = %+v", change)
+	}
+	want := []string{"pay/orderquery", "pay/closeorder", "pay/orderquery"}
+	if calls := record.calls(); len(calls) != len(want) || calls[0] != want[0] || calls[1] != want[1] || calls[2] != want[2] {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestRequestInterval(t *testing.T) {
+	record := &recorder{}
+	aliPayServer, aliPay := newAliPay(t, record)
+	orders := make([]*scheduler.PendingOrder, 0, 3)
+	for _, outTradeNo := range []string{"A1", "A2", "A3"} {
+		aliPayServer.CreateOrder(outTradeNo, "测试", "1.00")
+		orders = append(orders, &scheduler.PendingOrder{Provider: gopay.ProviderAliPay, OutTradeNo: outTradeNo})
+	}
+
+	clock := scheduler.NewFakeClock(now)
+	results := newCollector()
+	schedule := scheduler.New(&scheduler.Options{
+		Source:          fixedSource(orders...),
+		AliPay:          aliPay,
+		Concurrency:     3,
+		RequestInterval: time.Second,
+		Clock:           clock,
+		OnError:         results.onError,
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		schedule.RunOnce(context.Background())
+	}()
+
+	//第一个请求立即发出，其余两个分别等待1秒与2秒
+	waitFor(t, "two requests to wait", func() bool {
+		return clock.Waiters() == 2 && len(record.calls()) == 1
+	})
+	clock.Advance(999 * time.Millisecond)
+	if waiters, calls := clock.Waiters(), len(record.calls()); waiters != 2 || calls != 1 {
+		t.Fatalf("after 999ms: waiters = %d, calls = %d", waiters, calls)
+	}
+	clock.Advance(time.Millisecond)
+	waitFor(t, "second request", func() bool {
+		return clock.Waiters() == 1 && len(record.calls()) == 2
+	})
+	if isDone(done) {
+		t.Fatal("RunOnce returned before the third request")
+	}
+	clock.Advance(time.Second)
+	waitFor(t, "third request", func() bool {
+		return len(record.calls()) == 3
+	})
+	<-done
+	if errs := results.errors(); len(errs) > 0 {
+		t.Fatalf("errors = %v", errs)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	record := &recorder{block: make(chan struct{})}
+	aliPayServer, aliPay := newAliPay(t, record)
+	orders := make([]*scheduler.PendingOrder, 0, 6)
+	for _, outTradeNo := range []string{"A1", "A2", "A3", "A4", "A5", "A6"} {
+		aliPayServer.CreateOrder(outTradeNo, "测试", "1.00")
+		orders = append(orders, &scheduler.PendingOrder{Provider: gopay.ProviderAliPay, OutTradeNo: outTradeNo})
+	}
+
+	schedule := scheduler.New(&scheduler.Options{
+		Source:      fixedSource(orders...),
+		AliPay:      aliPay,
+		Concurrency: 2,
+		Clock:       scheduler.NewFakeClock(now),
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		schedule.RunOnce(context.Background())
+	}()
+
+	waitFor(t, "two requests in flight", func() bool {
+		inFlight, _ := record.current()
+		return inFlight == 2
+	})
+	time.Sleep(20 * time.Millisecond)
+	if inFlight, maxInFlight := record.current(); inFlight != 2 || maxInFlight != 2 {
+		t.Fatalf("inFlight = %d, maxInFlight = %d, want 2", inFlight, maxInFlight)
+	}
+	close(record.block)
+	<-done
+
+	if _, maxInFlight := record.current(); maxInFlight != 2 {
+		t.Errorf("maxInFlight = %d, want 2", maxInFlight)
+	}
+	if calls := len(record.calls()); calls != 6 {
+		t.Errorf("calls = %d, want 6", calls)
+	}
+}
+
+func TestShutdownWaitsForInFlight(t *testing.T) {
+	record := &recorder{block: make(chan struct{})}
+	aliPayServer, aliPay := newAliPay(t, record)
+	aliPayServer.CreateOrder("A1", "测试", "1.00")
+	if err := aliPayServer.Pay("A1"); err != nil {
+		t.Fatal(err)
+	}
+
+	results := newCollector()
+	pendingCalls := 0
+	mutex := sync.Mutex{}
+	source := scheduler.SourceFunc(func(ctx context.Context, limit int) ([]*scheduler.PendingOrder, error) {
+		mutex.Lock()
+		pendingCalls++
+		mutex.Unlock()
+		return []*scheduler.PendingOrder{{Provider: gopay.ProviderAliPay, OutTradeNo: "A1"}}, nil
+	})
+	schedule := scheduler.New(&scheduler.Options{
+		Source:   source,
+		AliPay:   aliPay,
+		Clock:    scheduler.NewFakeClock(now),
+		OnChange: results.onChange,
+		OnError:  results.onError,
+	})
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- schedule.Run(context.Background())
+	}()
+	waitFor(t, "request in flight", func() bool {
+		inFlight, _ := record.current()
+		return inFlight == 1
+	})
+	if err := schedule.Run(context.Background()); !errors.Is(err, scheduler.ErrRunning) {
+		t.Errorf("Run twice: got %v, want %v", err, scheduler.ErrRunning)
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- schedule.Shutdown(context.Background())
+	}()
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned %v before the request finished", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(record.block)
+
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	if err := <-runErr; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+	if change := results.change("A1"); change == nil || change.State != store.StatePaid {
+		t.Errorf("change = %+v", change)
+	}
+	if errs := results.errors(); len(errs) > 0 {
+		t.Errorf("errors = %v", errs)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if pendingCalls != 1 {
+		t.Errorf("Pending calls = %d, want 1", pendingCalls)
+	}
+}
+
+func TestShutdownCancelsOnTimeout(t *testing.T) {
+	record := &recorder{block: make(chan struct{})}
+	defer close(record.block)
+	aliPayServer, aliPay := newAliPay(t, record)
+	aliPayServer.CreateOrder("A1", "测试", "1.00")
+
+	results := newCollector()
+	schedule := scheduler.New(&scheduler.Options{
+		Source:   fixedSource(&scheduler.PendingOrder{Provider: gopay.ProviderAliPay, OutTradeNo: "A1"}),
+		AliPay:   aliPay,
+		Clock:    scheduler.NewFakeClock(now),
+		OnChange: results.onChange,
+		OnError:  results.onError,
+	})
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- schedule.Run(context.Background())
+	}()
+	waitFor(t, "request in flight", func() bool {
+		inFlight, _ := record.current()
+		return inFlight == 1
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := schedule.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	//Shutdown 返回时进行中的请求已取消
+	if inFlight, _ := record.current(); inFlight != 0 {
+		t.Errorf("inFlight = %d after Shutdown", inFlight)
+	}
+	if err := <-runErr; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+	errs := results.errors()
+	if len(errs) != 1 || !errors.Is(errs[0], context.Canceled) {
+		t.Errorf("errors = %v, want %v", errs, context.Canceled)
+	}
+	if change := results.change("A1"); change != nil {
+		t.Errorf("unexpected change %+v", change)
+	}
+}
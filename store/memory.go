@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+/**
+ * 内存订单存储，用于测试与单实例部署，进程重启后数据丢失
+ */
+type MemoryStore struct {
+	mutex         sync.Mutex
+	orders        map[string]*Order
+	notifications map[string]*Notification
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		orders:        make(map[string]*Order),
+		notifications: make(map[string]*Notification),
+	}
+}
+
+func (m *MemoryStore) CreateOrder(ctx context.Context, order *Order) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	orderKey := storeKey(order.Provider, order.OutTradeNo)
+	if _, ok := m.orders[orderKey]; ok {
+		return ErrOrderExists
+	}
+	now := time.Now()
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = now
+	}
+	order.UpdatedAt = now
+	stored := *order
+	m.orders[orderKey] = &stored
+	return nil
+}
+
+func (m *MemoryStore) GetOrder(ctx context.Context, provider, outTradeNo string) (*Order, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	order, ok := m.orders[storeKey(provider, outTradeNo)]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	result := *order
+	return &result, nil
+}
+
+func (m *MemoryStore) Transit(ctx context.Context, provider, outTradeNo string, state State, transactionId string) (*Order, State, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	order, ok := m.orders[storeKey(provider, outTradeNo)]
+	if !ok {
+		return nil, "", ErrOrderNotFound
+	}
+	previous := order.State
+	if !CanTransit(previous, state) {
+		return nil, previous, ErrInvalidTransition
+	}
+	order.State = state
+	if len(transactionId) > 0 {
+		order.TransactionId = transactionId
+	}
+	order.UpdatedAt = time.Now()
+	result := *order
+	return &result, previous, nil
+}
+
+func (m *MemoryStore) ClaimNotification(ctx context.Context, notification *Notification, staleBefore time.Time) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	notifyKey := storeKey(notification.Provider, notification.NotifyId)
+	if stored, ok := m.notifications[notifyKey]; ok {
+		if stored.Status == NotificationDone {
+			return false, nil
+		}
+		if !stored.ReceivedAt.Before(staleBefore) {
+			return false, ErrNotifyProcessing
+		}
+	}
+	notification.Status = NotificationProcessing
+	stored := *notification
+	m.notifications[notifyKey] = &stored
+	return true, nil
+}
+
+func (m *MemoryStore) CompleteNotification(ctx context.Context, provider, notifyId string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if stored, ok := m.notifications[storeKey(provider, notifyId)]; ok {
+		stored.Status = NotificationDone
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteNotification(ctx context.Context, provider, notifyId string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.notifications, storeKey(provider, notifyId))
+	return nil
+}
+
+func storeKey(provider, id string) string {
+	return provider + "/" + id
+}
@@ -0,0 +1,232 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shinmigo/gopay"
+)
+
+/**
+ * 状态并发变更时 Transit 的重试次数
+ */
+const sqlTransitAttempts = 3
+
+/**
+ * 数据库订单存储配置
+ */
+type SQLOptions struct {
+	OrderTable        string                 // 订单表名，默认 gopay_order
+	NotificationTable string                 // 通知表名，默认 gopay_notification
+	Placeholder       func(index int) string // 参数占位符，index 从1开始，默认 ?，PostgreSQL 使用 DollarPlaceholder
+}
+
+/**
+ * PostgreSQL 风格的参数占位符 $1、$2
+ */
+func DollarPlaceholder(index int) string {
+	return "$" + strconv.Itoa(index)
+}
+
+/**
+ * 基于 database/sql 的订单存储，使用状态比较更新保证并发下的状态流转
+ */
+type SQLStore struct {
+	db      *sql.DB
+	options SQLOptions
+}
+
+func NewSQLStore(db *sql.DB, options *SQLOptions) *SQLStore {
+	store := &SQLStore{db: db}
+	if options != nil {
+		store.options = *options
+	}
+	if len(store.options.OrderTable) == 0 {
+		store.options.OrderTable = "gopay_order"
+	}
+	if len(store.options.NotificationTable) == 0 {
+		store.options.NotificationTable = "gopay_notification"
+	}
+	if store.options.Placeholder == nil {
+		store.options.Placeholder = func(index int) string {
+			return "?"
+		}
+	}
+	return store
+}
+
+/**
+ * 创建订单表与通知表，表已存在时跳过
+ */
+func (m *SQLStore) CreateTables(ctx context.Context) error {
+	statements := []string{
+		"CREATE TABLE IF NOT EXISTS " + m.options.OrderTable + " (" +
+			"provider VARCHAR(16) NOT NULL, " +
+			"out_trade_no VARCHAR(64) NOT NULL, " +
+			"transaction_id VARCHAR(64) NOT NULL, " +
+			"state VARCHAR(16) NOT NULL, " +
+			"amount BIGINT NOT NULL, " +
+			"created_at TIMESTAMP NOT NULL, " +
+			"updated_at TIMESTAMP NOT NULL, " +
+			"PRIMARY KEY (provider, out_trade_no))",
+		"CREATE TABLE IF NOT EXISTS " + m.options.NotificationTable + " (" +
+			"provider VARCHAR(16) NOT NULL, " +
+			"notify_id VARCHAR(128) NOT NULL, " +
+			"out_trade_no VARCHAR(64) NOT NULL, " +
+			"transaction_id VARCHAR(64) NOT NULL, " +
+			"state VARCHAR(16) NOT NULL, " +
+			"amount BIGINT NOT NULL, " +
+			"status VARCHAR(16) NOT NULL, " +
+			"received_at TIMESTAMP NOT NULL, " +
+			"PRIMARY KEY (provider, notify_id))",
+	}
+	for _, statement := range statements {
+		if _, err := m.db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *SQLStore) CreateOrder(ctx context.Context, order *Order) error {
+	now := time.Now().UTC()
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = now
+	}
+	order.UpdatedAt = now
+
+	_, err := m.db.ExecContext(ctx, m.bind("INSERT INTO "+m.options.OrderTable+
+		" (provider, out_trade_no, transaction_id, state, amount, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)"),
+		order.Provider, order.OutTradeNo, order.TransactionId, string(order.State), int64(order.Amount), order.CreatedAt.UTC(), order.UpdatedAt)
+	if err != nil {
+		//主键冲突的错误因驱动而异，插入失败后按订单是否存在判断
+		if _, getErr := m.GetOrder(ctx, order.Provider, order.OutTradeNo); getErr == nil {
+			return ErrOrderExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (m *SQLStore) GetOrder(ctx context.Context, provider, outTradeNo string) (*Order, error) {
+	order := &Order{}
+	var state string
+	var amount int64
+	err := m.db.QueryRowContext(ctx, m.bind("SELECT provider, out_trade_no, transaction_id, state, amount, created_at, updated_at FROM "+
+		m.options.OrderTable+" WHERE provider = ? AND out_trade_no = ?"), provider, outTradeNo).
+		Scan(&order.Provider, &order.OutTradeNo, &order.TransactionId, &state, &amount, &order.CreatedAt, &order.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	order.State = State(state)
+	order.Amount = gopay.Amount(amount)
+	return order, nil
+}
+
+func (m *SQLStore) Transit(ctx context.Context, provider, outTradeNo string, state State, transactionId string) (*Order, State, error) {
+	for attempt := 0; attempt < sqlTransitAttempts; attempt++ {
+		order, err := m.GetOrder(ctx, provider, outTradeNo)
+		if err != nil {
+			return nil, "", err
+		}
+		previous := order.State
+		if !CanTransit(previous, state) {
+			return nil, previous, ErrInvalidTransition
+		}
+		if len(transactionId) > 0 {
+			order.TransactionId = transactionId
+		}
+		order.State = state
+		order.UpdatedAt = time.Now().UTC()
+
+		//仅在状态未被并发修改时更新
+		result, err := m.db.ExecContext(ctx, m.bind("UPDATE "+m.options.OrderTable+
+			" SET state = ?, transaction_id = ?, updated_at = ? WHERE provider = ? AND out_trade_no = ? AND state = ?"),
+			string(order.State), order.TransactionId, order.UpdatedAt, provider, outTradeNo, string(previous))
+		if err != nil {
+			return nil, previous, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, previous, err
+		}
+		if affected > 0 {
+			return order, previous, nil
+		}
+	}
+	return nil, "", ErrInvalidTransition
+}
+
+func (m *SQLStore) ClaimNotification(ctx context.Context, notification *Notification, staleBefore time.Time) (bool, error) {
+	notification.Status = NotificationProcessing
+	_, err := m.db.ExecContext(ctx, m.bind("INSERT INTO "+m.options.NotificationTable+
+		" (provider, notify_id, out_trade_no, transaction_id, state, amount, status, received_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"),
+		notification.Provider, notification.NotifyId, notification.OutTradeNo, notification.TransactionId,
+		string(notification.State), int64(notification.Amount), string(notification.Status), notification.ReceivedAt.UTC())
+	if err == nil {
+		return true, nil
+	}
+
+	//重新领取处理中断的通知，接收时间更新后其他并发请求不会再次领取
+	result, updateErr := m.db.ExecContext(ctx, m.bind("UPDATE "+m.options.NotificationTable+
+		" SET received_at = ? WHERE provider = ? AND notify_id = ? AND status = ? AND received_at < ?"),
+		notification.ReceivedAt.UTC(), notification.Provider, notification.NotifyId, string(NotificationProcessing), staleBefore.UTC())
+	if updateErr != nil {
+		return false, updateErr
+	}
+	affected, updateErr := result.RowsAffected()
+	if updateErr != nil {
+		return false, updateErr
+	}
+	if affected > 0 {
+		return true, nil
+	}
+
+	var status string
+	statusErr := m.db.QueryRowContext(ctx, m.bind("SELECT status FROM "+m.options.NotificationTable+
+		" WHERE provider = ? AND notify_id = ?"), notification.Provider, notification.NotifyId).Scan(&status)
+	if errors.Is(statusErr, sql.ErrNoRows) {
+		return false, err
+	}
+	if statusErr != nil {
+		return false, statusErr
+	}
+	if NotificationStatus(status) == NotificationDone {
+		return false, nil
+	}
+	return false, ErrNotifyProcessing
+}
+
+func (m *SQLStore) CompleteNotification(ctx context.Context, provider, notifyId string) error {
+	_, err := m.db.ExecContext(ctx, m.bind("UPDATE "+m.options.NotificationTable+
+		" SET status = ? WHERE provider = ? AND notify_id = ?"), string(NotificationDone), provider, notifyId)
+	return err
+}
+
+func (m *SQLStore) DeleteNotification(ctx context.Context, provider, notifyId string) error {
+	_, err := m.db.ExecContext(ctx, m.bind("DELETE FROM "+m.options.NotificationTable+
+		" WHERE provider = ? AND notify_id = ?"), provider, notifyId)
+	return err
+}
+
+/**
+ * 将 ? 占位符替换为数据库对应的占位符
+ */
+func (m *SQLStore) bind(query string) string {
+	parts := strings.Split(query, "?")
+	builder := strings.Builder{}
+	for index, part := range parts {
+		if index > 0 {
+			builder.WriteString(m.options.Placeholder(index))
+		}
+		builder.WriteString(part)
+	}
+	return builder.String()
+}
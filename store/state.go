@@ -0,0 +1,88 @@
+package store
+
+import (
+	"fmt"
+)
+
+/**
+ * 统一的订单状态，支付宝与微信支付的交易状态均映射为该状态
+ */
+type State string
+
+const (
+	StatePending  State = "PENDING"  // 待支付，WAIT_BUYER_PAY、NOTPAY
+	StatePaying   State = "PAYING"   // 用户支付中，USERPAYING
+	StatePaid     State = "PAID"     // 支付成功，TRADE_SUCCESS、SUCCESS
+	StateRefunded State = "REFUNDED" // 已退款，可继续退款，REFUND
+	StateFinished State = "FINISHED" // 交易结束，不可退款，TRADE_FINISHED
+	StateClosed   State = "CLOSED"   // 已关闭或全额退款，TRADE_CLOSED、CLOSED、REVOKED
+	StateFailed   State = "FAILED"   // 支付失败，PAYERROR
+)
+
+/**
+ * 订单状态流转：PENDING -> PAYING|PAID|FINISHED|CLOSED|FAILED
+ *            PAYING -> PENDING|PAID|FINISHED|CLOSED|FAILED
+ *            PAID -> REFUNDED|FINISHED|CLOSED
+ *            REFUNDED -> FINISHED|CLOSED
+ *            FAILED -> CLOSED
+ */
+var transitions = map[State][]State{
+	StatePending:  {StatePaying, StatePaid, StateFinished, StateClosed, StateFailed},
+	StatePaying:   {StatePending, StatePaid, StateFinished, StateClosed, StateFailed},
+	StatePaid:     {StateRefunded, StateFinished, StateClosed},
+	StateRefunded: {StateFinished, StateClosed},
+	StateFailed:   {StateClosed},
+}
+
+/**
+ * 是否允许从 from 变更为 to，状态不变时允许
+ */
+func CanTransit(from, to State) bool {
+	if from == to {
+		return true
+	}
+	for _, state := range transitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * 支付宝交易状态 trade_status 转换为订单状态
+ */
+func AliPayState(tradeStatus string) (State, error) {
+	switch tradeStatus {
+	case "WAIT_BUYER_PAY":
+		return StatePending, nil
+	case "TRADE_SUCCESS":
+		return StatePaid, nil
+	case "TRADE_FINISHED":
+		return StateFinished, nil
+	case "TRADE_CLOSED":
+		return StateClosed, nil
+	}
+	return "", fmt.Errorf("%w: alipay %s", ErrUnknownState, tradeStatus)
+}
+
+/**
+ * 微信支付交易状态 trade_state 转换为订单状态
+ */
+func WxPayState(tradeState string) (State, error) {
+	switch tradeState {
+	case "NOTPAY":
+		return StatePending, nil
+	case "USERPAYING":
+		return StatePaying, nil
+	case "SUCCESS":
+		return StatePaid, nil
+	case "REFUND":
+		return StateRefunded, nil
+	case "CLOSED", "REVOKED":
+		return StateClosed, nil
+	case "PAYERROR":
+		return StateFailed, nil
+	}
+	return "", fmt.Errorf("%w: wxpay %s", ErrUnknownState, tradeState)
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shinmigo/gopay"
+)
+
+var (
+	ErrOrderNotFound     = errors.New("store: order not found")
+	ErrOrderExists       = errors.New("store: order already exists")
+	ErrInvalidTransition = errors.New("store: invalid state transition")
+	ErrUnknownState      = errors.New("store: unknown trade state")
+	ErrAmountMismatch    = errors.New("store: notification amount mismatch")
+	ErrNotifyProcessing  = errors.New("store: notification is being processed")
+)
+
+/**
+ * 处理中的通知超过该时间仍未完成时视为处理中断，渠道重发的通知会重新处理
+ */
+var ProcessingTimeout = 5 * time.Minute
+
+/**
+ * 通知处理状态
+ */
+type NotificationStatus string
+
+const (
+	NotificationProcessing NotificationStatus = "PROCESSING" // 处理中
+	NotificationDone       NotificationStatus = "DONE"       // 已处理
+)
+
+/**
+ * 支付订单，按支付渠道与商户订单号区分
+ */
+type Order struct {
+	Provider      string       // 支付渠道 alipay、wxpay
+	OutTradeNo    string       // 商户订单号
+	TransactionId string       // 渠道交易号，支付宝为 trade_no，微信支付为 transaction_id
+	State         State        // 订单状态
+	Amount        gopay.Amount // 订单金额，为0时不校验通知中的金额
+	CreatedAt     time.Time    // 创建时间
+	UpdatedAt     time.Time    // 更新时间
+}
+
+/**
+ * 异步通知处理记录，按支付渠道与通知ID去重
+ */
+type Notification struct {
+	Provider      string             // 支付渠道 alipay、wxpay
+	NotifyId      string             // 通知ID，支付宝为 notify_id，微信支付为 transaction_id
+	OutTradeNo    string             // 商户订单号
+	TransactionId string             // 渠道交易号
+	State         State              // 通知中的订单状态
+	Amount        gopay.Amount       // 通知中的订单金额
+	Status        NotificationStatus // 处理状态
+	ReceivedAt    time.Time          // 接收时间，重新领取处理中断的通知时更新
+}
+
+/**
+ * 订单存储，实现需保证 Transit 与 ClaimNotification 在并发下的原子性
+ */
+type Store interface {
+	// 创建订单，订单已存在时返回 ErrOrderExists
+	CreateOrder(ctx context.Context, order *Order) error
+	// 查询订单，不存在时返回 ErrOrderNotFound
+	GetOrder(ctx context.Context, provider, outTradeNo string) (*Order, error)
+	// 变更订单状态，返回变更后的订单与变更前的状态，不允许的变更返回 ErrInvalidTransition
+	// transactionId 为空时保留原渠道交易号
+	Transit(ctx context.Context, provider, outTradeNo string, state State, transactionId string) (*Order, State, error)
+	// 领取通知，不存在时记录为处理中并返回 true，已处理时返回 false
+	// 处理中且接收时间早于 staleBefore 时更新接收时间并返回 true，否则返回 ErrNotifyProcessing
+	ClaimNotification(ctx context.Context, notification *Notification, staleBefore time.Time) (bool, error)
+	// 标记通知已处理，订单更新与业务处理均成功后调用
+	CompleteNotification(ctx context.Context, provider, notifyId string) error
+	// 删除通知记录，业务处理失败时调用，渠道重发的通知会重新处理
+	DeleteNotification(ctx context.Context, provider, notifyId string) error
+}
+
+/**
+ * 通知处理结果
+ */
+type Event struct {
+	Notification *Notification // 通知
+	Order        *Order        // 更新后的订单，重复通知时为空
+	Previous     State         // 更新前的订单状态，订单由通知创建时为空
+	Duplicate    bool          // 重复通知，未执行业务处理
+	Stale        bool          // 订单状态已越过通知中的状态，如关闭后收到的支付通知，未执行业务处理
+}
+
+/**
+ * 通知的业务处理，返回错误时不确认通知
+ */
+type Handler func(ctx context.Context, event *Event) error
+
+/**
+ * 幂等处理已验证签名的异步通知
+ * 首次收到的通知会更新订单状态并执行 handler，重复通知与过期通知直接返回，均应向渠道确认
+ * 订单不存在时按通知创建订单；handler 返回错误时删除通知记录，渠道重发后重新处理
+ * 通知在 handler 成功后才标记为已处理，处理中断（如进程退出）的通知超过 ProcessingTimeout 后可重新处理，
+ * 此前收到的重发通知返回 ErrNotifyProcessing，不应向渠道确认
+ */
+func Process(ctx context.Context, orderStore Store, notification *Notification, handler Handler) (*Event, error) {
+	if notification.ReceivedAt.IsZero() {
+		notification.ReceivedAt = time.Now()
+	}
+	event := &Event{Notification: notification}
+
+	claimed, err := orderStore.ClaimNotification(ctx, notification, time.Now().Add(-ProcessingTimeout))
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		event.Duplicate = true
+		return event, nil
+	}
+
+	if err = apply(ctx, orderStore, event); err != nil {
+		if !errors.Is(err, ErrInvalidTransition) {
+			return nil, release(ctx, orderStore, notification, err)
+		}
+		event.Stale = true
+	} else if handler != nil {
+		if err = handler(ctx, event); err != nil {
+			return nil, release(ctx, orderStore, notification, err)
+		}
+	}
+	if err = orderStore.CompleteNotification(ctx, notification.Provider, notification.NotifyId); err != nil {
+		return nil, err
+	}
+	notification.Status = NotificationDone
+	return event, nil
+}
+
+/**
+ * 按通知更新订单状态
+ */
+func apply(ctx context.Context, orderStore Store, event *Event) error {
+	notification := event.Notification
+	order, err := orderStore.GetOrder(ctx, notification.Provider, notification.OutTradeNo)
+	if errors.Is(err, ErrOrderNotFound) {
+		order = &Order{
+			Provider:      notification.Provider,
+			OutTradeNo:    notification.OutTradeNo,
+			TransactionId: notification.TransactionId,
+			State:         notification.State,
+			Amount:        notification.Amount,
+		}
+		err = orderStore.CreateOrder(ctx, order)
+		if err == nil {
+			event.Order = order
+			return nil
+		}
+		if !errors.Is(err, ErrOrderExists) {
+			return err
+		}
+		order, err = orderStore.GetOrder(ctx, notification.Provider, notification.OutTradeNo)
+	}
+	if err != nil {
+		return err
+	}
+	if order.Amount != 0 && notification.Amount != 0 && order.Amount != notification.Amount {
+		return ErrAmountMismatch
+	}
+
+	event.Order, event.Previous, err = orderStore.Transit(ctx, notification.Provider, notification.OutTradeNo, notification.State, notification.TransactionId)
+	return err
+}
+
+func release(ctx context.Context, orderStore Store, notification *Notification, err error) error {
+	if deleteErr := orderStore.DeleteNotification(ctx, notification.Provider, notification.NotifyId); deleteErr != nil {
+		return fmt.Errorf("%w; delete notification: %v", err, deleteErr)
+	}
+	return err
+}
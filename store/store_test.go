@@ -0,0 +1,392 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shinmigo/gopay"
+	"github.com/shinmigo/gopay/store"
+
+	_ "modernc.org/sqlite"
+)
+
+/**
+ * 分别以内存存储与数据库存储运行测试，数据库使用内存中的 sqlite
+ */
+func runStores(t *testing.T, test func(t *testing.T, orderStore store.Store)) {
+	t.Run("MemoryStore", func(t *testing.T) {
+		test(t, store.NewMemoryStore())
+	})
+	t.Run("SQLStore", func(t *testing.T) {
+		db, err := sql.Open("sqlite", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		//每个连接是独立的内存数据库
+		db.SetMaxOpenConns(1)
+		sqlStore := store.NewSQLStore(db, nil)
+		if err = sqlStore.CreateTables(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		test(t, sqlStore)
+	})
+}
+
+func newNotification(notifyId, outTradeNo string, state store.State) *store.Notification {
+	return &store.Notification{
+		Provider:      "alipay",
+		NotifyId:      notifyId,
+		OutTradeNo:    outTradeNo,
+		TransactionId: "TX" + outTradeNo,
+		State:         state,
+		Amount:        gopay.Fen(100),
+	}
+}
+
+func TestCanTransit(t *testing.T) {
+	cases := []struct {
+		from, to store.State
+		allowed  bool
+	}{
+		{store.StatePending, store.StatePending, true},
+		{store.StatePending, store.StatePaying, true},
+		{store.StatePending, store.StatePaid, true},
+		{store.StatePending, store.StateFinished, true},
+		{store.StatePending, store.StateClosed, true},
+		{store.StatePending, store.StateFailed, true},
+		{store.StatePending, store.StateRefunded, false},
+		{store.StatePaying, store.StatePending, true},
+		{store.StatePaying, store.StatePaid, true},
+		{store.StatePaying, store.StateFinished, true},
+		{store.StatePaying, store.StateRefunded, false},
+		{store.StatePaid, store.StateRefunded, true},
+		{store.StatePaid, store.StateFinished, true},
+		{store.StatePaid, store.StateClosed, true},
+		{store.StatePaid, store.StatePending, false},
+		{store.StatePaid, store.StateFailed, false},
+		{store.StateRefunded, store.StateFinished, true},
+		{store.StateRefunded, store.StateClosed, true},
+		{store.StateRefunded, store.StatePaid, false},
+		{store.StateFailed, store.StateClosed, true},
+		{store.StateFailed, store.StatePaid, false},
+		{store.StateFinished, store.StateClosed, false},
+		{store.StateFinished, store.StateRefunded, false},
+		{store.StateClosed, store.StatePaid, false},
+		{store.StateClosed, store.StatePending, false},
+	}
+	for _, item := range cases {
+		if allowed := store.CanTransit(item.from, item.to); allowed != item.allowed {
+			t.Errorf("CanTransit(%s, %s) = %v, want %v", item.from, item.to, allowed, item.allowed)
+		}
+	}
+}
+
+func TestStateMapping(t *testing.T) {
+	aliPayCases := map[string]store.State{
+		"WAIT_BUYER_PAY": store.StatePending,
+		"TRADE_SUCCESS":  store.StatePaid,
+		"TRADE_FINISHED": store.StateFinished,
+		"TRADE_CLOSED":   store.StateClosed,
+	}
+	for tradeStatus, want := range aliPayCases {
+		if state, err := store.AliPayState(tradeStatus); err != nil || state != want {
+			t.Errorf("AliPayState(%s) = %s, %v, want %s", tradeStatus, state, err, want)
+		}
+	}
+	wxPayCases := map[string]store.State{
+		"NOTPAY":     store.StatePending,
+		"USERPAYING": store.StatePaying,
+		"SUCCESS":    store.StatePaid,
+		"REFUND":     store.StateRefunded,
+		"CLOSED":     store.StateClosed,
+		"REVOKED":    store.StateClosed,
+		"PAYERROR":   store.StateFailed,
+	}
+	for tradeState, want := range wxPayCases {
+		if state, err := store.WxPayState(tradeState); err != nil || state != want {
+			t.Errorf("WxPayState(%s) = %s, %v, want %s", tradeState, state, err, want)
+		}
+	}
+	if _, err := store.AliPayState("UNKNOWN"); !errors.Is(err, store.ErrUnknownState) {
+		t.Errorf("AliPayState(UNKNOWN) err = %v", err)
+	}
+	if _, err := store.WxPayState("UNKNOWN"); !errors.Is(err, store.ErrUnknownState) {
+		t.Errorf("WxPayState(UNKNOWN) err = %v", err)
+	}
+}
+
+func TestTransit(t *testing.T) {
+	runStores(t, func(t *testing.T, orderStore store.Store) {
+		ctx := context.Background()
+		order := &store.Order{Provider: "wxpay", OutTradeNo: "T1001", State: store.StatePending, Amount: gopay.Fen(100)}
+		if err := orderStore.CreateOrder(ctx, order); err != nil {
+			t.Fatal(err)
+		}
+		if err := orderStore.CreateOrder(ctx, order); !errors.Is(err, store.ErrOrderExists) {
+			t.Errorf("CreateOrder twice: got %v, want %v", err, store.ErrOrderExists)
+		}
+
+		updated, previous, err := orderStore.Transit(ctx, "wxpay", "T1001", store.StatePaid, "TX1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if previous != store.StatePending || updated.State != store.StatePaid || updated.TransactionId != "TX1" {
+			t.Errorf("Transit = %+v, %s", updated, previous)
+		}
+		//渠道交易号为空时保留原值
+		updated, previous, err = orderStore.Transit(ctx, "wxpay", "T1001", store.StateFinished, "")
+		if err != nil || previous != store.StatePaid || updated.TransactionId != "TX1" {
+			t.Errorf("Transit = %+v, %s, %v", updated, previous, err)
+		}
+
+		_, previous, err = orderStore.Transit(ctx, "wxpay", "T1001", store.StateClosed, "")
+		if !errors.Is(err, store.ErrInvalidTransition) || previous != store.StateFinished {
+			t.Errorf("Transit FINISHED -> CLOSED: got %s, %v, want %v", previous, err, store.ErrInvalidTransition)
+		}
+		stored, err := orderStore.GetOrder(ctx, "wxpay", "T1001")
+		if err != nil || stored.State != store.StateFinished || stored.Amount != gopay.Fen(100) {
+			t.Errorf("GetOrder = %+v, %v", stored, err)
+		}
+
+		if _, _, err = orderStore.Transit(ctx, "wxpay", "NOT_EXIST", store.StatePaid, ""); !errors.Is(err, store.ErrOrderNotFound) {
+			t.Errorf("Transit: got %v, want %v", err, store.ErrOrderNotFound)
+		}
+		if _, err = orderStore.GetOrder(ctx, "alipay", "T1001"); !errors.Is(err, store.ErrOrderNotFound) {
+			t.Errorf("GetOrder: got %v, want %v", err, store.ErrOrderNotFound)
+		}
+	})
+}
+
+func TestProcess(t *testing.T) {
+	runStores(t, func(t *testing.T, orderStore store.Store) {
+		ctx := context.Background()
+		if err := orderStore.CreateOrder(ctx, &store.Order{Provider: "alipay", OutTradeNo: "T2001", State: store.StatePending, Amount: gopay.Fen(100)}); err != nil {
+			t.Fatal(err)
+		}
+
+		calls := 0
+		handler := func(ctx context.Context, event *store.Event) error {
+			calls++
+			return nil
+		}
+		event, err := store.Process(ctx, orderStore, newNotification("N1", "T2001", store.StatePaid), handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Duplicate || event.Stale || event.Previous != store.StatePending || event.Order.State != store.StatePaid || event.Order.TransactionId != "TXT2001" {
+			t.Errorf("event = %+v, order = %+v", event, event.Order)
+		}
+		if event.Notification.Status != store.NotificationDone || calls != 1 {
+			t.Errorf("status = %s, calls = %d", event.Notification.Status, calls)
+		}
+
+		//订单不存在时按通知创建
+		event, err = store.Process(ctx, orderStore, newNotification("N2", "T2002", store.StateFinished), handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Previous != "" || event.Order.State != store.StateFinished || calls != 2 {
+			t.Errorf("event = %+v, calls = %d", event, calls)
+		}
+		if order, err := orderStore.GetOrder(ctx, "alipay", "T2002"); err != nil || order.Amount != gopay.Fen(100) {
+			t.Errorf("GetOrder = %+v, %v", order, err)
+		}
+
+		//不可退款的支付宝交易由 WAIT_BUYER_PAY 直接变为 TRADE_FINISHED
+		if err = orderStore.CreateOrder(ctx, &store.Order{Provider: "alipay", OutTradeNo: "T2003", State: store.StatePending}); err != nil {
+			t.Fatal(err)
+		}
+		event, err = store.Process(ctx, orderStore, newNotification("N3", "T2003", store.StateFinished), handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Stale || event.Previous != store.StatePending || event.Order.State != store.StateFinished || calls != 3 {
+			t.Errorf("event = %+v, calls = %d", event, calls)
+		}
+	})
+}
+
+func TestProcessDuplicate(t *testing.T) {
+	runStores(t, func(t *testing.T, orderStore store.Store) {
+		ctx := context.Background()
+		calls := 0
+		handler := func(ctx context.Context, event *store.Event) error {
+			calls++
+			return nil
+		}
+		for i := 0; i < 3; i++ {
+			event, err := store.Process(ctx, orderStore, newNotification("N1", "T3001", store.StatePaid), handler)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.Duplicate != (i > 0) {
+				t.Errorf("attempt %d: Duplicate = %v", i, event.Duplicate)
+			}
+			if event.Duplicate && event.Order != nil {
+				t.Errorf("attempt %d: duplicate event has order %+v", i, event.Order)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("handler calls = %d, want 1", calls)
+		}
+	})
+}
+
+func TestProcessStale(t *testing.T) {
+	runStores(t, func(t *testing.T, orderStore store.Store) {
+		ctx := context.Background()
+		if err := orderStore.CreateOrder(ctx, &store.Order{Provider: "alipay", OutTradeNo: "T4001", State: store.StateClosed}); err != nil {
+			t.Fatal(err)
+		}
+		calls := 0
+		handler := func(ctx context.Context, event *store.Event) error {
+			calls++
+			return nil
+		}
+		event, err := store.Process(ctx, orderStore, newNotification("N1", "T4001", store.StatePaid), handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !event.Stale || event.Duplicate || calls != 0 {
+			t.Errorf("event = %+v, calls = %d", event, calls)
+		}
+		if order, _ := orderStore.GetOrder(ctx, "alipay", "T4001"); order.State != store.StateClosed {
+			t.Errorf("state = %s, want %s", order.State, store.StateClosed)
+		}
+
+		//过期通知同样标记为已处理
+		event, err = store.Process(ctx, orderStore, newNotification("N1", "T4001", store.StatePaid), handler)
+		if err != nil || !event.Duplicate {
+			t.Errorf("event = %+v, %v", event, err)
+		}
+	})
+}
+
+func TestProcessHandlerFailure(t *testing.T) {
+	runStores(t, func(t *testing.T, orderStore store.Store) {
+		ctx := context.Background()
+		errHandler := errors.New("handler failed")
+		calls := 0
+		handler := func(ctx context.Context, event *store.Event) error {
+			calls++
+			if calls == 1 {
+				return errHandler
+			}
+			return nil
+		}
+		if _, err := store.Process(ctx, orderStore, newNotification("N1", "T5001", store.StatePaid), handler); !errors.Is(err, errHandler) {
+			t.Fatalf("Process: got %v, want %v", err, errHandler)
+		}
+
+		//通知记录已删除，重发的通知重新执行业务处理
+		event, err := store.Process(ctx, orderStore, newNotification("N1", "T5001", store.StatePaid), handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Duplicate || event.Stale || event.Order.State != store.StatePaid || calls != 2 {
+			t.Errorf("event = %+v, calls = %d", event, calls)
+		}
+	})
+}
+
+func TestProcessAmountMismatch(t *testing.T) {
+	runStores(t, func(t *testing.T, orderStore store.Store) {
+		ctx := context.Background()
+		if err := orderStore.CreateOrder(ctx, &store.Order{Provider: "alipay", OutTradeNo: "T6001", State: store.StatePending, Amount: gopay.Fen(200)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Process(ctx, orderStore, newNotification("N1", "T6001", store.StatePaid), nil); !errors.Is(err, store.ErrAmountMismatch) {
+			t.Fatalf("Process: got %v, want %v", err, store.ErrAmountMismatch)
+		}
+		if order, _ := orderStore.GetOrder(ctx, "alipay", "T6001"); order.State != store.StatePending {
+			t.Errorf("state = %s, want %s", order.State, store.StatePending)
+		}
+		//校验失败的通知未记录为已处理
+		claimed, err := orderStore.ClaimNotification(ctx, newNotification("N1", "T6001", store.StatePaid), time.Now())
+		if err != nil || !claimed {
+			t.Errorf("ClaimNotification = %v, %v", claimed, err)
+		}
+	})
+}
+
+func TestClaimNotification(t *testing.T) {
+	runStores(t, func(t *testing.T, orderStore store.Store) {
+		ctx := context.Background()
+		now := time.Now()
+		notification := newNotification("N1", "T7001", store.StatePaid)
+		notification.ReceivedAt = now
+		claimed, err := orderStore.ClaimNotification(ctx, notification, now.Add(-store.ProcessingTimeout))
+		if err != nil || !claimed || notification.Status != store.NotificationProcessing {
+			t.Fatalf("ClaimNotification = %v, %v, status %s", claimed, err, notification.Status)
+		}
+
+		//处理中且未超时
+		retry := newNotification("N1", "T7001", store.StatePaid)
+		retry.ReceivedAt = now.Add(time.Minute)
+		if _, err = orderStore.ClaimNotification(ctx, retry, retry.ReceivedAt.Add(-store.ProcessingTimeout)); !errors.Is(err, store.ErrNotifyProcessing) {
+			t.Errorf("ClaimNotification before timeout: got %v, want %v", err, store.ErrNotifyProcessing)
+		}
+
+		//超过 ProcessingTimeout 后重新领取，并更新接收时间
+		retry.ReceivedAt = now.Add(store.ProcessingTimeout + time.Minute)
+		claimed, err = orderStore.ClaimNotification(ctx, retry, retry.ReceivedAt.Add(-store.ProcessingTimeout))
+		if err != nil || !claimed {
+			t.Fatalf("ClaimNotification after timeout = %v, %v", claimed, err)
+		}
+		again := newNotification("N1", "T7001", store.StatePaid)
+		again.ReceivedAt = retry.ReceivedAt.Add(time.Minute)
+		if _, err = orderStore.ClaimNotification(ctx, again, again.ReceivedAt.Add(-store.ProcessingTimeout)); !errors.Is(err, store.ErrNotifyProcessing) {
+			t.Errorf("ClaimNotification after reclaim: got %v, want %v", err, store.ErrNotifyProcessing)
+		}
+
+		if err = orderStore.CompleteNotification(ctx, "alipay", "N1"); err != nil {
+			t.Fatal(err)
+		}
+		again.ReceivedAt = again.ReceivedAt.Add(2 * store.ProcessingTimeout)
+		claimed, err = orderStore.ClaimNotification(ctx, again, again.ReceivedAt.Add(-store.ProcessingTimeout))
+		if err != nil || claimed {
+			t.Errorf("ClaimNotification after complete = %v, %v, want false, nil", claimed, err)
+		}
+	})
+}
+
+func TestProcessReclaimsInterrupted(t *testing.T) {
+	runStores(t, func(t *testing.T, orderStore store.Store) {
+		ctx := context.Background()
+		//模拟处理中断：通知已领取但未完成
+		interrupted := newNotification("N1", "T8001", store.StatePaid)
+		interrupted.ReceivedAt = time.Now().Add(-store.ProcessingTimeout - time.Minute)
+		if claimed, err := orderStore.ClaimNotification(ctx, interrupted, interrupted.ReceivedAt.Add(-store.ProcessingTimeout)); err != nil || !claimed {
+			t.Fatalf("ClaimNotification = %v, %v", claimed, err)
+		}
+
+		calls := 0
+		event, err := store.Process(ctx, orderStore, newNotification("N1", "T8001", store.StatePaid), func(ctx context.Context, event *store.Event) error {
+			calls++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Duplicate || event.Order.State != store.StatePaid || calls != 1 {
+			t.Errorf("event = %+v, calls = %d", event, calls)
+		}
+
+		//处理中且未超时的重发通知不应确认
+		if err = orderStore.DeleteNotification(ctx, "alipay", "N1"); err != nil {
+			t.Fatal(err)
+		}
+		processing := newNotification("N1", "T8001", store.StatePaid)
+		processing.ReceivedAt = time.Now()
+		if claimed, err := orderStore.ClaimNotification(ctx, processing, time.Now().Add(-store.ProcessingTimeout)); err != nil || !claimed {
+			t.Fatalf("ClaimNotification = %v, %v", claimed, err)
+		}
+		if _, err = store.Process(ctx, orderStore, newNotification("N1", "T8001", store.StatePaid), nil); !errors.Is(err, store.ErrNotifyProcessing) {
+			t.Errorf("Process: got %v, want %v", err, store.ErrNotifyProcessing)
+		}
+	})
+}
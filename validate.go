@@ -0,0 +1,167 @@
+package gopay
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidParam = errors.New("gopay: invalid param")
+
+/**
+ * 请求参数校验失败的字段
+ */
+type FieldError struct {
+	Field   string // 参数名
+	Message string // 错误描述
+}
+
+func (m *FieldError) Error() string {
+	return m.Field + " " + m.Message
+}
+
+/**
+ * 请求参数校验错误，包含所有校验失败的字段
+ */
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (m *ValidationError) Error() string {
+	messages := make([]string, 0, len(m.Fields))
+	for _, field := range m.Fields {
+		messages = append(messages, field.Error())
+	}
+	return "gopay: invalid param: " + strings.Join(messages, "; ")
+}
+
+func (m *ValidationError) Unwrap() error {
+	return ErrInvalidParam
+}
+
+/**
+ * 请求参数校验器，收集所有校验失败的字段
+ */
+type Validator struct {
+	fields []*FieldError
+}
+
+/**
+ * 记录校验失败的字段
+ */
+func (m *Validator) Fail(field, format string, args ...interface{}) {
+	m.fields = append(m.fields, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+/**
+ * 条件不成立时记录校验失败的字段
+ */
+func (m *Validator) Check(ok bool, field, format string, args ...interface{}) {
+	if !ok {
+		m.Fail(field, format, args...)
+	}
+}
+
+/**
+ * 必填
+ */
+func (m *Validator) Required(field, value string) {
+	m.Check(len(value) > 0, field, "is required")
+}
+
+/**
+ * 最大长度，按字符计算
+ */
+func (m *Validator) MaxLength(field, value string, max int) {
+	m.Check(utf8.RuneCountInString(value) <= max, field, "exceeds %d characters", max)
+}
+
+/**
+ * 字符集或格式，为空时不校验
+ */
+func (m *Validator) Match(field, value string, pattern *regexp.Regexp) {
+	m.Check(value == "" || pattern.MatchString(value), field, "has invalid format")
+}
+
+/**
+ * 枚举值，为空时不校验
+ */
+func (m *Validator) OneOf(field, value string, values ...string) {
+	if value == "" {
+		return
+	}
+	for _, item := range values {
+		if value == item {
+			return
+		}
+	}
+	m.Fail(field, "must be one of %s", strings.Join(values, ", "))
+}
+
+/**
+ * 多个参数必须且只能传入一个，values 为参数名与参数值交替排列
+ */
+func (m *Validator) ExactlyOne(values ...string) {
+	names := make([]string, 0, len(values)/2)
+	count := 0
+	for i := 0; i+1 < len(values); i += 2 {
+		names = append(names, values[i])
+		if len(values[i+1]) > 0 {
+			count++
+		}
+	}
+	field := strings.Join(names, "|")
+	if count == 0 {
+		m.Fail(field, "one of them is required")
+	} else if count > 1 {
+		m.Fail(field, "are mutually exclusive")
+	}
+}
+
+/**
+ * 多个参数至少传入一个，values 为参数名与参数值交替排列
+ */
+func (m *Validator) AtLeastOne(values ...string) {
+	names := make([]string, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		if len(values[i+1]) > 0 {
+			return
+		}
+		names = append(names, values[i])
+	}
+	m.Fail(strings.Join(names, "|"), "one of them is required")
+}
+
+/**
+ * 金额范围
+ */
+func (m *Validator) Amount(field string, amount Amount, min, max Amount) {
+	m.Check(amount.Validate(min, max) == nil, field, "is out of range")
+}
+
+/**
+ * 以元为单位的金额字符串，为空时不校验
+ */
+func (m *Validator) Yuan(field, value string, min, max Amount) {
+	if value == "" {
+		return
+	}
+	amount, err := ParseYuan(value)
+	if err != nil {
+		m.Fail(field, "is not a valid amount")
+		return
+	}
+	m.Amount(field, amount, min, max)
+}
+
+/**
+ * 校验结果，没有错误时返回nil
+ */
+func (m *Validator) Err() error {
+	if len(m.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: m.fields}
+}
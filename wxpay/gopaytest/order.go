@@ -0,0 +1,124 @@
+package gopaytest
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	TradeStateSuccess    = "SUCCESS"    // 支付成功
+	TradeStateRefund     = "REFUND"     // 转入退款
+	TradeStateNotPay     = "NOTPAY"     // 未支付
+	TradeStateClosed     = "CLOSED"     // 已关闭
+	TradeStateUserPaying = "USERPAYING" // 用户支付中
+	TradeStatePayError   = "PAYERROR"   // 支付失败
+)
+
+var (
+	ErrOrderNotExist    = errors.New("gopaytest: order not exist")
+	ErrTradeStateError  = errors.New("gopaytest: trade state error")
+	ErrRefundFeeInvalid = errors.New("gopaytest: refund fee invalid")
+)
+
+/**
+ * 测试网关中的订单
+ */
+type Order struct {
+	TransactionId string             // 微信支付订单号
+	OutTradeNo    string             // 商户订单号
+	Body          string             // 商品描述
+	TotalFee      uint64             // 订单金额 分
+	TradeType     string             // 交易类型
+	TradeState    string             // 交易状态
+	OpenId        string             // 用户标识
+	SubMchId      string             // 服务商模式的子商户号
+	SubAppId      string             // 服务商模式的子商户应用ID
+	SubOpenId     string             // 用户在子商户应用下的标识
+	Attach        string             // 附加数据
+	PrepayId      string             // 预支付交易会话标识
+	NotifyUrl     string             // 异步通知地址
+	TimeEnd       string             // 支付完成时间
+	Refunds       map[string]*Refund // 退款记录，key为商户退款单号
+	RefundOrder   []string           // 退款单号顺序
+}
+
+/**
+ * 测试网关中的退款记录
+ */
+type Refund struct {
+	OutRefundNo string // 商户退款单号
+	RefundId    string // 微信退款单号
+	RefundFee   uint64 // 退款金额 分
+	Status      string // 退款状态
+}
+
+/**
+ * 订单状态流转：NOTPAY -> USERPAYING -> SUCCESS|PAYERROR|NOTPAY
+ *            NOTPAY -> SUCCESS|CLOSED
+ *            SUCCESS -> REFUND
+ */
+func (m *Order) transit(tradeState string) error {
+	allowed := false
+	switch m.TradeState {
+	case TradeStateNotPay:
+		allowed = tradeState == TradeStateSuccess || tradeState == TradeStateClosed || tradeState == TradeStateUserPaying
+	case TradeStateUserPaying:
+		allowed = tradeState == TradeStateSuccess || tradeState == TradeStatePayError || tradeState == TradeStateNotPay
+	case TradeStateSuccess:
+		allowed = tradeState == TradeStateRefund
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s -> %s", ErrTradeStateError, m.TradeState, tradeState)
+	}
+
+	m.TradeState = tradeState
+	return nil
+}
+
+/**
+ * 退款，同一商户退款单号重复请求时返回已有的退款记录
+ */
+func (m *Order) refund(outRefundNo, refundId string, refundFee uint64) (*Refund, error) {
+	if refund, ok := m.Refunds[outRefundNo]; ok {
+		return refund, nil
+	}
+	if m.TradeState != TradeStateSuccess && m.TradeState != TradeStateRefund {
+		return nil, ErrTradeStateError
+	}
+	if refundFee == 0 || m.RefundFee()+refundFee > m.TotalFee {
+		return nil, ErrRefundFeeInvalid
+	}
+
+	refund := &Refund{
+		OutRefundNo: outRefundNo,
+		RefundId:    refundId,
+		RefundFee:   refundFee,
+		Status:      "SUCCESS",
+	}
+	m.Refunds[outRefundNo] = refund
+	m.RefundOrder = append(m.RefundOrder, outRefundNo)
+	m.TradeState = TradeStateRefund
+	return refund, nil
+}
+
+/**
+ * 累计退款金额 分
+ */
+func (m *Order) RefundFee() uint64 {
+	var refundFee uint64
+	for _, refund := range m.Refunds {
+		refundFee += refund.RefundFee
+	}
+	return refundFee
+}
+
+func (m *Order) clone() *Order {
+	order := *m
+	order.Refunds = make(map[string]*Refund, len(m.Refunds))
+	for outRefundNo, refund := range m.Refunds {
+		refundCopy := *refund
+		order.Refunds[outRefundNo] = &refundCopy
+	}
+	order.RefundOrder = append([]string(nil), m.RefundOrder...)
+	return &order
+}
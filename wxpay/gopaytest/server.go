@@ -0,0 +1,554 @@
+package gopaytest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shinmigo/gopay/wxpay/kernel"
+)
+
+/**
+ * 测试网关配置
+ */
+type Options struct {
+	AppId     string // 应用ID，默认 wx0000000000000000
+	MchId     string // 商户号，默认 1900000000
+	Md5Key    string // MD5key，默认随机生成
+	NotifyUrl string // 异步通知地址，为空时使用下单请求中的notify_url
+	SignType  string // 响应与通知的签名类型 MD5、HMAC-SHA256，默认MD5
+}
+
+/**
+ * 本地微信支付测试网关
+ */
+type Server struct {
+	URL string // 网关地址，可通过 WxClient.SetGatewayHost 使用
+
+	options    Options
+	httpServer *httptest.Server
+	mutex      sync.Mutex
+	orders     map[string]*Order
+	sequence   int64
+}
+
+/**
+ * 启动本地微信支付测试网关
+ */
+func NewServer(options *Options) *Server {
+	server := &Server{
+		orders: make(map[string]*Order),
+	}
+	if options != nil {
+		server.options = *options
+	}
+	if len(server.options.AppId) == 0 {
+		server.options.AppId = "wx0000000000000000"
+	}
+	if len(server.options.MchId) == 0 {
+		server.options.MchId = "1900000000"
+	}
+	if len(server.options.Md5Key) == 0 {
+		server.options.Md5Key = fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	if len(server.options.SignType) == 0 {
+		server.options.SignType = kernel.SignTypeMD5
+	}
+
+	server.httpServer = httptest.NewServer(http.HandlerFunc(server.serveGateway))
+	server.URL = server.httpServer.URL + "/"
+	return server
+}
+
+/**
+ * 关闭测试网关
+ */
+func (m *Server) Close() {
+	m.httpServer.Close()
+}
+
+/**
+ * 初始化指向测试网关的微信支付客户端
+ */
+func (m *Server) Client() *kernel.WxClient {
+	client := kernel.NewWxClient(m.options.AppId, m.options.MchId, m.options.Md5Key, true)
+	client.SetGatewayHost(m.URL)
+	client.SetSignType(m.options.SignType)
+	return client
+}
+
+/**
+ * 查询订单当前状态
+ */
+func (m *Server) Order(outTradeNo string) (*Order, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	order, ok := m.orders[outTradeNo]
+	if !ok {
+		return nil, false
+	}
+	return order.clone(), true
+}
+
+/**
+ * 模拟用户付款成功，并发送支付结果通知
+ */
+func (m *Server) Pay(outTradeNo string) error {
+	return m.Transit(outTradeNo, TradeStateSuccess)
+}
+
+/**
+ * 变更订单状态，变更为 SUCCESS 时发送支付结果通知
+ */
+func (m *Server) Transit(outTradeNo, tradeState string) error {
+	m.mutex.Lock()
+	order, ok := m.orders[outTradeNo]
+	if !ok {
+		m.mutex.Unlock()
+		return ErrOrderNotExist
+	}
+	if err := order.transit(tradeState); err != nil {
+		m.mutex.Unlock()
+		return err
+	}
+	if tradeState == TradeStateSuccess {
+		order.TimeEnd = time.Now().Format("20060102150405")
+		if len(order.OpenId) == 0 {
+			order.OpenId = "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"
+		}
+	}
+	order = order.clone()
+	m.mutex.Unlock()
+
+	if tradeState != TradeStateSuccess {
+		return nil
+	}
+	return m.sendNotify(order)
+}
+
+/**
+ * 重新发送支付结果通知
+ */
+func (m *Server) Notify(outTradeNo string) error {
+	m.mutex.Lock()
+	order, ok := m.orders[outTradeNo]
+	if !ok {
+		m.mutex.Unlock()
+		return ErrOrderNotExist
+	}
+	order = order.clone()
+	m.mutex.Unlock()
+
+	return m.sendNotify(order)
+}
+
+/**
+ * 生成签名后的支付结果通知报文
+ */
+func (m *Server) NotifyXml(order *Order) []byte {
+	notifyData := m.orderValues(order)
+	notifyData.Set("nonce_str", m.nonceStr())
+	return m.signedXml(notifyData)
+}
+
+/**
+ * 生成签名后的扫码支付模式一商品回调报文
+ */
+func (m *Server) NativeCallbackXml(productId, openId string) []byte {
+	callbackData := url.Values{}
+	callbackData.Set("appid", m.options.AppId)
+	callbackData.Set("mch_id", m.options.MchId)
+	callbackData.Set("openid", openId)
+	callbackData.Set("product_id", productId)
+	callbackData.Set("is_subscribe", "N")
+	callbackData.Set("nonce_str", m.nonceStr())
+	callbackData.Set("sign", m.sign(callbackData, kernel.SignTypeMD5))
+	return []byte(kernel.EncodeXml(callbackData))
+}
+
+/**
+ * 验证商户响应报文的签名，报文中未包含 sign_type 时按MD5验证
+ */
+func (m *Server) VerifyXml(data []byte) (kernel.XmlToMap, error) {
+	params := make(kernel.XmlToMap)
+	if err := xml.Unmarshal(data, &params); err != nil {
+		return nil, err
+	}
+	if !m.verify(params) {
+		return nil, fmt.Errorf("gopaytest: invalid sign: %s", data)
+	}
+	return params, nil
+}
+
+func (m *Server) sendNotify(order *Order) error {
+	if len(order.NotifyUrl) == 0 {
+		return nil
+	}
+	response, err := http.Post(order.NotifyUrl, "application/xml;charset=utf-8", bytes.NewReader(m.NotifyXml(order)))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	responseByte, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	replyData := make(kernel.XmlToMap)
+	if err = xml.Unmarshal(responseByte, &replyData); err != nil || replyData.Get("return_code") != "SUCCESS" {
+		return fmt.Errorf("gopaytest: notify not acknowledged: %s", responseByte)
+	}
+	return nil
+}
+
+/**
+ * 网关入口
+ */
+func (m *Server) serveGateway(writer http.ResponseWriter, request *http.Request) {
+	requestByte, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := make(kernel.XmlToMap)
+	if err = xml.Unmarshal(requestByte, &params); err != nil {
+		m.writeFail(writer, "XML格式错误")
+		return
+	}
+	if params.Get("mch_id") != m.options.MchId {
+		m.writeFail(writer, "mch_id参数格式错误")
+		return
+	}
+	api := strings.TrimPrefix(request.URL.Path, "/")
+	api = strings.TrimPrefix(api, "sandboxnew/")
+	if api == "pay/getsignkey" {
+		responseData := url.Values{}
+		responseData.Set("return_code", "SUCCESS")
+		responseData.Set("return_msg", "ok")
+		responseData.Set("mch_id", m.options.MchId)
+		responseData.Set("sandbox_signkey", m.options.Md5Key)
+		m.writeXml(writer, responseData)
+		return
+	}
+	//long_url 传输时经过URL编码，签名使用原串
+	if api == "tools/shorturl" {
+		longUrl, err := url.QueryUnescape(params.Get("long_url"))
+		if err != nil {
+			m.writeFail(writer, "long_url参数格式错误")
+			return
+		}
+		params.Set("long_url", longUrl)
+	}
+	if !m.verify(params) {
+		m.writeFail(writer, "签名错误")
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	switch api {
+	case "pay/unifiedorder":
+		m.unifiedOrder(writer, params)
+	case "pay/orderquery":
+		order, ok := m.findOrder(params)
+		if !ok {
+			m.writeBizError(writer, "ORDERNOTEXIST", "订单不存在")
+			return
+		}
+		responseData := m.orderValues(order)
+		responseData.Set("trade_state_desc", order.TradeState)
+		m.writeSuccess(writer, responseData)
+	case "pay/closeorder":
+		order, ok := m.findOrder(params)
+		if !ok {
+			m.writeBizError(writer, "ORDERNOTEXIST", "订单不存在")
+			return
+		}
+		if order.TradeState == TradeStateSuccess || order.TradeState == TradeStateRefund {
+			m.writeBizError(writer, "ORDERPAID", "订单已支付")
+			return
+		}
+		if order.TradeState != TradeStateClosed {
+			if err = order.transit(TradeStateClosed); err != nil {
+				m.writeBizError(writer, "SYSTEMERROR", err.Error())
+				return
+			}
+		}
+		m.writeSuccess(writer, url.Values{})
+	case "pay/refund", "secapi/pay/refund":
+		m.refund(writer, params)
+	case "pay/refundquery":
+		m.refundQuery(writer, params)
+	case "tools/shorturl":
+		hashByte := md5.Sum([]byte(params.Get("long_url")))
+		responseData := url.Values{}
+		responseData.Set("short_url", "weixin://wxpay/s/"+hex.EncodeToString(hashByte[:4]))
+		m.writeSuccess(writer, responseData)
+	default:
+		http.NotFound(writer, request)
+	}
+}
+
+func (m *Server) unifiedOrder(writer http.ResponseWriter, params kernel.XmlToMap) {
+	outTradeNo := params.Get("out_trade_no")
+	totalFee, err := strconv.ParseUint(params.Get("total_fee"), 10, 64)
+	if len(outTradeNo) == 0 || err != nil || totalFee == 0 {
+		m.writeBizError(writer, "PARAM_ERROR", "参数错误")
+		return
+	}
+	order, ok := m.orders[outTradeNo]
+	if ok && order.TradeState != TradeStateNotPay {
+		m.writeBizError(writer, "ORDERPAID", "商户订单已支付")
+		return
+	}
+	if !ok {
+		m.sequence++
+		notifyUrl := m.options.NotifyUrl
+		if len(notifyUrl) == 0 {
+			notifyUrl = params.Get("notify_url")
+		}
+		order = &Order{
+			TransactionId: fmt.Sprintf("4200%s%010d", time.Now().Format("20060102"), m.sequence),
+			OutTradeNo:    outTradeNo,
+			Body:          params.Get("body"),
+			TotalFee:      totalFee,
+			TradeType:     params.Get("trade_type"),
+			TradeState:    TradeStateNotPay,
+			OpenId:        params.Get("openid"),
+			SubMchId:      params.Get("sub_mch_id"),
+			SubAppId:      params.Get("sub_appid"),
+			SubOpenId:     params.Get("sub_openid"),
+			Attach:        params.Get("attach"),
+			PrepayId:      fmt.Sprintf("wx%s%010d", time.Now().Format("20060102150405"), m.sequence),
+			NotifyUrl:     notifyUrl,
+			Refunds:       make(map[string]*Refund),
+		}
+		m.orders[outTradeNo] = order
+	}
+
+	responseData := url.Values{}
+	responseData.Set("sub_mch_id", order.SubMchId)
+	responseData.Set("sub_appid", order.SubAppId)
+	responseData.Set("trade_type", order.TradeType)
+	responseData.Set("prepay_id", order.PrepayId)
+	if order.TradeType == "NATIVE" {
+		responseData.Set("code_url", "weixin://wxpay/bizpayurl?pr="+order.PrepayId)
+	}
+	if order.TradeType == "MWEB" {
+		responseData.Set("mweb_url", "https://wx.tenpay.com/cgi-bin/mmpayweb-bin/checkmweb?prepay_id="+order.PrepayId)
+	}
+	m.writeSuccess(writer, responseData)
+}
+
+func (m *Server) refund(writer http.ResponseWriter, params kernel.XmlToMap) {
+	order, ok := m.findOrder(params)
+	if !ok {
+		m.writeBizError(writer, "ORDERNOTEXIST", "订单不存在")
+		return
+	}
+	refundFee, err := strconv.ParseUint(params.Get("refund_fee"), 10, 64)
+	if err != nil {
+		m.writeBizError(writer, "PARAM_ERROR", "参数错误")
+		return
+	}
+	m.sequence++
+	refund, err := order.refund(params.Get("out_refund_no"), fmt.Sprintf("5000%s%010d", time.Now().Format("20060102"), m.sequence), refundFee)
+	if err == ErrTradeStateError {
+		m.writeBizError(writer, "TRADE_STATE_ERROR", "订单状态错误")
+		return
+	}
+	if err != nil {
+		m.writeBizError(writer, "INVALID_REQUEST", "退款金额超限")
+		return
+	}
+
+	responseData := url.Values{}
+	responseData.Set("sub_mch_id", order.SubMchId)
+	responseData.Set("sub_appid", order.SubAppId)
+	responseData.Set("transaction_id", order.TransactionId)
+	responseData.Set("out_trade_no", order.OutTradeNo)
+	responseData.Set("out_refund_no", refund.OutRefundNo)
+	responseData.Set("refund_id", refund.RefundId)
+	responseData.Set("refund_fee", strconv.FormatUint(refund.RefundFee, 10))
+	responseData.Set("total_fee", strconv.FormatUint(order.TotalFee, 10))
+	responseData.Set("cash_fee", strconv.FormatUint(order.TotalFee, 10))
+	m.writeSuccess(writer, responseData)
+}
+
+func (m *Server) refundQuery(writer http.ResponseWriter, params kernel.XmlToMap) {
+	order, ok := m.findOrder(params)
+	if !ok {
+		m.writeBizError(writer, "REFUNDNOTEXIST", "退款订单查询失败")
+		return
+	}
+	refundNos := order.RefundOrder
+	if outRefundNo := params.Get("out_refund_no"); len(outRefundNo) > 0 {
+		if _, ok := order.Refunds[outRefundNo]; !ok {
+			m.writeBizError(writer, "REFUNDNOTEXIST", "退款订单查询失败")
+			return
+		}
+		refundNos = []string{outRefundNo}
+	}
+	if len(refundNos) == 0 {
+		m.writeBizError(writer, "REFUNDNOTEXIST", "退款订单查询失败")
+		return
+	}
+
+	responseData := url.Values{}
+	responseData.Set("transaction_id", order.TransactionId)
+	responseData.Set("out_trade_no", order.OutTradeNo)
+	responseData.Set("total_fee", strconv.FormatUint(order.TotalFee, 10))
+	responseData.Set("cash_fee", strconv.FormatUint(order.TotalFee, 10))
+	responseData.Set("refund_count", strconv.Itoa(len(refundNos)))
+	for index, outRefundNo := range refundNos {
+		refund := order.Refunds[outRefundNo]
+		suffix := "_" + strconv.Itoa(index)
+		responseData.Set("out_refund_no"+suffix, refund.OutRefundNo)
+		responseData.Set("refund_id"+suffix, refund.RefundId)
+		responseData.Set("refund_fee"+suffix, strconv.FormatUint(refund.RefundFee, 10))
+		responseData.Set("refund_status"+suffix, refund.Status)
+	}
+	m.writeSuccess(writer, responseData)
+}
+
+func (m *Server) findOrder(params kernel.XmlToMap) (*Order, bool) {
+	if outTradeNo := params.Get("out_trade_no"); len(outTradeNo) > 0 {
+		order, ok := m.orders[outTradeNo]
+		return order, ok
+	}
+	transactionId := params.Get("transaction_id")
+	for _, order := range m.orders {
+		if len(transactionId) > 0 && order.TransactionId == transactionId {
+			return order, true
+		}
+	}
+	return nil, false
+}
+
+func (m *Server) orderValues(order *Order) url.Values {
+	orderData := url.Values{}
+	orderData.Set("return_code", "SUCCESS")
+	orderData.Set("result_code", "SUCCESS")
+	orderData.Set("appid", m.options.AppId)
+	orderData.Set("mch_id", m.options.MchId)
+	orderData.Set("sub_mch_id", order.SubMchId)
+	orderData.Set("sub_appid", order.SubAppId)
+	orderData.Set("sub_openid", order.SubOpenId)
+	orderData.Set("out_trade_no", order.OutTradeNo)
+	orderData.Set("transaction_id", order.TransactionId)
+	orderData.Set("trade_type", order.TradeType)
+	orderData.Set("trade_state", order.TradeState)
+	orderData.Set("total_fee", strconv.FormatUint(order.TotalFee, 10))
+	orderData.Set("fee_type", "CNY")
+	orderData.Set("openid", order.OpenId)
+	orderData.Set("attach", order.Attach)
+	if len(order.TimeEnd) > 0 {
+		orderData.Set("bank_type", "OTHERS")
+		orderData.Set("cash_fee", strconv.FormatUint(order.TotalFee, 10))
+		orderData.Set("time_end", order.TimeEnd)
+		orderData.Set("is_subscribe", "N")
+	}
+	return orderData
+}
+
+func (m *Server) writeSuccess(writer http.ResponseWriter, responseData url.Values) {
+	responseData.Set("return_code", "SUCCESS")
+	responseData.Set("return_msg", "OK")
+	responseData.Set("result_code", "SUCCESS")
+	responseData.Set("appid", m.options.AppId)
+	responseData.Set("mch_id", m.options.MchId)
+	responseData.Set("nonce_str", m.nonceStr())
+	m.writeXml(writer, m.signedValues(responseData))
+}
+
+func (m *Server) writeBizError(writer http.ResponseWriter, errCode, errCodeDes string) {
+	responseData := url.Values{}
+	responseData.Set("return_code", "SUCCESS")
+	responseData.Set("return_msg", "OK")
+	responseData.Set("result_code", "FAIL")
+	responseData.Set("err_code", errCode)
+	responseData.Set("err_code_des", errCodeDes)
+	responseData.Set("appid", m.options.AppId)
+	responseData.Set("mch_id", m.options.MchId)
+	responseData.Set("nonce_str", m.nonceStr())
+	m.writeXml(writer, m.signedValues(responseData))
+}
+
+func (m *Server) writeFail(writer http.ResponseWriter, returnMsg string) {
+	responseData := url.Values{}
+	responseData.Set("return_code", "FAIL")
+	responseData.Set("return_msg", returnMsg)
+	m.writeXml(writer, responseData)
+}
+
+func (m *Server) writeXml(writer http.ResponseWriter, responseData url.Values) {
+	writer.Header().Set("Content-Type", "application/xml;charset=utf-8")
+	_, _ = writer.Write([]byte(kernel.EncodeXml(responseData)))
+}
+
+func (m *Server) signedValues(params url.Values) url.Values {
+	for paramKey := range params {
+		if len(params.Get(paramKey)) == 0 {
+			delete(params, paramKey)
+		}
+	}
+	params.Set("sign", m.sign(params, m.options.SignType))
+	return params
+}
+
+func (m *Server) signedXml(params url.Values) []byte {
+	return []byte(kernel.EncodeXml(m.signedValues(params)))
+}
+
+/**
+ * 按请求中的 sign_type 验证签名，未包含时按MD5验证
+ */
+func (m *Server) verify(params kernel.XmlToMap) bool {
+	signType := params.Get("sign_type")
+	if len(signType) == 0 {
+		signType = kernel.SignTypeMD5
+	}
+	return params.Get("sign") == m.sign(url.Values(params), signType)
+}
+
+/**
+ * MD5、HMAC-SHA256签名，与 WxClient 一致
+ */
+func (m *Server) sign(params url.Values, signType string) string {
+	paramList := make([]string, 0, len(params))
+	for paramKey := range params {
+		paramValue := strings.TrimSpace(params.Get(paramKey))
+		if paramKey == "sign" || len(paramValue) == 0 {
+			continue
+		}
+		paramList = append(paramList, paramKey+"="+paramValue)
+	}
+	sort.Strings(paramList)
+	paramList = append(paramList, "key="+m.options.Md5Key)
+
+	hashHandler := md5.New()
+	if signType == kernel.SignTypeHMACSHA256 {
+		hashHandler = hmac.New(sha256.New, []byte(m.options.Md5Key))
+	}
+	hashHandler.Write([]byte(strings.Join(paramList, "&")))
+	return strings.ToUpper(hex.EncodeToString(hashHandler.Sum(nil)))
+}
+
+func (m *Server) nonceStr() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
@@ -0,0 +1,212 @@
+package gopaytest_test
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shinmigo/gopay"
+	"github.com/shinmigo/gopay/wxpay/gopaytest"
+	"github.com/shinmigo/gopay/wxpay/kernel"
+	"github.com/shinmigo/gopay/wxpay/payment"
+)
+
+/**
+ * 分别以 MD5 与 HMAC-SHA256 签名启动测试网关，未设置通知地址时通知直接确认
+ */
+func runSignTypes(t *testing.T, options gopaytest.Options, test func(t *testing.T, server *gopaytest.Server, pay *payment.Payment)) {
+	if len(options.NotifyUrl) == 0 {
+		notifyServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			_, _ = writer.Write([]byte("<xml><return_code><![CDATA[SUCCESS]]></return_code></xml>"))
+		}))
+		defer notifyServer.Close()
+		options.NotifyUrl = notifyServer.URL
+	}
+	for _, signType := range []string{kernel.SignTypeMD5, kernel.SignTypeHMACSHA256} {
+		t.Run(signType, func(t *testing.T) {
+			options.SignType = signType
+			server := gopaytest.NewServer(&options)
+			defer server.Close()
+			test(t, server, &payment.Payment{Client: server.Client()})
+		})
+	}
+}
+
+func newTrade(outTradeNo string, totalFee uint64) *payment.Trade {
+	return &payment.Trade{
+		Body:           "测试",
+		OutTradeNo:     outTradeNo,
+		TotalFee:       totalFee,
+		SpbillCreateIp: "127.0.0.1",
+		NotifyUrl:      "https://example.com/notify",
+		TradeType:      "NATIVE",
+		ProductId:      "P1",
+	}
+}
+
+func TestPayAndQuery(t *testing.T) {
+	runSignTypes(t, gopaytest.Options{}, func(t *testing.T, server *gopaytest.Server, pay *payment.Payment) {
+		res, err := pay.Pay(newTrade("0012", 100))
+		if err != nil {
+			t.Fatalf("Pay: %v", err)
+		}
+		if len(res.PrepayId) == 0 || len(res.CodeUrl) == 0 {
+			t.Errorf("Pay = %+v", res)
+		}
+		order, ok := server.Order("0012")
+		if !ok || order.TotalFee != 100 || order.TradeState != gopaytest.TradeStateNotPay {
+			t.Fatalf("order = %+v, %v", order, ok)
+		}
+
+		if err = server.Pay("0012"); err != nil {
+			t.Fatal(err)
+		}
+		queryRes, err := pay.Query(&payment.TradeQuery{OutTradeNo: "0012"})
+		if err != nil {
+			t.Fatalf("Query: %v", err)
+		}
+		if queryRes.TradeState != gopaytest.TradeStateSuccess || queryRes.TransactionId != order.TransactionId || queryRes.TotalFee != 100 || queryRes.TotalAmount != gopay.Fen(100) {
+			t.Errorf("Query = %+v", queryRes)
+		}
+
+		_, err = pay.Query(&payment.TradeQuery{OutTradeNo: "NOT_EXIST"})
+		if !errors.Is(err, gopay.ErrOrderNotExist) {
+			t.Errorf("Query: got %v, want %v", err, gopay.ErrOrderNotExist)
+		}
+	})
+}
+
+func TestClose(t *testing.T) {
+	runSignTypes(t, gopaytest.Options{}, func(t *testing.T, server *gopaytest.Server, pay *payment.Payment) {
+		if _, err := pay.Pay(newTrade("T1001", 100)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pay.Close(&payment.TradeClose{OutTradeNo: "T1001"}); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		if order, _ := server.Order("T1001"); order.TradeState != gopaytest.TradeStateClosed {
+			t.Errorf("trade_state = %s", order.TradeState)
+		}
+
+		if _, err := pay.Pay(newTrade("T1002", 100)); err != nil {
+			t.Fatal(err)
+		}
+		if err := server.Pay("T1002"); err != nil {
+			t.Fatal(err)
+		}
+		_, err := pay.Close(&payment.TradeClose{OutTradeNo: "T1002"})
+		if !errors.Is(err, gopay.ErrOrderPaid) {
+			t.Errorf("Close paid order: got %v, want %v", err, gopay.ErrOrderPaid)
+		}
+	})
+}
+
+func TestRefundAndRefundQuery(t *testing.T) {
+	runSignTypes(t, gopaytest.Options{}, func(t *testing.T, server *gopaytest.Server, pay *payment.Payment) {
+		if _, err := pay.Pay(newTrade("T2001", 1000)); err != nil {
+			t.Fatal(err)
+		}
+		if err := server.Pay("T2001"); err != nil {
+			t.Fatal(err)
+		}
+
+		refundRes, err := pay.Refund(&payment.Refund{OutTradeNo: "T2001", OutRefundNo: "R1", TotalFee: 1000, RefundAmount: gopay.Fen(300)})
+		if err != nil {
+			t.Fatalf("Refund: %v", err)
+		}
+		if refundRes.RefundAmount != gopay.Fen(300) || refundRes.TotalAmount != gopay.Fen(1000) || refundRes.OutRefundNo != "R1" || len(refundRes.RefundId) == 0 {
+			t.Errorf("Refund = %+v", refundRes)
+		}
+		if _, err = pay.Refund(&payment.Refund{OutTradeNo: "T2001", OutRefundNo: "R2", TotalFee: 1000, RefundFee: 200}); err != nil {
+			t.Fatalf("Refund: %v", err)
+		}
+
+		queryRes, err := pay.RefundQuery(&payment.RefundQuery{OutTradeNo: "T2001"})
+		if err != nil {
+			t.Fatalf("RefundQuery: %v", err)
+		}
+		if queryRes.RefundCount != 2 || len(queryRes.Refunds) != 2 {
+			t.Fatalf("RefundQuery = %+v", queryRes)
+		}
+		if queryRes.Refunds[0].OutRefundNo != "R1" || queryRes.Refunds[0].RefundAmount != gopay.Fen(300) || queryRes.Refunds[1].RefundFee != 200 {
+			t.Errorf("refunds = %+v, %+v", queryRes.Refunds[0], queryRes.Refunds[1])
+		}
+
+		_, err = pay.RefundQuery(&payment.RefundQuery{OutTradeNo: "T2001", OutRefundNo: "R3"})
+		if !errors.Is(err, gopay.ErrOrderNotExist) {
+			t.Errorf("RefundQuery: got %v, want %v", err, gopay.ErrOrderNotExist)
+		}
+	})
+}
+
+func TestTransit(t *testing.T) {
+	runSignTypes(t, gopaytest.Options{}, func(t *testing.T, server *gopaytest.Server, pay *payment.Payment) {
+		if _, err := pay.Pay(newTrade("T3001", 100)); err != nil {
+			t.Fatal(err)
+		}
+		for _, tradeState := range []string{gopaytest.TradeStateUserPaying, gopaytest.TradeStateNotPay, gopaytest.TradeStateClosed} {
+			if err := server.Transit("T3001", tradeState); err != nil {
+				t.Fatalf("Transit %s: %v", tradeState, err)
+			}
+			queryRes, err := pay.Query(&payment.TradeQuery{OutTradeNo: "T3001"})
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if queryRes.TradeState != tradeState {
+				t.Errorf("trade_state = %s, want %s", queryRes.TradeState, tradeState)
+			}
+		}
+
+		err := server.Transit("T3001", gopaytest.TradeStateSuccess)
+		if !errors.Is(err, gopaytest.ErrTradeStateError) {
+			t.Errorf("Transit CLOSED -> SUCCESS: got %v, want %v", err, gopaytest.ErrTradeStateError)
+		}
+	})
+}
+
+func TestNotifyVerify(t *testing.T) {
+	var pay *payment.Payment
+	notifies := make(chan *payment.NotifyRes, 2)
+	notifyServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		reqBody, _ := ioutil.ReadAll(request.Body)
+		reply := &payment.NotifyReply{ReturnCode: "SUCCESS", ReturnMsg: "OK"}
+		res, err := pay.NotifyVerify(reqBody)
+		if err != nil {
+			reply = &payment.NotifyReply{ReturnCode: "FAIL", ReturnMsg: err.Error()}
+		} else {
+			notifies <- res
+		}
+		replyByte, _ := xml.Marshal(reply)
+		_, _ = writer.Write(replyByte)
+	}))
+	defer notifyServer.Close()
+
+	runSignTypes(t, gopaytest.Options{NotifyUrl: notifyServer.URL}, func(t *testing.T, server *gopaytest.Server, client *payment.Payment) {
+		pay = client
+		trade := newTrade("T4001", 100)
+		trade.Attach = "0012"
+		if _, err := pay.Pay(trade); err != nil {
+			t.Fatal(err)
+		}
+		if err := server.Pay("T4001"); err != nil {
+			t.Fatalf("Pay: %v", err)
+		}
+		res := <-notifies
+		order, _ := server.Order("T4001")
+		if res.OutTradeNo != "T4001" || res.TransactionId != order.TransactionId || res.TotalAmount != gopay.Fen(100) || res.Attach != "0012" {
+			t.Errorf("notify = %+v", res)
+		}
+
+		notifyXml := server.NotifyXml(order)
+		if _, err := pay.NotifyVerify(notifyXml); err != nil {
+			t.Errorf("NotifyVerify: %v", err)
+		}
+		tampered := []byte(string(notifyXml[:len(notifyXml)-len("</xml>")]) + "<total_fee>1</total_fee></xml>")
+		if _, err := pay.NotifyVerify(tampered); err == nil {
+			t.Error("tampered notify passed verification")
+		}
+	})
+}
@@ -2,10 +2,13 @@ package kernel
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
 	"encoding/hex"
 	"encoding/xml"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -14,20 +17,50 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/shinmigo/gopay"
 )
 
+/**
+ * 需要自行组装参数的请求，如设置默认值或金额换算，其他请求按结构体的 xml 标签生成参数，见 EncodeParams
+ */
 type WXPayParam interface {
 	// 返回参数列表
 	Params() url.Values
 }
 
+/**
+ * 传输时需要转换的参数，签名使用原值，如 pay/shorturl 的 long_url 需URL编码后传输
+ */
+type TransportParam interface {
+	TransportParams(signedParams url.Values)
+}
+
 type WxClient struct {
-	appId       string //应用ID
-	mchId       string //商户号
-	md5Key      string //MD5key
-	isProd      bool   //环境
-	gatewayHost string //网关地址
+	appId       string             //应用ID
+	mchId       string             //商户号
+	md5Key      string             //MD5key
+	isProd      bool               //环境
+	endpoints   *endpointList      //网关地址
+	sandbox     *sandboxSignKey    //沙箱环境验签密钥
+	httpClient  *http.Client       //HTTP客户端
+	retryPolicy *gopay.RetryPolicy //幂等请求重试策略
+	middlewares []gopay.Middleware //网关调用中间件
+	ctx         context.Context    //请求上下文
+	notifyUrl   string             //默认异步通知地址
+	signType    string             //签名类型 MD5、HMAC-SHA256
+	subAppId    string             //服务商模式的子商户应用ID
+	subMchId    string             //服务商模式的子商户号
+}
+
+/**
+ * 沙箱环境验签密钥，客户端副本共享同一密钥
+ */
+type sandboxSignKey struct {
+	mutex sync.Mutex
+	key   string
 }
 
 /**
@@ -35,120 +68,596 @@ type WxClient struct {
  */
 func NewWxClient(appId, mchId, md5Key string, isProd bool) *WxClient {
 	client := &WxClient{
-		appId:       appId,
-		mchId:       mchId,
-		isProd:      isProd,
-		md5Key:      md5Key,
-		gatewayHost: "https://api.mch.weixin.qq.com/sandboxnew/",
+		appId:      appId,
+		mchId:      mchId,
+		isProd:     isProd,
+		md5Key:     md5Key,
+		endpoints:  newEndpointList(WxPayProdURL+WxPaySandboxPath, WxPayBackupURL+WxPaySandboxPath),
+		sandbox:    &sandboxSignKey{},
+		httpClient: &http.Client{},
+		signType:   SignTypeMD5,
 	}
 	if isProd {
-		client.gatewayHost = "https://api.mch.weixin.qq.com/"
+		client.endpoints = newEndpointList(WxPayProdURL, WxPayBackupURL)
 	}
 	
 	return client
 }
 
 /**
- * 发送微信支付请求
+ * 设置网关地址，如本地测试网关或代理，不再使用备用域名
+ */
+func (m *WxClient) SetGatewayHost(gatewayHost string) {
+	m.endpoints.set(gatewayHost)
+}
+
+/**
+ * 设置网关地址列表，按顺序优先使用，幂等请求在网关故障时依次切换
+ */
+func (m *WxClient) SetEndpoints(gatewayHosts ...string) {
+	m.endpoints.set(gatewayHosts...)
+}
+
+/**
+ * 设置网关故障后暂停使用的时长
+ */
+func (m *WxClient) SetFailoverTTL(failoverTTL time.Duration) {
+	m.endpoints.mutex.Lock()
+	m.endpoints.failoverTTL = failoverTTL
+	m.endpoints.mutex.Unlock()
+}
+
+/**
+ * 获取主网关地址
+ */
+func (m *WxClient) GetGatewayHost() string {
+	return m.endpoints.primary()
+}
+
+/**
+ * 发送微信支付请求，param 为实现了 WXPayParam 或带 xml 标签的结构体
+ */
+func (m *WxClient) SendRequest(method string, url string, param interface{}, result interface{}) (err error) {
+	call, idempotent, err := m.newCall(method, url, param)
+	if err != nil {
+		return err
+	}
+	var retryPolicy *gopay.RetryPolicy
+	if idempotent {
+		retryPolicy = m.retryPolicy
+	}
+	
+	//请求报文只生成一次，重试时使用相同的随机字符串与签名
+	body := EncodeXml(call.Params, NumberParams(param)...)
+	err = m.handle(call, func(call *gopay.Call) error {
+		return retryPolicy.Do(call.Context, isRetryable, func() error {
+			if ctxErr := call.Context.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			call.Attempts++
+			data, requestErr := m.doRequest(call.Context, method, call.Api, body, idempotent)
+			if requestErr != nil {
+				return requestErr
+			}
+			call.Response = data
+			return m.VerifySign(data)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	err = UnmarshalXml(call.Response, result)
+	
+	return
+}
+
+/**
+ * 发送微信支付请求，返回未验证签名的原始响应，如下载对账单
+ */
+func (m *WxClient) SendRawRequest(method string, url string, param interface{}) ([]byte, error) {
+	call, idempotent, err := m.newCall(method, url, param)
+	if err != nil {
+		return nil, err
+	}
+	
+	var retryPolicy *gopay.RetryPolicy
+	if idempotent {
+		retryPolicy = m.retryPolicy
+	}
+	
+	body := EncodeXml(call.Params, NumberParams(param)...)
+	err = m.handle(call, func(call *gopay.Call) error {
+		return retryPolicy.Do(call.Context, isRetryable, func() error {
+			if ctxErr := call.Context.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			call.Attempts++
+			data, requestErr := m.doRequest(call.Context, method, call.Api, body, idempotent)
+			call.Response = data
+			return requestErr
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	return call.Response, nil
+}
+
+/**
+ * 设置幂等请求的重试策略，为空时不重试
+ */
+func (m *WxClient) SetRetryPolicy(retryPolicy *gopay.RetryPolicy) {
+	m.retryPolicy = retryPolicy
+}
+
+/**
+ * 添加网关调用中间件，按添加顺序由外到内执行
+ */
+func (m *WxClient) Use(middlewares ...gopay.Middleware) {
+	m.middlewares = append(m.middlewares, middlewares...)
+}
+
+/**
+ * 返回使用指定上下文的客户端副本，用于超时控制与链路追踪
+ */
+func (m *WxClient) WithContext(ctx context.Context) *WxClient {
+	client := *m
+	client.ctx = ctx
+	return &client
+}
+
+/**
+ * 返回服务商模式下指定子商户的客户端副本，请求中附带 sub_mch_id 与 sub_appid
+ * subAppId 为空时不传 sub_appid，此时用户标识使用服务商应用下的 openid
+ */
+func (m *WxClient) WithSubMerchant(subMchId, subAppId string) *WxClient {
+	client := *m
+	client.subMchId = subMchId
+	client.subAppId = subAppId
+	return &client
+}
+
+func (m *WxClient) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+/**
+ * 生成签名后的请求参数
+ */
+func (m *WxClient) newCall(method string, url string, param interface{}) (*gopay.Call, bool, error) {
+	if err := m.loadSandboxKey(); err != nil {
+		return nil, false, err
+	}
+	//沙箱环境的接口地址不包含secapi
+	if !m.isProd {
+		url = strings.TrimPrefix(url, "secapi/")
+	}
+	idempotent := false
+	if idempotentParam, ok := param.(Idempotent); ok {
+		idempotent = idempotentParam.IsIdempotent()
+	}
+	call := &gopay.Call{
+		Context:  m.context(),
+		Provider: gopay.ProviderWxPay,
+		Api:      url,
+		Method:   method,
+		Params:   m.UrlParams(param),
+	}
+	if transportParam, ok := param.(TransportParam); ok {
+		transportParam.TransportParams(call.Params)
+	}
+	
+	return call, idempotent, nil
+}
+
+/**
+ * 经过中间件执行网关调用
+ */
+func (m *WxClient) handle(call *gopay.Call, handler gopay.Handler) error {
+	return gopay.Chain(m.middlewares, func(call *gopay.Call) error {
+		startTime := time.Now()
+		defer func() {
+			call.Latency = time.Since(startTime)
+		}()
+		return handler(call)
+	})(call)
+}
+
+/**
+ * 设置商户API证书，退款等接口需要双向证书
+ */
+func (m *WxClient) SetCertificate(certPath, keyPath string) error {
+	certificate, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return err
+	}
+	m.setCertificate(certificate)
+	
+	return nil
+}
+
+/**
+ * 使用PEM格式的证书内容设置商户API证书
  */
-func (m *WxClient) SendRequest(method string, url string, param WXPayParam, result interface{}) (err error) {
-	requestParam := m.UrlParams(param)
-	requestParamXml := mapToXml(requestParam)
-	request, err := http.NewRequest(method, m.gatewayHost+url, strings.NewReader(requestParamXml))
+func (m *WxClient) SetCertificatePEM(certPEM, keyPEM []byte) error {
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
 	if err != nil {
 		return err
 	}
+	m.setCertificate(certificate)
+	
+	return nil
+}
+
+func (m *WxClient) setCertificate(certificate tls.Certificate) {
+	//沿用默认传输的代理、拨号与空闲连接设置，仅替换TLS配置
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	m.httpClient = &http.Client{
+		Timeout:   m.httpClient.Timeout,
+		Transport: transport,
+	}
+}
+
+/**
+ * 设置网关请求超时时间，为0时不超时
+ */
+func (m *WxClient) SetTimeout(timeout time.Duration) {
+	m.httpClient.Timeout = timeout
+}
+
+/**
+ * 获取沙箱环境验签密钥，沙箱环境下的请求和响应均使用该密钥签名
+ */
+func (m *WxClient) SandboxSignKey() (string, error) {
+	m.sandbox.mutex.Lock()
+	defer m.sandbox.mutex.Unlock()
+	if len(m.sandbox.key) > 0 {
+		return m.sandbox.key, nil
+	}
+	
+	requestParam := url.Values{}
+	requestParam.Set("mch_id", m.mchId)
+	requestParam.Set("nonce_str", getNonceStr())
+	requestParam.Set("sign", signWithKey(requestParam, m.md5Key, SignTypeMD5))
+	responseByte, err := m.doRequest(m.context(), "POST", "pay/getsignkey", EncodeXml(requestParam), true)
+	if err != nil {
+		return "", err
+	}
+	xmlHandler := make(XmlToMap)
+	err = xml.Unmarshal(responseByte, &xmlHandler)
+	if err != nil {
+		return "", err
+	}
+	if xmlHandler.Get("return_code") != "SUCCESS" {
+		return "", returnError(xmlHandler, responseByte)
+	}
+	sandboxKey := xmlHandler.Get("sandbox_signkey")
+	if sandboxKey == "" {
+		return "", parseError(responseByte)
+	}
+	m.sandbox.key = sandboxKey
+	
+	return sandboxKey, nil
+}
+
+/**
+ * 非生产环境下预先获取沙箱验签密钥
+ */
+func (m *WxClient) loadSandboxKey() error {
+	if m.isProd {
+		return nil
+	}
+	_, err := m.SandboxSignKey()
+	
+	return err
+}
+
+/**
+ * 发送HTTP请求，网关故障时幂等请求切换到下一个网关地址
+ */
+func (m *WxClient) doRequest(ctx context.Context, method, api, body string, idempotent bool) (responseByte []byte, err error) {
+	for _, gatewayHost := range m.endpoints.ordered() {
+		responseByte, err = m.doHostRequest(ctx, method, gatewayHost+api, body)
+		if err == nil {
+			m.endpoints.markUp(gatewayHost)
+			return responseByte, nil
+		}
+		if !isGatewayError(err) {
+			return nil, err
+		}
+		m.endpoints.markDown(gatewayHost)
+		//非幂等请求只有在请求未到达网关时才能切换
+		if !idempotent && !isDialError(err) {
+			return nil, err
+		}
+	}
+	
+	return nil, err
+}
+
+func (m *WxClient) doHostRequest(ctx context.Context, method, requestUrl, body string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, method, requestUrl, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
 	request.Header.Set("Accept", "application/xml")
 	request.Header.Set("Content-Type", "application/xml;charset=utf-8")
-	client := &http.Client{}
-	response, err := client.Do(request)
+	response, err := m.httpClient.Do(request)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() {
 		_ = response.Body.Close()
 	}()
 	responseByte, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	err = m.VerifySign(responseByte)
-	if err != nil {
-		return err
+	if response.StatusCode >= http.StatusInternalServerError {
+		return nil, &StatusError{StatusCode: response.StatusCode, Body: responseByte}
 	}
-	err = xml.Unmarshal(responseByte, result)
 	
-	return
+	return responseByte, nil
 }
 
+/**
+ * 公众号 WeixinJSBridge 调起支付的参数
+ */
 func (m *WxClient) Jsapi(signType, prepayId, nonceStr string) (param url.Values) {
+	return m.jsapiParams(m.payAppId(), signType, prepayId, nonceStr)
+}
+
+/**
+ * 小程序 wx.requestPayment 调起支付的参数，appId 为小程序的应用ID
+ */
+func (m *WxClient) MiniProgram(appId, prepayId, nonceStr string) (param url.Values) {
+	return m.jsapiParams(appId, m.signType, prepayId, nonceStr)
+}
+
+func (m *WxClient) jsapiParams(appId, signType, prepayId, nonceStr string) (param url.Values) {
 	timeStamp := strconv.FormatInt(time.Now().Unix(), 10)
 	param = url.Values{}
-	param.Set("appId", m.appId)
+	param.Set("appId", appId)
 	param.Set("timeStamp", timeStamp)
 	param.Set("signType", signType)
 	param.Set("package", fmt.Sprintf("prepay_id=%s", prepayId))
 	param.Set("nonceStr", nonceStr)
-	param.Set("paySign", m.sign(param))
+	param.Set("paySign", m.signWithType(param, signType))
+	
+	return
+}
+
+/**
+ * APP调起支付的参数，参数名均为小写，签名类型与统一下单一致
+ */
+func (m *WxClient) App(prepayId, nonceStr string) (param url.Values) {
+	partnerId := m.mchId
+	if len(m.subMchId) > 0 {
+		partnerId = m.subMchId
+	}
+	param = url.Values{}
+	param.Set("appid", m.payAppId())
+	param.Set("partnerid", partnerId)
+	param.Set("prepayid", prepayId)
+	param.Set("package", "Sign=WXPay")
+	param.Set("noncestr", nonceStr)
+	param.Set("timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	param.Set("sign", m.sign(param))
 	
 	return
 }
 
 /**
- * 组装微信支付公共参数
+ * 调起支付使用的应用ID，服务商模式下设置了子商户应用ID时使用 sub_appid
+ */
+func (m *WxClient) payAppId() string {
+	if len(m.subAppId) > 0 {
+		return m.subAppId
+	}
+	return m.appId
+}
+
+/**
+ * 请求参数，实现了 WXPayParam 时使用 Params()，否则按结构体的 xml 标签生成
+ */
+func paramsOf(param interface{}) url.Values {
+	if wxPayParam, ok := param.(WXPayParam); ok {
+		return wxPayParam.Params()
+	}
+	return EncodeParams(param)
+}
+
+/**
+ * 组装微信支付公共参数，使用客户端的签名类型
  */
-func (m *WxClient) UrlParams(param WXPayParam) (requestParam url.Values) {
-	requestParam = param.Params()
+func (m *WxClient) UrlParams(param interface{}) (requestParam url.Values) {
+	return m.SignParams(param, m.signType)
+}
+
+/**
+ * 使用指定签名类型组装微信支付公共参数，如仅支持MD5签名的扫码支付模式一
+ */
+func (m *WxClient) SignParams(param interface{}, signType string) (requestParam url.Values) {
+	requestParam = paramsOf(param)
 	requestParam.Set("appid", m.appId)
 	requestParam.Set("mch_id", m.mchId)
+	if len(m.subMchId) > 0 {
+		requestParam.Set("sub_mch_id", m.subMchId)
+	}
+	if len(m.subAppId) > 0 {
+		requestParam.Set("sub_appid", m.subAppId)
+	}
 	requestParam.Set("nonce_str", getNonceStr())
-	requestParam.Set("sign", m.sign(requestParam))
+	if signType != SignTypeMD5 {
+		requestParam.Set("sign_type", signType)
+	}
+	requestParam.Set("sign", m.signWithType(requestParam, signType))
 	
 	return
 }
 
 /**
- * 验证微信支付响应结果签名
+ * 组装带签名的XML报文，如扫码支付模式一的回调响应
+ */
+func (m *WxClient) SignXml(param interface{}) string {
+	return EncodeXml(m.SignParams(param, SignTypeMD5), NumberParams(param)...)
+}
+
+/**
+ * 设置签名类型 MD5、HMAC-SHA256，默认MD5，沙箱环境仅支持MD5
+ */
+func (m *WxClient) SetSignType(signType string) {
+	m.signType = signType
+}
+
+func (m *WxClient) GetSignType() string {
+	return m.signType
+}
+
+/**
+ * 组装带签名的微信页面跳转地址，页面跳转接口不带 sign_type，固定使用MD5签名
+ */
+func (m *WxClient) SignUrl(api string, param interface{}) string {
+	requestParam := paramsOf(param)
+	requestParam.Set("appid", m.appId)
+	requestParam.Set("mch_id", m.mchId)
+	for paramKey := range requestParam {
+		if len(requestParam.Get(paramKey)) == 0 {
+			delete(requestParam, paramKey)
+		}
+	}
+	requestParam.Set("sign", m.signWithType(requestParam, SignTypeMD5))
+	
+	return m.endpoints.primary() + api + "?" + requestParam.Encode()
+}
+
+/**
+ * 验证微信支付响应签名，通信失败或业务失败时返回错误
  */
 func (m *WxClient) VerifySign(data []byte) (err error) {
-	xmlHandler := make(XmlToMap)
-	err = xml.Unmarshal(data, &xmlHandler)
+	xmlHandler, err := m.verifyData(data)
 	if err != nil {
 		return err
 	}
+	resultCode := xmlHandler.Get("result_code")
+	if resultCode == "" {
+		return parseError(data)
+	}
+	if resultCode == "FAIL" {
+		return resultError(xmlHandler, data)
+	}
+	
+	return nil
+}
+
+/**
+ * 验证异步通知签名，支付失败的通知 result_code 为 FAIL，同样是有效的通知
+ */
+func (m *WxClient) VerifyNotify(data []byte) (err error) {
+	_, err = m.verifyData(data)
+	
+	return err
+}
+
+/**
+ * 验证回调签名，用于不包含 return_code 的回调，如扫码支付模式一的商品回调
+ */
+func (m *WxClient) VerifyCallback(data []byte) (err error) {
+	xmlHandler, err := m.unmarshalData(data)
+	if err != nil {
+		return err
+	}
+	
+	return m.verifySignature(xmlHandler, data, SignTypeMD5)
+}
+
+/**
+ * 检查通信结果并验证签名
+ */
+func (m *WxClient) verifyData(data []byte) (XmlToMap, error) {
+	xmlHandler, err := m.unmarshalData(data)
+	if err != nil {
+		return nil, err
+	}
 	
 	returnCode := xmlHandler.Get("return_code")
 	if returnCode == "" {
-		return errors.New("解析失败！")
+		return nil, parseError(data)
 	}
 	if returnCode == "FAIL" {
-		return errors.New(xmlHandler.Get("return_msg"))
+		return nil, returnError(xmlHandler, data)
 	}
-	resultCode := xmlHandler.Get("result_code")
-	if resultCode == "" {
-		return errors.New("解析失败！")
+	
+	if err = m.verifySignature(xmlHandler, data, m.signType); err != nil {
+		return nil, err
 	}
-	if returnCode == "FAIL" {
-		return errors.New(xmlHandler.Get("err_code_des"))
+	
+	return xmlHandler, nil
+}
+
+func (m *WxClient) unmarshalData(data []byte) (XmlToMap, error) {
+	if err := m.loadSandboxKey(); err != nil {
+		return nil, err
+	}
+	xmlHandler := make(XmlToMap)
+	err := xml.Unmarshal(data, &xmlHandler)
+	if err != nil {
+		return nil, err
 	}
 	
+	return xmlHandler, nil
+}
+
+/**
+ * 验证签名，报文中包含 sign_type 时使用该签名类型，验证后 xmlHandler 中不再包含 sign
+ */
+func (m *WxClient) verifySignature(xmlHandler XmlToMap, data []byte, signType string) error {
 	srcSign := xmlHandler.Get("sign")
 	if srcSign == "" {
-		return errors.New("解析失败！")
+		return parseError(data)
+	}
+	if dataSignType := xmlHandler.Get("sign_type"); len(dataSignType) > 0 {
+		signType = dataSignType
 	}
 	delete(xmlHandler, "sign")
-	generateSign := m.sign(url.Values(xmlHandler))
-	if srcSign == generateSign {
-		return nil
+	generateSign := m.signWithType(url.Values(xmlHandler), signType)
+	if srcSign != generateSign {
+		return signatureError(data)
 	}
 	
-	return errors.New("签名验证失败")
+	return nil
 }
 
 /**
  * 组装微信签名
  */
 func (m *WxClient) sign(params url.Values) string {
+	return m.signWithType(params, m.signType)
+}
+
+/**
+ * 使用指定签名类型组装微信签名
+ */
+func (m *WxClient) signWithType(params url.Values, signType string) string {
+	m.sandbox.mutex.Lock()
+	md5Key := m.md5Key
+	if !m.isProd && len(m.sandbox.key) > 0 {
+		md5Key = m.sandbox.key
+	}
+	m.sandbox.mutex.Unlock()
+	
+	return signWithKey(params, md5Key, signType)
+}
+
+/**
+ * 使用指定密钥组装微信签名，签名类型为 HMAC-SHA256 时使用密钥计算HMAC，否则使用MD5
+ */
+func signWithKey(params url.Values, md5Key, signType string) string {
 	paramList := make([]string, 0, 16)
 	for paramKey := range params {
 		paramValue := params.Get(paramKey)
@@ -160,14 +669,17 @@ func (m *WxClient) sign(params url.Values) string {
 		paramList = append(paramList, paramKey+"="+paramValue)
 	}
 	sort.Strings(paramList)
-	if len(m.md5Key) > 0 {
-		paramList = append(paramList, "key="+m.md5Key)
+	if len(md5Key) > 0 {
+		paramList = append(paramList, "key="+md5Key)
 	}
 	requestParam := strings.Join(paramList, "&")
 	
-	md5Handler := md5.New()
-	md5Handler.Write([]byte(requestParam))
-	hashByte := md5Handler.Sum(nil)
+	hashHandler := md5.New()
+	if signType == SignTypeHMACSHA256 {
+		hashHandler = hmac.New(sha256.New, []byte(md5Key))
+	}
+	hashHandler.Write([]byte(requestParam))
+	hashByte := hashHandler.Sum(nil)
 	
 	return strings.ToUpper(hex.EncodeToString(hashByte))
 }
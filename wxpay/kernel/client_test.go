@@ -0,0 +1,46 @@
+package kernel
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testMd5Key = "0123456789abcdef0123456789abcdef"
+
+type testParams url.Values
+
+func (m testParams) Params() url.Values {
+	return url.Values(m)
+}
+
+func TestSignUrlUsesMD5(t *testing.T) {
+	for _, signType := range []string{SignTypeMD5, SignTypeHMACSHA256} {
+		t.Run(signType, func(t *testing.T) {
+			client := NewWxClient("wx123", "1900000109", testMd5Key, true)
+			client.SetSignType(signType)
+			param := testParams{"plan_id": {"12535"}, "contract_code": {"100000"}, "version": {"1.0"}, "timestamp": {"1414488825"}}
+			signUrl := client.SignUrl("papay/entrustweb", param)
+
+			index := strings.Index(signUrl, "?")
+			if index < 0 || !strings.HasSuffix(signUrl[:index], "papay/entrustweb") {
+				t.Fatalf("url = %s", signUrl)
+			}
+			query, err := url.ParseQuery(signUrl[index+1:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query.Get("plan_id") != "12535" || query.Get("appid") != "wx123" {
+				t.Errorf("query = %v", query)
+			}
+			if query.Get("sign_type") != "" {
+				t.Errorf("sign_type = %q, want empty", query.Get("sign_type"))
+			}
+			sign := query.Get("sign")
+			query.Del("sign")
+			if want := signWithKey(query, testMd5Key, SignTypeMD5); sign != want {
+				t.Errorf("sign = %s, want MD5 sign %s", sign, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package kernel
+
+import (
+	"errors"
+	"time"
+)
+
+type Config struct {
+	AppId     string        //应用ID
+	MchId     string        //商户号
+	Md5Key    string        //MD5key
+	IsProd    bool          //是否为生产环境
+	CertPath  string        //商户API证书路径，退款等接口需要（可选）
+	KeyPath   string        //商户API证书私钥路径（可选）
+	Endpoints []string      //自定义网关地址，按顺序优先使用，如代理或本地测试网关（可选）
+	Cert      string        //商户API证书内容，设置后不再读取 CertPath（可选）
+	Key       string        //商户API证书私钥内容（可选）
+	NotifyUrl string        //默认异步通知地址，下单时未传入通知地址时使用（可选）
+	Timeout   time.Duration //网关请求超时时间，为0时不超时（可选）
+	SignType  string        //签名类型 MD5、HMAC-SHA256，默认MD5（可选）
+	SubMchId  string        //服务商模式的子商户号，也可通过 WithSubMerchant 按请求指定（可选）
+	SubAppId  string        //服务商模式的子商户应用ID（可选）
+}
+
+/**
+ * 根据配置初始化微信支付客户端
+ */
+func NewWxClientWithConfig(config *Config) (*WxClient, error) {
+	if config == nil {
+		return nil, errors.New("配置不能为空")
+	}
+	client := NewWxClient(config.AppId, config.MchId, config.Md5Key, config.IsProd)
+	if len(config.Cert) > 0 || len(config.Key) > 0 {
+		if err := client.SetCertificatePEM([]byte(config.Cert), []byte(config.Key)); err != nil {
+			return nil, err
+		}
+	} else if len(config.CertPath) > 0 || len(config.KeyPath) > 0 {
+		if err := client.SetCertificate(config.CertPath, config.KeyPath); err != nil {
+			return nil, err
+		}
+	}
+	client.SetTimeout(config.Timeout)
+	client.notifyUrl = config.NotifyUrl
+	if len(config.SignType) > 0 {
+		client.SetSignType(config.SignType)
+	}
+	client.subMchId = config.SubMchId
+	client.subAppId = config.SubAppId
+	if len(config.Endpoints) > 0 {
+		client.SetEndpoints(config.Endpoints...)
+	}
+
+	return client, nil
+}
+
+/**
+ * 获取商户号
+ */
+func (m *WxClient) GetMchId() string {
+	return m.mchId
+}
+
+/**
+ * 获取应用ID
+ */
+func (m *WxClient) GetAppId() string {
+	return m.appId
+}
+
+/**
+ * 获取服务商模式的子商户号
+ */
+func (m *WxClient) GetSubMchId() string {
+	return m.subMchId
+}
+
+/**
+ * 获取服务商模式的子商户应用ID
+ */
+func (m *WxClient) GetSubAppId() string {
+	return m.subAppId
+}
+
+/**
+ * 获取默认异步通知地址
+ */
+func (m *WxClient) GetNotifyUrl() string {
+	return m.notifyUrl
+}
+
+/**
+ * 设置默认异步通知地址
+ */
+func (m *WxClient) SetNotifyUrl(notifyUrl string) {
+	m.notifyUrl = notifyUrl
+}
@@ -0,0 +1,241 @@
+package kernel
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/shinmigo/gopay"
+)
+
+var amountType = reflect.TypeOf(gopay.Amount(0))
+
+/**
+ * 按结构体字段的 xml 标签生成请求参数，如
+ *   OutTradeNo string `xml:"out_trade_no"`
+ *   BillType   string `xml:"bill_type" default:"ALL"`
+ * 未设置 xml 标签或标签为 - 的字段不作为参数，零值字段不传，设置了 default 标签时使用默认值
+ * gopay.Amount 转换为分，实现了 encoding.TextMarshaler 的字段使用其文本，
+ * 实现了 fmt.Stringer 的结构体使用 String()，其他结构体、map、slice 编码为JSON
+ * 未设置标签的嵌入结构体展开为同一层参数，数字参数设置 wx:"number" 标签，见 NumberParams
+ */
+func EncodeParams(param interface{}) url.Values {
+	params := url.Values{}
+	value := indirect(reflect.ValueOf(param))
+	if value.Kind() == reflect.Struct {
+		encodeStruct(params, value)
+	}
+	return params
+}
+
+func encodeStruct(params url.Values, value reflect.Value) {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		name, tagged := paramName(field)
+		if !tagged && field.Anonymous && field.PkgPath == "" {
+			if embedded := indirect(value.Field(i)); embedded.Kind() == reflect.Struct {
+				encodeStruct(params, embedded)
+			}
+			continue
+		}
+		if field.PkgPath != "" || name == "" || name == "-" {
+			continue
+		}
+		paramValue := encodeValue(value.Field(i))
+		if len(paramValue) == 0 {
+			paramValue = field.Tag.Get("default")
+		}
+		if len(paramValue) > 0 {
+			params.Set(name, paramValue)
+		}
+	}
+}
+
+func encodeValue(value reflect.Value) string {
+	if (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) && value.IsNil() {
+		return ""
+	}
+	//*gopay.Amount 同样转换为分，不能使用 MarshalText 的元
+	if amount := indirect(value); amount.Type() == amountType {
+		if amount.Int() == 0 {
+			return ""
+		}
+		return strconv.FormatInt(amount.Int(), 10)
+	}
+	if marshaler, ok := value.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		if err != nil {
+			return ""
+		}
+		return string(text)
+	}
+	if stringer, ok := value.Interface().(fmt.Stringer); ok && (value.Kind() == reflect.Ptr || value.Kind() == reflect.Struct) {
+		return stringer.String()
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return encodeValue(value.Elem())
+	case reflect.String:
+		return value.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Int() == 0 {
+			return ""
+		}
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value.Uint() == 0 {
+			return ""
+		}
+		return strconv.FormatUint(value.Uint(), 10)
+	case reflect.Bool:
+		if !value.Bool() {
+			return ""
+		}
+		return "true"
+	case reflect.Float32, reflect.Float64:
+		if value.Float() == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(value.Float(), 'f', -1, value.Type().Bits())
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		if value.IsZero() || ((value.Kind() == reflect.Map || value.Kind() == reflect.Slice) && value.Len() == 0) {
+			return ""
+		}
+		data, err := json.Marshal(value.Interface())
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+	return ""
+}
+
+/**
+ * 按结构体字段的 xml 标签解析参数，与 EncodeParams 对应，result 为结构体指针
+ */
+func DecodeParams(params url.Values, result interface{}) error {
+	value := reflect.ValueOf(result)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("wxpay: decode params into non-pointer %T", result)
+	}
+	value = indirect(value)
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("wxpay: decode params into non-struct %T", result)
+	}
+	return decodeStruct(params, value)
+}
+
+func decodeStruct(params url.Values, value reflect.Value) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		name, tagged := paramName(field)
+		if !tagged && field.Anonymous && field.PkgPath == "" && field.Type.Kind() == reflect.Struct {
+			if err := decodeStruct(params, value.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" || name == "" || name == "-" {
+			continue
+		}
+		paramValue, ok := params[name]
+		if !ok || len(paramValue) == 0 || len(paramValue[0]) == 0 {
+			continue
+		}
+		if err := decodeValue(value.Field(i), paramValue[0], name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeValue(value reflect.Value, paramValue, key string) error {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return decodeValue(value.Elem(), paramValue, key)
+	}
+	if value.Type() == amountType {
+		return setParamField(value, paramValue, key)
+	}
+	if unmarshaler, ok := value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := unmarshaler.UnmarshalText([]byte(paramValue)); err != nil {
+			return fmt.Errorf("wxpay: invalid %s: %w", key, err)
+		}
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		if err := json.Unmarshal([]byte(paramValue), value.Addr().Interface()); err != nil {
+			return fmt.Errorf("wxpay: invalid %s: %w", key, err)
+		}
+		return nil
+	}
+	return setParamField(value, paramValue, key)
+}
+
+/**
+ * 设置了 wx:"number" 标签的参数名，生成XML时直接输出，其他参数使用CDATA，如
+ *   TotalFee uint `xml:"total_fee" wx:"number"`
+ */
+func NumberParams(param interface{}) []string {
+	value := indirect(reflect.ValueOf(param))
+	if !value.IsValid() || value.Kind() != reflect.Struct {
+		return nil
+	}
+	return numberParams(value.Type())
+}
+
+func numberParams(valueType reflect.Type) (paramKeys []string) {
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		name, tagged := paramName(field)
+		if !tagged && field.Anonymous && field.PkgPath == "" {
+			embeddedType := field.Type
+			if embeddedType.Kind() == reflect.Ptr {
+				embeddedType = embeddedType.Elem()
+			}
+			if embeddedType.Kind() == reflect.Struct {
+				paramKeys = append(paramKeys, numberParams(embeddedType)...)
+			}
+			continue
+		}
+		if field.PkgPath != "" || name == "" || name == "-" {
+			continue
+		}
+		if field.Tag.Get("wx") == "number" {
+			paramKeys = append(paramKeys, name)
+		}
+	}
+	return
+}
+
+/**
+ * 参数名，返回是否设置了 xml 标签
+ */
+func paramName(field reflect.StructField) (string, bool) {
+	tag, ok := field.Tag.Lookup("xml")
+	if !ok {
+		return "", false
+	}
+	return strings.Split(tag, ",")[0], true
+}
+
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
@@ -0,0 +1,303 @@
+package kernel_test
+
+import (
+	"encoding/xml"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shinmigo/gopay"
+	"github.com/shinmigo/gopay/wxpay/kernel"
+	"github.com/shinmigo/gopay/wxpay/papay"
+)
+
+type EncodeBase struct {
+	AppId string `xml:"appid"`
+	MchId string `xml:"mch_id"`
+}
+
+type encodeDetail struct {
+	GoodsId string `json:"goods_id"`
+}
+
+type encodeParam struct {
+	EncodeBase
+	*EncodePointer
+	OutTradeNo string        `xml:"out_trade_no"`
+	Body       string        `xml:"body"`
+	TotalFee   uint64        `xml:"total_fee" wx:"number"`
+	FeeType    string        `xml:"fee_type" default:"CNY"`
+	Version    string        `xml:"version,omitempty" default:"1.0"`
+	Amount     *gopay.Amount `xml:"refund_fee" wx:"number"`
+	Cash       gopay.Amount  `xml:"cash_fee"`
+	Profit     bool          `xml:"profit_sharing"`
+	Rate       float64       `xml:"rate"`
+	Expire     *time.Time    `xml:"time_expire"`
+	Detail     *encodeDetail `xml:"detail"`
+	Tagged     EncodeBase    `xml:"scene_info"`
+	Ignored    string        `xml:"-"`
+	Untagged   string
+	unexported string
+}
+
+type EncodePointer struct {
+	SubMchId string `xml:"sub_mch_id"`
+	Count    int    `xml:"count" wx:"number"`
+}
+
+func TestEncodeParams(t *testing.T) {
+	refundFee := gopay.Fen(300)
+	expire := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := []struct {
+		name  string
+		param interface{}
+		want  url.Values
+	}{
+		{
+			name: "all fields",
+			param: &encodeParam{
+				EncodeBase:    EncodeBase{AppId: "wx123", MchId: "1900000109"},
+				EncodePointer: &EncodePointer{SubMchId: "1900000110", Count: 2},
+				OutTradeNo:    "T1001",
+				Body:          "测试",
+				TotalFee:      100,
+				FeeType:       "USD",
+				Amount:        &refundFee,
+				Cash:          gopay.Fen(12345),
+				Profit:        true,
+				Rate:          0.6,
+				Expire:        &expire,
+				Detail:        &encodeDetail{GoodsId: "G1"},
+				Tagged:        EncodeBase{AppId: "wx456"},
+				Ignored:       "ignored",
+				Untagged:      "untagged",
+				unexported:    "unexported",
+			},
+			want: url.Values{
+				"appid":          {"wx123"},
+				"mch_id":         {"1900000109"},
+				"sub_mch_id":     {"1900000110"},
+				"count":          {"2"},
+				"out_trade_no":   {"T1001"},
+				"body":           {"测试"},
+				"total_fee":      {"100"},
+				"fee_type":       {"USD"},
+				"version":        {"1.0"},
+				"refund_fee":     {"300"},
+				"cash_fee":       {"12345"},
+				"profit_sharing": {"true"},
+				"rate":           {"0.6"},
+				"time_expire":    {"2022-01-02T03:04:05Z"},
+				"detail":         {`{"goods_id":"G1"}`},
+				"scene_info":     {`{"AppId":"wx456","MchId":""}`},
+			},
+		},
+		{
+			name:  "zero values and defaults",
+			param: encodeParam{OutTradeNo: "T1002"},
+			want:  url.Values{"out_trade_no": {"T1002"}, "fee_type": {"CNY"}, "version": {"1.0"}},
+		},
+		{
+			name:  "nil pointer",
+			param: (*encodeParam)(nil),
+			want:  url.Values{},
+		},
+		{
+			name:  "non struct",
+			param: "T1003",
+			want:  url.Values{},
+		},
+	}
+	for _, item := range cases {
+		t.Run(item.name, func(t *testing.T) {
+			if params := kernel.EncodeParams(item.param); !reflect.DeepEqual(params, item.want) {
+				t.Errorf("EncodeParams = %v, want %v", params, item.want)
+			}
+		})
+	}
+}
+
+func TestNumberParams(t *testing.T) {
+	want := []string{"count", "total_fee", "refund_fee"}
+	if numbers := kernel.NumberParams(&encodeParam{}); !reflect.DeepEqual(numbers, want) {
+		t.Errorf("NumberParams = %v, want %v", numbers, want)
+	}
+	if numbers := kernel.NumberParams(url.Values{}); numbers != nil {
+		t.Errorf("NumberParams(url.Values) = %v, want nil", numbers)
+	}
+}
+
+func TestDecodeParams(t *testing.T) {
+	params := url.Values{
+		"appid":          {"wx123"},
+		"sub_mch_id":     {"1900000110"},
+		"out_trade_no":   {"T1001"},
+		"total_fee":      {"100"},
+		"refund_fee":     {"300"},
+		"cash_fee":       {"12345"},
+		"profit_sharing": {"true"},
+		"rate":           {"0.6"},
+		"time_expire":    {"2022-01-02T03:04:05Z"},
+		"detail":         {`{"goods_id":"G1"}`},
+		"fee_type":       {""},
+		"untagged":       {"untagged"},
+	}
+	result := &encodeParam{}
+	if err := kernel.DecodeParams(params, result); err != nil {
+		t.Fatal(err)
+	}
+	if result.AppId != "wx123" || result.OutTradeNo != "T1001" || result.TotalFee != 100 || !result.Profit || result.Rate != 0.6 || result.Untagged != "" {
+		t.Errorf("result = %+v", result)
+	}
+	//未设置标签的嵌入结构体指针不会自动创建
+	if result.EncodePointer != nil {
+		t.Errorf("EncodePointer = %+v", result.EncodePointer)
+	}
+	if result.Amount == nil || *result.Amount != gopay.Fen(300) || result.Cash != gopay.Fen(12345) {
+		t.Errorf("Amount = %v, Cash = %v", result.Amount, result.Cash)
+	}
+	if result.Expire == nil || !result.Expire.Equal(time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("Expire = %v", result.Expire)
+	}
+	if result.Detail == nil || result.Detail.GoodsId != "G1" {
+		t.Errorf("Detail = %+v", result.Detail)
+	}
+
+	//编码后再解析得到相同的结果
+	encoded := kernel.EncodeParams(result)
+	decoded := &encodeParam{}
+	if err := kernel.DecodeParams(encoded, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(kernel.EncodeParams(decoded), encoded) {
+		t.Errorf("round trip = %v, want %v", kernel.EncodeParams(decoded), encoded)
+	}
+
+	invalidCases := []struct {
+		name   string
+		params url.Values
+		result interface{}
+	}{
+		{"non pointer", params, encodeParam{}},
+		{"non struct", params, new(string)},
+		{"invalid number", url.Values{"total_fee": {"1.5"}}, &encodeParam{}},
+		{"invalid amount", url.Values{"cash_fee": {"1.00"}}, &encodeParam{}},
+		{"invalid bool", url.Values{"profit_sharing": {"Y"}}, &encodeParam{}},
+		{"invalid time", url.Values{"time_expire": {"20220102"}}, &encodeParam{}},
+		{"invalid json", url.Values{"detail": {"{"}}, &encodeParam{}},
+	}
+	for _, item := range invalidCases {
+		t.Run(item.name, func(t *testing.T) {
+			if err := kernel.DecodeParams(item.params, item.result); err == nil {
+				t.Errorf("DecodeParams(%v) = nil, want error", item.params)
+			}
+		})
+	}
+}
+
+func TestEncodeXml(t *testing.T) {
+	cases := []struct {
+		name       string
+		params     url.Values
+		numberKeys []string
+		want       string
+	}{
+		{
+			name:   "sorted",
+			params: url.Values{"nonce_str": {"n"}, "appid": {"wx123"}, "mch_id": {"1900000109"}},
+			want:   "<xml><appid><![CDATA[wx123]]></appid><mch_id><![CDATA[1900000109]]></mch_id><nonce_str><![CDATA[n]]></nonce_str></xml>",
+		},
+		{
+			name:       "number",
+			params:     url.Values{"total_fee": {"100"}, "body": {"测试"}},
+			numberKeys: []string{"total_fee", "not_exist"},
+			want:       "<xml><body><![CDATA[测试]]></body><total_fee>100</total_fee></xml>",
+		},
+		{
+			name:   "escape cdata end",
+			params: url.Values{"attach": {"a]]>b<c>]]>"}},
+			want:   "<xml><attach><![CDATA[a]]]]><![CDATA[>b<c>]]]]><![CDATA[>]]></attach></xml>",
+		},
+		{
+			name:   "empty",
+			params: url.Values{},
+			want:   "<xml></xml>",
+		},
+	}
+	for _, item := range cases {
+		t.Run(item.name, func(t *testing.T) {
+			xmlData := kernel.EncodeXml(item.params, item.numberKeys...)
+			if xmlData != item.want {
+				t.Errorf("EncodeXml = %s, want %s", xmlData, item.want)
+			}
+			//解析后得到原参数
+			xmlHandler := make(kernel.XmlToMap)
+			if err := xml.Unmarshal([]byte(xmlData), &xmlHandler); err != nil {
+				t.Fatal(err)
+			}
+			for key := range item.params {
+				if xmlHandler.Get(key) != item.params.Get(key) {
+					t.Errorf("%s = %q, want %q", key, xmlHandler.Get(key), item.params.Get(key))
+				}
+			}
+		})
+	}
+}
+
+func TestPapayParams(t *testing.T) {
+	cases := []struct {
+		name  string
+		param interface{}
+		want  url.Values
+	}{
+		{
+			name:  "entrustweb",
+			param: &papay.EntrustWeb{PlanId: "12535", ContractCode: "100000", RequestSerial: 1000, ContractDisplayAccount: "张三", NotifyUrl: "https://example.com/notify", Timestamp: 1414488825},
+			want: url.Values{
+				"plan_id": {"12535"}, "contract_code": {"100000"}, "request_serial": {"1000"}, "contract_display_account": {"张三"},
+				"notify_url": {"https://example.com/notify"}, "version": {papay.ContractVersion}, "timestamp": {"1414488825"},
+			},
+		},
+		{
+			name:  "querycontract",
+			param: &papay.QueryContract{ContractId: "200000"},
+			want:  url.Values{"contract_id": {"200000"}, "version": {papay.ContractVersion}},
+		},
+		{
+			name:  "deletecontract",
+			param: &papay.DeleteContract{PlanId: "12535", ContractCode: "100000", ContractTerminationRemark: "解约"},
+			want:  url.Values{"plan_id": {"12535"}, "contract_code": {"100000"}, "contract_termination_remark": {"解约"}, "version": {papay.ContractVersion}},
+		},
+	}
+	for _, item := range cases {
+		t.Run(item.name, func(t *testing.T) {
+			if params := kernel.EncodeParams(item.param); !reflect.DeepEqual(params, item.want) {
+				t.Errorf("EncodeParams = %v, want %v", params, item.want)
+			}
+		})
+	}
+}
+
+func TestEntrustWebTimestamp(t *testing.T) {
+	client := kernel.NewWxClient("wx123", "1900000109", testMd5Key, true)
+	param := &papay.EntrustWeb{PlanId: "12535", ContractCode: "100000", RequestSerial: 1000}
+	entrustUrl := (&papay.Papay{Client: client}).EntrustWeb(param)
+	query, err := url.ParseQuery(entrustUrl[strings.Index(entrustUrl, "?")+1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp, err := strconv.ParseInt(query.Get("timestamp"), 10, 64)
+	if err != nil || time.Since(time.Unix(timestamp, 0)) > time.Minute {
+		t.Errorf("timestamp = %q", query.Get("timestamp"))
+	}
+	if query.Get("version") != papay.ContractVersion || query.Get("plan_id") != "12535" {
+		t.Errorf("query = %v", query)
+	}
+	//未修改调用方的参数
+	if param.Timestamp != 0 {
+		t.Errorf("param.Timestamp = %d", param.Timestamp)
+	}
+}
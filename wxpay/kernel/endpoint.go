@@ -0,0 +1,166 @@
+package kernel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	/**
+	 * 微信支付网关地址，api2为微信支付备用域名
+	 */
+	WxPayProdURL       = "https://api.mch.weixin.qq.com/"
+	WxPayBackupURL     = "https://api2.mch.weixin.qq.com/"
+	WxPaySandboxPath   = "sandboxnew/"
+	DefaultFailoverTTL = 30 * time.Second
+
+	SignTypeMD5        = "MD5"         // MD5签名，默认
+	SignTypeHMACSHA256 = "HMAC-SHA256" // HMAC-SHA256签名
+)
+
+/**
+ * 可安全重复提交的请求，如查询、关闭订单、相同商户退款单号的退款
+ * 实现该接口的请求在网关故障时会切换到备用域名
+ */
+type Idempotent interface {
+	IsIdempotent() bool
+}
+
+/**
+ * 网关响应了5xx状态码
+ */
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (m *StatusError) Error() string {
+	return fmt.Sprintf("wxpay: gateway responded with status %d", m.StatusCode)
+}
+
+/**
+ * 网关地址及其健康状态
+ */
+type endpoint struct {
+	host      string
+	downUntil time.Time
+}
+
+type endpointList struct {
+	mutex       sync.Mutex
+	endpoints   []*endpoint
+	failoverTTL time.Duration
+}
+
+func newEndpointList(hosts ...string) *endpointList {
+	list := &endpointList{failoverTTL: DefaultFailoverTTL}
+	list.set(hosts...)
+	return list
+}
+
+func (m *endpointList) set(hosts ...string) {
+	endpoints := make([]*endpoint, 0, len(hosts))
+	for _, host := range hosts {
+		if !strings.HasSuffix(host, "/") {
+			host += "/"
+		}
+		endpoints = append(endpoints, &endpoint{host: host})
+	}
+
+	m.mutex.Lock()
+	m.endpoints = endpoints
+	m.mutex.Unlock()
+}
+
+/**
+ * 主网关地址
+ */
+func (m *endpointList) primary() string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(m.endpoints) == 0 {
+		return ""
+	}
+	return m.endpoints[0].host
+}
+
+/**
+ * 按优先级返回网关地址，健康的地址在前，故障中的地址作为最后的选择
+ */
+func (m *endpointList) ordered() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	healthy := make([]string, 0, len(m.endpoints))
+	unhealthy := make([]string, 0, len(m.endpoints))
+	for _, item := range m.endpoints {
+		if now.Before(item.downUntil) {
+			unhealthy = append(unhealthy, item.host)
+		} else {
+			healthy = append(healthy, item.host)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+func (m *endpointList) markDown(host string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, item := range m.endpoints {
+		if item.host == host {
+			item.downUntil = time.Now().Add(m.failoverTTL)
+		}
+	}
+}
+
+func (m *endpointList) markUp(host string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, item := range m.endpoints {
+		if item.host == host {
+			item.downUntil = time.Time{}
+		}
+	}
+}
+
+/**
+ * 是否为域名解析或建立连接失败，此时请求未到达网关，任何请求都可以安全切换
+ */
+func isDialError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return opErr.Op == "dial"
+	}
+	return false
+}
+
+/**
+ * 是否为网关故障，可以切换到其它网关重试幂等请求
+ */
+func isGatewayError(err error) bool {
+	if isDialError(err) {
+		return true
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return true
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Timeout()
+	}
+	return false
+}
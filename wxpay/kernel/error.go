@@ -0,0 +1,79 @@
+package kernel
+
+import (
+	"strings"
+
+	"github.com/shinmigo/gopay"
+)
+
+/**
+ * 业务错误码对应的错误分类，可按需补充
+ */
+var ErrCodeErrors = map[string]error{
+	"ORDERPAID":         gopay.ErrOrderPaid,
+	"ORDERNOTEXIST":     gopay.ErrOrderNotExist,
+	"REFUNDNOTEXIST":    gopay.ErrOrderNotExist,
+	"NOTENOUGH":         gopay.ErrInsufficientBalance,
+	"SIGNERROR":         gopay.ErrSignatureInvalid,
+	"SYSTEMERROR":       gopay.ErrSystemBusy,
+	"BANKERROR":         gopay.ErrSystemBusy,
+	"FREQUENCY_LIMITED": gopay.ErrSystemBusy,
+}
+
+/**
+ * 通信失败，return_code 为 FAIL
+ */
+func returnError(xmlHandler XmlToMap, raw []byte) *gopay.Error {
+	returnMsg := xmlHandler.Get("return_msg")
+	var kind error
+	if strings.Contains(returnMsg, "签名错误") {
+		kind = gopay.ErrSignatureInvalid
+	}
+
+	return &gopay.Error{
+		Provider: gopay.ProviderWxPay,
+		Code:     xmlHandler.Get("return_code"),
+		Message:  returnMsg,
+		Raw:      raw,
+		Kind:     kind,
+	}
+}
+
+/**
+ * 业务失败，result_code 为 FAIL
+ */
+func resultError(xmlHandler XmlToMap, raw []byte) *gopay.Error {
+	errCode := xmlHandler.Get("err_code")
+
+	return &gopay.Error{
+		Provider: gopay.ProviderWxPay,
+		Code:     xmlHandler.Get("result_code"),
+		SubCode:  errCode,
+		Message:  xmlHandler.Get("err_code_des"),
+		Raw:      raw,
+		Kind:     ErrCodeErrors[errCode],
+	}
+}
+
+/**
+ * 响应格式错误
+ */
+func parseError(raw []byte) *gopay.Error {
+	return &gopay.Error{
+		Provider: gopay.ProviderWxPay,
+		Message:  "解析失败！",
+		Raw:      raw,
+	}
+}
+
+/**
+ * 签名验证失败
+ */
+func signatureError(raw []byte) *gopay.Error {
+	return &gopay.Error{
+		Provider: gopay.ProviderWxPay,
+		Message:  "签名验证失败",
+		Raw:      raw,
+		Kind:     gopay.ErrSignatureInvalid,
+	}
+}
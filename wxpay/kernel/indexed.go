@@ -0,0 +1,216 @@
+package kernel
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/**
+ * 下标占位符，依次对应第一层与第二层列表的下标，如 coupon_refund_id_$n_$m
+ */
+var indexPlaceholders = []string{"$n", "$m"}
+
+/**
+ * 解析微信支付XML报文，并将带下标的参数解析到列表字段
+ * 列表字段使用 indexed 标签指定数量参数，元素字段的 xml 标签使用下标占位符，如
+ *   Coupons []*Coupon `xml:"-" indexed:"coupon_count"`
+ *   CouponId string `xml:"coupon_id_$n"`
+ * 数量参数不存在时按下标顺序读取，直到元素的参数均不存在
+ * gopay.Amount 字段使用 amount 标签指定分为单位的参数，可与原有字段对应同一参数，如
+ *   TotalFee    int          `xml:"total_fee"`
+ *   TotalAmount gopay.Amount `xml:"-" amount:"total_fee"`
+ */
+func UnmarshalXml(data []byte, result interface{}) error {
+	if err := xml.Unmarshal(data, result); err != nil {
+		return err
+	}
+	xmlHandler := make(XmlToMap)
+	if err := xml.Unmarshal(data, &xmlHandler); err != nil {
+		return err
+	}
+	if value := indirect(reflect.ValueOf(result)); value.Kind() == reflect.Struct {
+		if err := decodeAmountFields(xmlHandler, value, nil); err != nil {
+			return err
+		}
+	}
+	return DecodeIndexed(xmlHandler, result)
+}
+
+/**
+ * 将带下标的参数解析到列表字段，result 为结构体指针
+ */
+func DecodeIndexed(xmlHandler XmlToMap, result interface{}) error {
+	value := reflect.ValueOf(result)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	return decodeIndexedStruct(xmlHandler, value, nil)
+}
+
+func decodeIndexedStruct(xmlHandler XmlToMap, value reflect.Value, indexes []int) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		countKey, ok := field.Tag.Lookup("indexed")
+		if !ok || field.PkgPath != "" || field.Type.Kind() != reflect.Slice {
+			continue
+		}
+		if err := decodeIndexedSlice(xmlHandler, value.Field(i), indexedKey(countKey, indexes), indexes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeIndexedSlice(xmlHandler XmlToMap, value reflect.Value, countKey string, indexes []int) error {
+	elemType := value.Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct || len(indexes) >= len(indexPlaceholders) {
+		return nil
+	}
+
+	count := -1
+	if countValue := xmlHandler.Get(countKey); len(countValue) > 0 {
+		parsed, err := strconv.Atoi(countValue)
+		if err != nil {
+			return fmt.Errorf("wxpay: invalid %s: %q", countKey, countValue)
+		}
+		//数量不超过参数总数，避免异常的数量参数
+		count = parsed
+		if count > len(xmlHandler) {
+			count = len(xmlHandler)
+		}
+	}
+
+	slice := reflect.MakeSlice(value.Type(), 0, 0)
+	for index := 0; count < 0 || index < count; index++ {
+		elemIndexes := append(append([]int{}, indexes...), index)
+		elem := reflect.New(structType).Elem()
+		found, err := decodeIndexedFields(xmlHandler, elem, elemIndexes)
+		if err != nil {
+			return err
+		}
+		if count < 0 && !found {
+			break
+		}
+		if err = decodeAmountFields(xmlHandler, elem, elemIndexes); err != nil {
+			return err
+		}
+		if err = decodeIndexedStruct(xmlHandler, elem, elemIndexes); err != nil {
+			return err
+		}
+		if elemType.Kind() == reflect.Ptr {
+			slice = reflect.Append(slice, elem.Addr())
+		} else {
+			slice = reflect.Append(slice, elem)
+		}
+	}
+	if slice.Len() > 0 {
+		value.Set(slice)
+	}
+	return nil
+}
+
+/**
+ * 解析元素的基本类型字段，返回是否存在任一参数
+ */
+func decodeIndexedFields(xmlHandler XmlToMap, value reflect.Value, indexes []int) (bool, error) {
+	found := false
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		name := strings.Split(field.Tag.Get("xml"), ",")[0]
+		if field.PkgPath != "" || name == "" || name == "-" {
+			continue
+		}
+		paramValue, ok := xmlHandler[indexedKey(name, indexes)]
+		if !ok || len(paramValue) == 0 {
+			continue
+		}
+		found = true
+		if err := setParamField(value.Field(i), paramValue[0], indexedKey(name, indexes)); err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+/**
+ * 按 amount 标签解析 gopay.Amount 字段，单位为分
+ */
+func decodeAmountFields(xmlHandler XmlToMap, value reflect.Value, indexes []int) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		name, ok := field.Tag.Lookup("amount")
+		if !ok || field.PkgPath != "" || field.Type != amountType {
+			continue
+		}
+		key := indexedKey(name, indexes)
+		if err := setParamField(value.Field(i), xmlHandler.Get(key), key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setParamField(value reflect.Value, paramValue, key string) error {
+	paramValue = strings.TrimSpace(paramValue)
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(paramValue)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if len(paramValue) == 0 {
+			return nil
+		}
+		parsed, err := strconv.ParseInt(paramValue, 10, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("wxpay: invalid %s: %q", key, paramValue)
+		}
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if len(paramValue) == 0 {
+			return nil
+		}
+		parsed, err := strconv.ParseUint(paramValue, 10, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("wxpay: invalid %s: %q", key, paramValue)
+		}
+		value.SetUint(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(paramValue)
+		if err != nil {
+			return fmt.Errorf("wxpay: invalid %s: %q", key, paramValue)
+		}
+		value.SetBool(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(paramValue, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("wxpay: invalid %s: %q", key, paramValue)
+		}
+		value.SetFloat(parsed)
+	}
+	return nil
+}
+
+/**
+ * 将参数名中的下标占位符替换为下标
+ */
+func indexedKey(name string, indexes []int) string {
+	for level, index := range indexes {
+		name = strings.ReplaceAll(name, indexPlaceholders[level], strconv.Itoa(index))
+	}
+	return name
+}
@@ -0,0 +1,210 @@
+package kernel_test
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shinmigo/gopay"
+	"github.com/shinmigo/gopay/wxpay/kernel"
+	"github.com/shinmigo/gopay/wxpay/payment"
+)
+
+const testMd5Key = "0123456789abcdef0123456789abcdef"
+
+type rawParams url.Values
+
+func (m rawParams) Params() url.Values {
+	return url.Values(m)
+}
+
+func xmlMap(params map[string]string) kernel.XmlToMap {
+	xmlHandler := make(kernel.XmlToMap)
+	for key, value := range params {
+		xmlHandler.Set(key, value)
+	}
+	return xmlHandler
+}
+
+func TestDecodeIndexed(t *testing.T) {
+	cases := []struct {
+		name   string
+		params map[string]string
+		result interface{}
+		want   interface{}
+	}{
+		{
+			name: "query coupons",
+			params: map[string]string{
+				"coupon_count":  "2",
+				"coupon_type_0": "CASH", "coupon_id_0": "C0", "coupon_fee_0": "10",
+				"coupon_type_1": "NO_CASH", "coupon_id_1": "C1", "coupon_fee_1": "20",
+			},
+			result: &payment.TradeQueryRes{},
+			want: &payment.TradeQueryRes{Coupons: []*payment.Coupon{
+				{CouponType: "CASH", CouponId: "C0", CouponFee: 10},
+				{CouponType: "NO_CASH", CouponId: "C1", CouponFee: 20},
+			}},
+		},
+		{
+			name: "notify coupons limited by count",
+			params: map[string]string{
+				"coupon_count": "1",
+				"coupon_id_0":  "C0", "coupon_fee_0": "10",
+				"coupon_id_1": "C1", "coupon_fee_1": "20",
+			},
+			result: &payment.NotifyRes{},
+			want:   &payment.NotifyRes{Coupons: []*payment.Coupon{{CouponId: "C0", CouponFee: 10}}},
+		},
+		{
+			name: "count-less coupons stop at the first missing index",
+			params: map[string]string{
+				"coupon_id_0": "C0", "coupon_fee_0": "10",
+				"coupon_id_1": "C1",
+				"coupon_id_3": "C3",
+			},
+			result: &payment.NotifyRes{},
+			want: &payment.NotifyRes{Coupons: []*payment.Coupon{
+				{CouponId: "C0", CouponFee: 10},
+				{CouponId: "C1"},
+			}},
+		},
+		{
+			name:   "no coupons",
+			params: map[string]string{"coupon_count": "0", "coupon_id_0": "C0"},
+			result: &payment.TradeQueryRes{},
+			want:   &payment.TradeQueryRes{},
+		},
+		{
+			name: "refunds with nested coupons",
+			params: map[string]string{
+				"refund_count":    "2",
+				"out_refund_no_0": "R0", "refund_id_0": "50000", "refund_fee_0": "300", "refund_status_0": "SUCCESS",
+				"coupon_refund_count_0": "2",
+				"coupon_refund_id_0_0":  "CR0", "coupon_refund_fee_0_0": "5", "coupon_type_0_0": "CASH",
+				"coupon_refund_id_0_1": "CR1", "coupon_refund_fee_0_1": "6",
+				"out_refund_no_1": "R1", "refund_id_1": "50001", "refund_fee_1": "200", "refund_status_1": "PROCESSING",
+			},
+			result: &payment.RefundQueryRes{},
+			want: &payment.RefundQueryRes{Refunds: []*payment.RefundItem{
+				{
+					OutRefundNo: "R0", RefundId: "50000", RefundFee: 300, RefundAmount: gopay.Fen(300), RefundStatus: "SUCCESS", CouponRefundCount: 2,
+					Coupons: []*payment.RefundCoupon{
+						{CouponRefundId: "CR0", CouponRefundFee: 5, CouponType: "CASH"},
+						{CouponRefundId: "CR1", CouponRefundFee: 6},
+					},
+				},
+				{OutRefundNo: "R1", RefundId: "50001", RefundFee: 200, RefundAmount: gopay.Fen(200), RefundStatus: "PROCESSING"},
+			}},
+		},
+		{
+			name: "count-less refunds and nested coupons",
+			params: map[string]string{
+				"out_refund_no_0": "R0", "refund_fee_0": "300",
+				"coupon_refund_id_0_0": "CR0",
+				"out_refund_no_1":      "R1",
+				"coupon_refund_id_1_0": "CR2", "coupon_refund_id_1_1": "CR3",
+			},
+			result: &payment.RefundQueryRes{},
+			want: &payment.RefundQueryRes{Refunds: []*payment.RefundItem{
+				{OutRefundNo: "R0", RefundFee: 300, RefundAmount: gopay.Fen(300), Coupons: []*payment.RefundCoupon{{CouponRefundId: "CR0"}}},
+				{OutRefundNo: "R1", Coupons: []*payment.RefundCoupon{{CouponRefundId: "CR2"}, {CouponRefundId: "CR3"}}},
+			}},
+		},
+	}
+	for _, item := range cases {
+		t.Run(item.name, func(t *testing.T) {
+			if err := kernel.DecodeIndexed(xmlMap(item.params), item.result); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(item.result, item.want) {
+				t.Errorf("result = %+v, want %+v", item.result, item.want)
+			}
+		})
+	}
+}
+
+func TestDecodeIndexedInvalid(t *testing.T) {
+	cases := []struct {
+		name   string
+		params map[string]string
+		result interface{}
+	}{
+		{"invalid count", map[string]string{"coupon_count": "x"}, &payment.NotifyRes{}},
+		{"invalid coupon fee", map[string]string{"coupon_count": "1", "coupon_fee_0": "1.5"}, &payment.NotifyRes{}},
+		{"invalid nested count", map[string]string{"refund_count": "1", "refund_id_0": "50000", "coupon_refund_count_0": "x"}, &payment.RefundQueryRes{}},
+		{"invalid refund amount", map[string]string{"refund_count": "1", "refund_fee_0": "-"}, &payment.RefundQueryRes{}},
+	}
+	for _, item := range cases {
+		t.Run(item.name, func(t *testing.T) {
+			if err := kernel.DecodeIndexed(xmlMap(item.params), item.result); err == nil {
+				t.Errorf("DecodeIndexed(%v) = nil, want error", item.params)
+			}
+		})
+	}
+
+	//非结构体指针不解析
+	if err := kernel.DecodeIndexed(xmlMap(map[string]string{"coupon_count": "x"}), (*payment.NotifyRes)(nil)); err != nil {
+		t.Errorf("DecodeIndexed(nil) = %v", err)
+	}
+}
+
+/**
+ * 组装带签名的通知报文
+ */
+func signedNotify(client *kernel.WxClient, signType string, params map[string]string) string {
+	values := rawParams{}
+	for key, value := range params {
+		url.Values(values).Set(key, value)
+	}
+	return kernel.EncodeXml(client.SignParams(values, signType))
+}
+
+func TestNotifyVerifyIndexed(t *testing.T) {
+	params := map[string]string{
+		"return_code":    "SUCCESS",
+		"result_code":    "SUCCESS",
+		"transaction_id": "4200000001",
+		"out_trade_no":   "T1001",
+		"total_fee":      "100",
+		"cash_fee":       "70",
+		"coupon_fee":     "30",
+		"coupon_count":   "2",
+		"coupon_id_0":    "C0", "coupon_fee_0": "10",
+		"coupon_id_1": "C1", "coupon_fee_1": "20",
+		//结构体未定义的参数同样参与签名
+		"coupon_batch_id_0": "B0",
+	}
+	for _, signType := range []string{kernel.SignTypeMD5, kernel.SignTypeHMACSHA256} {
+		t.Run(signType, func(t *testing.T) {
+			client := kernel.NewWxClient("wx123", "1900000109", testMd5Key, true)
+			client.SetSignType(signType)
+			pay := &payment.Payment{Client: client}
+			notifyXml := signedNotify(client, signType, params)
+
+			res, err := pay.NotifyVerify([]byte(notifyXml))
+			if err != nil {
+				t.Fatalf("NotifyVerify: %v", err)
+			}
+			want := []*payment.Coupon{{CouponId: "C0", CouponFee: 10}, {CouponId: "C1", CouponFee: 20}}
+			if !reflect.DeepEqual(res.Coupons, want) || res.TotalAmount != gopay.Fen(100) || res.CashAmount != gopay.Fen(70) {
+				t.Errorf("notify = %+v, coupons = %+v", res, res.Coupons)
+			}
+
+			for _, tampered := range []string{
+				strings.Replace(notifyXml, "<coupon_fee_1><![CDATA[20]]>", "<coupon_fee_1><![CDATA[25]]>", 1),
+				strings.Replace(notifyXml, "<![CDATA[B0]]>", "<![CDATA[B9]]>", 1),
+				strings.Replace(notifyXml, "</xml>", "<coupon_id_2><![CDATA[C2]]></coupon_id_2></xml>", 1),
+			} {
+				if tampered == notifyXml {
+					t.Fatalf("tampering did not change the notify: %s", notifyXml)
+				}
+				if _, err = pay.NotifyVerify([]byte(tampered)); !errors.Is(err, gopay.ErrSignatureInvalid) {
+					t.Errorf("NotifyVerify tampered: got %v, want %v", err, gopay.ErrSignatureInvalid)
+				}
+			}
+		})
+	}
+}
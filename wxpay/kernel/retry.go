@@ -0,0 +1,17 @@
+package kernel
+
+import (
+	"errors"
+
+	"github.com/shinmigo/gopay"
+)
+
+/**
+ * 默认错误分类：网关故障与系统繁忙类业务错误可重试
+ */
+func isRetryable(err error) bool {
+	if isGatewayError(err) {
+		return true
+	}
+	return errors.Is(err, gopay.ErrSystemBusy)
+}
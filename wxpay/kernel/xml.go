@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"sort"
+	"strings"
 )
 
 /**
@@ -67,17 +69,27 @@ func (m XmlToMap) Del(key string) {
 }
 
 /**
- * Map转XML
+ * Map转XML，参数按名称排序，numberKeys 中的参数直接输出，其他值使用CDATA并转义其中的 ]]>
  */
-func mapToXml(params url.Values) string {
+func EncodeXml(params url.Values, numberKeys ...string) string {
+	paramKeys := make([]string, 0, len(params))
+	for paramKey := range params {
+		paramKeys = append(paramKeys, paramKey)
+	}
+	sort.Strings(paramKeys)
+	numbers := make(map[string]bool, len(numberKeys))
+	for _, numberKey := range numberKeys {
+		numbers[numberKey] = true
+	}
+
 	buffer := &bytes.Buffer{}
 	buffer.WriteString("<xml>")
-
-	for paramKey := range params {
+	for _, paramKey := range paramKeys {
 		paramValue := params.Get(paramKey)
-		if paramKey == "total_fee" || paramKey == "refund_fee" || paramKey == "execute_time_" {
+		if numbers[paramKey] {
 			buffer.WriteString(fmt.Sprintf("<%v>%v</%v>", paramKey, paramValue, paramKey))
 		} else {
+			paramValue = strings.ReplaceAll(paramValue, "]]>", "]]]]><![CDATA[>")
 			buffer.WriteString(fmt.Sprintf("<%v><![CDATA[%v]]></%v>", paramKey, paramValue, paramKey))
 		}
 	}
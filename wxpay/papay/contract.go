@@ -0,0 +1,151 @@
+package papay
+
+import (
+	"net/url"
+
+	"github.com/shinmigo/gopay/wxpay/kernel"
+)
+
+const (
+	ContractVersion = "1.0"
+
+	ChangeTypeAdd    = "ADD"    // 签约
+	ChangeTypeDelete = "DELETE" // 解约
+
+	TradeTypePap = "PAP" // 委托代扣
+)
+
+/**
+ * 公众号纯签约，Timestamp 为0时使用当前时间
+ */
+type EntrustWeb struct {
+	PlanId                 string `xml:"plan_id"`                  //模板id
+	ContractCode           string `xml:"contract_code"`            //签约协议号
+	RequestSerial          uint64 `xml:"request_serial"`           //请求序列号
+	ContractDisplayAccount string `xml:"contract_display_account"` //用户账户展示名称
+	NotifyUrl              string `xml:"notify_url"`               //回调通知url
+	Version                string `xml:"version" default:"1.0"`    //版本号，固定值1.0
+	Timestamp              int64  `xml:"timestamp"`                //时间戳 秒
+	ReturnWeb              string `xml:"return_web"`               //返回web
+}
+
+/**
+ * 查询签约关系
+ */
+type QueryContract struct {
+	ContractId   string `xml:"contract_id"`           //委托代扣协议id，与 PlanId+ContractCode 二选一
+	PlanId       string `xml:"plan_id"`               //模板id
+	ContractCode string `xml:"contract_code"`         //签约协议号
+	Version      string `xml:"version" default:"1.0"` //版本号，固定值1.0
+}
+
+func (m *QueryContract) IsIdempotent() bool {
+	return true
+}
+
+type QueryContractRes struct {
+	ReturnCode                string `xml:"return_code"`                 //返回状态码
+	ReturnMsg                 string `xml:"return_msg"`                  //返回信息
+	ResultCode                string `xml:"result_code"`                 //业务结果
+	ErrCode                   string `xml:"err_code"`                    //错误代码
+	ErrCodeDes                string `xml:"err_code_des"`                //错误代码描述
+	AppId                     string `xml:"appid"`                       //应用APPId
+	MchId                     string `xml:"mch_id"`                      //商户号
+	Sign                      string `xml:"sign"`                        //签名
+	ContractId                string `xml:"contract_id"`                 //委托代扣协议id
+	PlanId                    string `xml:"plan_id"`                     //模板id
+	RequestSerial             string `xml:"request_serial"`              //请求序列号
+	ContractCode              string `xml:"contract_code"`               //签约协议号
+	ContractDisplayAccount    string `xml:"contract_display_account"`    //用户账户展示名称
+	ContractState             int    `xml:"contract_state"`              //协议状态 0-签约中 1-解约
+	ContractSignedTime        string `xml:"contract_signed_time"`        //协议签署时间
+	ContractExpiredTime       string `xml:"contract_expired_time"`       //协议到期时间
+	ContractTerminatedTime    string `xml:"contract_terminated_time"`    //协议解约时间
+	ContractTerminationMode   int    `xml:"contract_termination_mode"`   //协议解约方式 0-未解约 1-有效期过自动解约 2-用户主动解约 3-商户API解约 4-商户平台解约 5-注销
+	ContractTerminationRemark string `xml:"contract_termination_remark"` //解约备注
+	OpenId                    string `xml:"openid"`                      //用户标识
+}
+
+/**
+ * 申请解约
+ */
+type DeleteContract struct {
+	ContractId                string `xml:"contract_id"`                 //委托代扣协议id，与 PlanId+ContractCode 二选一
+	PlanId                    string `xml:"plan_id"`                     //模板id
+	ContractCode              string `xml:"contract_code"`               //签约协议号
+	ContractTerminationRemark string `xml:"contract_termination_remark"` //解约备注
+	Version                   string `xml:"version" default:"1.0"`       //版本号，固定值1.0
+}
+
+type DeleteContractRes struct {
+	ReturnCode   string `xml:"return_code"`   //返回状态码
+	ReturnMsg    string `xml:"return_msg"`    //返回信息
+	ResultCode   string `xml:"result_code"`   //业务结果
+	ErrCode      string `xml:"err_code"`      //错误代码
+	ErrCodeDes   string `xml:"err_code_des"`  //错误代码描述
+	MchId        string `xml:"mch_id"`        //商户号
+	Sign         string `xml:"sign"`          //签名
+	ContractId   string `xml:"contract_id"`   //委托代扣协议id
+	PlanId       string `xml:"plan_id"`       //模板id
+	ContractCode string `xml:"contract_code"` //签约协议号
+}
+
+/**
+ * 申请扣款
+ */
+type PayApply struct {
+	Body           string `xml:"body"`                   //商品描述
+	Detail         string `xml:"detail"`                 //商品详情
+	Attach         string `xml:"attach"`                 //附加数据
+	OutTradeNo     string `xml:"out_trade_no"`           //商户订单号
+	TotalFee       uint64 `xml:"total_fee" wx:"number"`  //总金额 分
+	FeeType        string `xml:"fee_type" default:"CNY"` //货币类型
+	SpbillCreateIp string `xml:"spbill_create_ip"`       //终端IP
+	GoodsTag       string `xml:"goods_tag"`              //商品标记
+	NotifyUrl      string `xml:"notify_url"`             //回调通知url
+	ContractId     string `xml:"contract_id"`            //委托代扣协议id
+}
+
+/**
+ * 交易类型固定为 PAP，其他参数按 xml 标签生成
+ */
+func (m *PayApply) Params() url.Values {
+	paramMap := kernel.EncodeParams(m)
+	paramMap.Set("trade_type", TradeTypePap)
+
+	return paramMap
+}
+
+type PayApplyRes struct {
+	ReturnCode string `xml:"return_code"`  //返回状态码
+	ReturnMsg  string `xml:"return_msg"`   //返回信息
+	AppId      string `xml:"appid"`        //应用APPId
+	MchId      string `xml:"mch_id"`       //商户号
+	NonceStr   string `xml:"nonce_str"`    //随机字符串
+	Sign       string `xml:"sign"`         //签名
+	ResultCode string `xml:"result_code"`  //业务结果
+	ErrCode    string `xml:"err_code"`     //错误代码
+	ErrCodeDes string `xml:"err_code_des"` //错误代码描述
+}
+
+/**
+ * 签约、解约结果通知
+ */
+type ContractNotifyRes struct {
+	ReturnCode              string `xml:"return_code"`               //返回状态码
+	ReturnMsg               string `xml:"return_msg"`                //返回信息
+	ResultCode              string `xml:"result_code"`               //业务结果
+	ErrCode                 string `xml:"err_code"`                  //错误代码
+	ErrCodeDes              string `xml:"err_code_des"`              //错误代码描述
+	MchId                   string `xml:"mch_id"`                    //商户号
+	Sign                    string `xml:"sign"`                      //签名
+	ContractCode            string `xml:"contract_code"`             //签约协议号
+	PlanId                  string `xml:"plan_id"`                   //模板id
+	OpenId                  string `xml:"openid"`                    //用户标识
+	ChangeType              string `xml:"change_type"`               //变更类型 ADD-签约 DELETE-解约
+	OperateTime             string `xml:"operate_time"`              //操作时间
+	ContractId              string `xml:"contract_id"`               //委托代扣协议id
+	ContractExpiredTime     string `xml:"contract_expired_time"`     //协议到期时间
+	ContractTerminationMode int    `xml:"contract_termination_mode"` //协议解约方式
+	RequestSerial           string `xml:"request_serial"`            //请求序列号
+}
@@ -0,0 +1,77 @@
+package papay
+
+import (
+	"encoding/xml"
+	"time"
+
+	"github.com/shinmigo/gopay/wxpay/kernel"
+)
+
+type Papay struct {
+	Client *kernel.WxClient
+}
+
+/**
+ * 公众号纯签约跳转地址
+ */
+func (m *Papay) EntrustWeb(param *EntrustWeb) string {
+	if param == nil {
+		return ""
+	}
+	if param.Timestamp == 0 {
+		entrustWeb := *param
+		entrustWeb.Timestamp = time.Now().Unix()
+		param = &entrustWeb
+	}
+
+	return m.Client.SignUrl("papay/entrustweb", param)
+}
+
+/**
+ * 查询签约关系
+ */
+func (m *Papay) QueryContract(param *QueryContract) (result *QueryContractRes, err error) {
+	if param == nil {
+		return nil, nil
+	}
+
+	err = m.Client.SendRequest("POST", "papay/querycontract", param, &result)
+	return
+}
+
+/**
+ * 申请解约
+ */
+func (m *Papay) DeleteContract(param *DeleteContract) (result *DeleteContractRes, err error) {
+	if param == nil {
+		return nil, nil
+	}
+
+	err = m.Client.SendRequest("POST", "papay/deletecontract", param, &result)
+	return
+}
+
+/**
+ * 申请扣款
+ */
+func (m *Papay) PayApply(param *PayApply) (result *PayApplyRes, err error) {
+	if param == nil {
+		return nil, nil
+	}
+
+	err = m.Client.SendRequest("POST", "pay/pappayapply", param, &result)
+	return
+}
+
+/**
+ * 签约、解约结果通知验证签名
+ */
+func (m *Papay) ContractNotifyVerify(reqBody []byte) (res *ContractNotifyRes, err error) {
+	err = m.Client.VerifyNotify(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	err = xml.Unmarshal(reqBody, &res)
+	return res, err
+}
@@ -1,9 +1,15 @@
 package payment
 
 import (
+	"bytes"
+	"context"
 	"encoding/xml"
+	"errors"
 	"net/url"
+	"strings"
 	
+	"github.com/shinmigo/gopay"
+	"github.com/shinmigo/gopay/store"
 	"github.com/shinmigo/gopay/wxpay/kernel"
 )
 
@@ -19,15 +25,146 @@ func (m *Payment) Pay(param *Trade) (result *TradeRes, err error) {
 		return nil, nil
 	}
 	
+	if len(param.NotifyUrl) == 0 {
+		param.NotifyUrl = m.Client.GetNotifyUrl()
+	}
+	validator := &gopay.Validator{}
+	param.validate(validator)
+	validator.Check(len(param.SubOpenId) == 0 || len(m.Client.GetSubAppId()) > 0, "sub_openid", "requires sub_appid")
+	if err = validator.Err(); err != nil {
+		return nil, err
+	}
 	err = m.Client.SendRequest("POST", "pay/unifiedorder", param, &result)
 	return
 }
 
+/**
+ * 公众号调起支付的参数，签名类型与客户端一致
+ * 服务商模式下设置了子商户应用ID时使用 sub_appid，此时下单需传入 sub_openid
+ */
 func (m *Payment) Jsapi(param *TradeRes) (result url.Values) {
 	if param == nil {
 		return nil
 	}
-	result = m.Client.Jsapi("MD5", param.PrepayId, param.NonceStr)
+	result = m.Client.Jsapi(m.Client.GetSignType(), param.PrepayId, param.NonceStr)
+	return
+}
+
+/**
+ * 小程序调起支付的参数，appId 为小程序的应用ID，下单时需使用同一应用ID
+ */
+func (m *Payment) MiniProgram(appId string, param *TradeRes) (result url.Values) {
+	if param == nil {
+		return nil
+	}
+	result = m.Client.MiniProgram(appId, param.PrepayId, param.NonceStr)
+	return
+}
+
+/**
+ * APP调起支付的参数，用于客户端SDK的 PayReq
+ */
+func (m *Payment) App(param *TradeRes) (result url.Values) {
+	if param == nil {
+		return nil
+	}
+	result = m.Client.App(param.PrepayId, param.NonceStr)
+	return
+}
+
+/**
+ * H5支付的跳转链接，redirectUrl 为支付完成后的跳转地址，为空时返回 mweb_url
+ */
+func (m *Payment) MwebUrl(param *TradeRes, redirectUrl string) (string, error) {
+	if param == nil {
+		return "", nil
+	}
+	if len(param.MwebUrl) == 0 {
+		return "", errors.New("mweb_url 不能为空")
+	}
+	if len(redirectUrl) == 0 {
+		return param.MwebUrl, nil
+	}
+	
+	separator := "&"
+	if !strings.Contains(param.MwebUrl, "?") {
+		separator = "?"
+	}
+	return param.MwebUrl + separator + "redirect_url=" + url.QueryEscape(redirectUrl), nil
+}
+
+/**
+ * 扫码支付模式一的商品二维码链接 weixin://wxpay/bizpayurl?...
+ */
+func (m *Payment) NativeUrl(param *NativeProduct) (string, error) {
+	if param == nil {
+		return "", nil
+	}
+	
+	if err := param.Validate(); err != nil {
+		return "", err
+	}
+	return NativeUrlPrefix + m.Client.SignParams(param, kernel.SignTypeMD5).Encode(), nil
+}
+
+/**
+ * 处理扫码支付模式一的商品回调，返回需要响应给微信的报文
+ * handler 根据商品ID与用户标识返回下单参数，交易类型与商品ID由回调参数填充
+ * 下单失败时响应中的 err_code_des 会展示给用户，返回的错误用于记录日志
+ */
+func (m *Payment) NativeCallback(reqBody []byte, handler func(callback *NativeCallback) (*Trade, error)) ([]byte, error) {
+	if err := m.Client.VerifyCallback(reqBody); err != nil {
+		return m.nativeReply(&NativeCallbackReply{ReturnCode: "FAIL", ReturnMsg: "签名失败"}), err
+	}
+	callback := &NativeCallback{}
+	if err := xml.Unmarshal(reqBody, callback); err != nil {
+		return m.nativeReply(&NativeCallbackReply{ReturnCode: "FAIL", ReturnMsg: "参数格式校验错误"}), err
+	}
+	
+	trade, err := handler(callback)
+	if err == nil && trade == nil {
+		err = errors.New("下单参数不能为空")
+	}
+	var result *TradeRes
+	if err == nil {
+		trade.TradeType = WX_NATIVE
+		trade.ProductId = callback.ProductId
+		result, err = m.Pay(trade)
+	}
+	if err != nil {
+		return m.nativeReply(&NativeCallbackReply{ReturnCode: "SUCCESS", ResultCode: "FAIL", ErrCodeDes: nativeErrorMessage(err)}), err
+	}
+	
+	return m.nativeReply(&NativeCallbackReply{ReturnCode: "SUCCESS", ResultCode: "SUCCESS", PrepayId: result.PrepayId}), nil
+}
+
+func (m *Payment) nativeReply(reply *NativeCallbackReply) []byte {
+	return []byte(m.Client.SignXml(reply))
+}
+
+/**
+ * 展示给用户的下单失败原因，只使用微信支付返回的错误描述
+ */
+func nativeErrorMessage(err error) string {
+	var payErr *gopay.Error
+	if errors.As(err, &payErr) && len(payErr.Message) > 0 {
+		return payErr.Message
+	}
+	return "下单失败，请稍后再试"
+}
+
+/**
+ * 转换短链接，用于生成更易识别的二维码
+ */
+func (m *Payment) ShortUrl(param *ShortUrl) (result *ShortUrlRes, err error) {
+	if param == nil {
+		return nil, nil
+	}
+	
+	if err = param.Validate(); err != nil {
+		return nil, err
+	}
+	err = m.Client.SendRequest("POST", "tools/shorturl", param, &result)
 	return
 }
 
@@ -39,6 +176,9 @@ func (m *Payment) Query(param *TradeQuery) (result *TradeQueryRes, err error) {
 		return nil, nil
 	}
 	
+	if err = param.Validate(); err != nil {
+		return nil, err
+	}
 	err = m.Client.SendRequest("POST", "pay/orderquery", param, &result)
 	return
 }
@@ -51,19 +191,129 @@ func (m *Payment) Close(param *TradeClose) (result *TradeCloseRes, err error) {
 		return nil, nil
 	}
 	
+	if err = param.Validate(); err != nil {
+		return nil, err
+	}
 	err = m.Client.SendRequest("POST", "pay/closeorder", param, &result)
 	return
 }
 
+/**
+ * 微信申请退款
+ */
+func (m *Payment) Refund(param *Refund) (result *RefundRes, err error) {
+	if param == nil {
+		return nil, nil
+	}
+	
+	if err = param.Validate(); err != nil {
+		return nil, err
+	}
+	err = m.Client.SendRequest("POST", "secapi/pay/refund", param, &result)
+	return
+}
+
+/**
+ * 微信查询退款
+ */
+func (m *Payment) RefundQuery(param *RefundQuery) (result *RefundQueryRes, err error) {
+	if param == nil {
+		return nil, nil
+	}
+	
+	if err = param.Validate(); err != nil {
+		return nil, err
+	}
+	err = m.Client.SendRequest("POST", "pay/refundquery", param, &result)
+	return
+}
+
+/**
+ * 微信下载对账单，成功时返回对账单文本内容
+ */
+func (m *Payment) DownloadBill(param *DownloadBill) (result []byte, err error) {
+	if param == nil {
+		return nil, nil
+	}
+	
+	if err = param.Validate(); err != nil {
+		return nil, err
+	}
+	result, err = m.Client.SendRawRequest("POST", "pay/downloadbill", param)
+	if err != nil {
+		return nil, err
+	}
+	//失败时返回XML格式的错误信息
+	if bytes.HasPrefix(bytes.TrimSpace(result), []byte("<xml>")) {
+		billRes := &DownloadBillRes{}
+		if err = xml.Unmarshal(result, billRes); err != nil {
+			return nil, err
+		}
+		if billRes.ReturnCode != "SUCCESS" {
+			return nil, &gopay.Error{
+				Provider: gopay.ProviderWxPay,
+				Code:     billRes.ReturnCode,
+				SubCode:  billRes.ErrorCode,
+				Message:  billRes.ReturnMsg,
+				Raw:      result,
+			}
+		}
+	}
+	return
+}
+
 /**
  * 异步通知验证签名
  */
 func (m *Payment) NotifyVerify(reqBody []byte) (res *NotifyRes, err error) {
-	err = m.Client.VerifySign(reqBody)
+	err = m.Client.VerifyNotify(reqBody)
 	if err != nil {
 		return nil, err
 	}
 	
-	err = xml.Unmarshal(reqBody, &res)
+	err = kernel.UnmarshalXml(reqBody, &res)
 	return res, err
 }
+
+/**
+ * 支付结果通知验证签名，并按 transaction_id 幂等更新订单状态
+ * 首次收到的通知执行 handler，重复通知直接确认；返回需要响应给微信的报文
+ */
+func (m *Payment) NotifyProcess(ctx context.Context, orderStore store.Store, reqBody []byte, handler func(ctx context.Context, res *NotifyRes, event *store.Event) error) ([]byte, error) {
+	res, err := m.NotifyVerify(reqBody)
+	if err != nil {
+		return notifyReply("FAIL", "签名失败"), err
+	}
+	
+	state := store.StatePaid
+	if res.ResultCode != "SUCCESS" {
+		state = store.StateFailed
+	}
+	notifyId := res.TransactionId
+	if len(notifyId) == 0 {
+		notifyId = res.OutTradeNo + ":" + res.ResultCode
+	}
+	notification := &store.Notification{
+		Provider:      gopay.ProviderWxPay,
+		NotifyId:      notifyId,
+		OutTradeNo:    res.OutTradeNo,
+		TransactionId: res.TransactionId,
+		State:         state,
+		Amount:        res.TotalAmount,
+	}
+	_, err = store.Process(ctx, orderStore, notification, func(ctx context.Context, event *store.Event) error {
+		if handler == nil {
+			return nil
+		}
+		return handler(ctx, res, event)
+	})
+	if err != nil {
+		return notifyReply("FAIL", "处理失败"), err
+	}
+	return notifyReply("SUCCESS", "OK"), nil
+}
+
+func notifyReply(returnCode, returnMsg string) []byte {
+	reply, _ := xml.Marshal(&NotifyReply{ReturnCode: returnCode, ReturnMsg: returnMsg})
+	return reply
+}
@@ -1,8 +1,19 @@
 package payment
 
 import (
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"net/url"
+	"strconv"
+	"time"
+	
+	"github.com/shinmigo/gopay"
+	"github.com/shinmigo/gopay/wxpay/kernel"
+)
+
+const (
+	NativeUrlPrefix = "weixin://wxpay/bizpayurl?" //扫码支付模式一的商品二维码链接
 )
 
 const (
@@ -16,51 +27,84 @@ const (
  * 微信APP支付、公众号支付、小程序支付
  */
 type Trade struct {
-	DeviceInfo     string //设备号
-	Body           string //商品描述
-	Detail         string //商品详情
-	Attach         string //附加数据
-	OutTradeNo     string //商户订单号
-	FeeType        string //货币类型
-	TotalFee       uint64 //总金额 分
-	SpbillCreateIp string //终端IP 用户的客户端IP
-	TimeStart      string //订单生成时间
-	TimeExpire     string //订单失效时间
-	GoodsTag       string //订单优惠标记
-	NotifyUrl      string //异步通知回调地址
-	TradeType      string //支付类型
-	ProductId      string //商品ID
-	LimitPay       string //指定支付方式
-	OpenId         string //用户标识
-	Receipt        string //开发票入口开放标识
+	DeviceInfo     string       `xml:"device_info"`            //设备号
+	Body           string       `xml:"body"`                   //商品描述
+	Detail         string       `xml:"detail"`                 //商品详情
+	Attach         string       `xml:"attach"`                 //附加数据
+	OutTradeNo     string       `xml:"out_trade_no"`           //商户订单号
+	FeeType        string       `xml:"fee_type" default:"CNY"` //货币类型
+	TotalFee       uint64       `xml:"total_fee" wx:"number"`  //总金额 分
+	SpbillCreateIp string       `xml:"spbill_create_ip"`       //终端IP 用户的客户端IP
+	TimeStart      string       `xml:"time_start"`             //订单生成时间
+	TimeExpire     string       `xml:"time_expire"`            //订单失效时间
+	GoodsTag       string       `xml:"goods_tag"`              //订单优惠标记
+	NotifyUrl      string       `xml:"notify_url"`             //异步通知回调地址
+	TradeType      string       `xml:"trade_type"`             //支付类型
+	ProductId      string       `xml:"product_id"`             //商品ID
+	LimitPay       string       `xml:"limit_pay"`              //指定支付方式
+	OpenId         string       `xml:"openid"`                 //用户标识
+	SubOpenId      string       `xml:"sub_openid"`             //用户在子商户应用下的标识，服务商模式下与 OpenId 二选一，需设置子商户应用ID
+	Receipt        string       `xml:"receipt"`                //开发票入口开放标识
+	Amount         gopay.Amount `xml:"-"`                      //总金额，TotalFee 为0时使用
+	SceneInfo      *SceneInfo   `xml:"scene_info"`             //场景信息，H5支付必填
 }
 
-func (m *Trade) Params() url.Values {
-	paramMap := url.Values{}
-	paramMap.Set("device_info", m.DeviceInfo)
-	paramMap.Set("body", m.Body)
-	paramMap.Set("detail", m.Detail)
-	paramMap.Set("attach", m.Attach)
-	paramMap.Set("out_trade_no", m.OutTradeNo)
-	paramMap.Set("fee_type", m.FeeType)
-	if len(m.FeeType) == 0 {
-		paramMap.Set("fee_type", "CNY")
+/**
+ * H5支付的场景信息
+ */
+type SceneInfo struct {
+	Type        string `json:"type"`                   //场景类型 IOS、Android、Wap
+	WapUrl      string `json:"wap_url,omitempty"`      //WAP网站URL地址，Wap 必填
+	WapName     string `json:"wap_name,omitempty"`     //WAP网站名，Wap 必填
+	AppName     string `json:"app_name,omitempty"`     //应用名，IOS、Android 必填
+	BundleId    string `json:"bundle_id,omitempty"`    //IOS应用的bundle_id
+	PackageName string `json:"package_name,omitempty"` //Android应用的包名
+}
+
+const (
+	SCENE_IOS     = "IOS"
+	SCENE_ANDROID = "Android"
+	SCENE_WAP     = "Wap"
+)
+
+/**
+ * 序列化为 scene_info 参数 {"h5_info": {...}}
+ */
+func (m *SceneInfo) String() string {
+	if m == nil {
+		return ""
 	}
-	paramMap.Set("total_fee", fmt.Sprintf("%d", m.TotalFee))
-	paramMap.Set("spbill_create_ip", m.SpbillCreateIp)
-	paramMap.Set("time_start", m.TimeStart)
-	paramMap.Set("time_expire", m.TimeExpire)
-	paramMap.Set("goods_tag", m.GoodsTag)
-	paramMap.Set("notify_url", m.NotifyUrl)
-	paramMap.Set("trade_type", m.TradeType)
-	paramMap.Set("product_id", m.ProductId)
-	paramMap.Set("limit_pay", m.LimitPay)
-	paramMap.Set("openid", m.OpenId)
-	paramMap.Set("sign_type", "MD5")
+	data, _ := json.Marshal(map[string]*SceneInfo{"h5_info": m})
+	return string(data)
+}
+
+/**
+ * 总金额由 TotalFee 与 Amount 换算，其他参数按 xml 标签生成
+ */
+func (m *Trade) Params() url.Values {
+	paramMap := kernel.EncodeParams(m)
+	paramMap.Set("total_fee", feeParam(m.TotalFee, m.Amount))
 	
 	return paramMap
 }
 
+/**
+ * 金额，分为单位的金额为0时使用 Amount
+ */
+func feeAmount(fee uint64, amount gopay.Amount) gopay.Amount {
+	if fee == 0 {
+		return amount
+	}
+	return gopay.Fen(int64(fee))
+}
+
+/**
+ * 金额参数 分
+ */
+func feeParam(fee uint64, amount gopay.Amount) string {
+	return fmt.Sprintf("%d", feeAmount(fee, amount).Fen())
+}
+
 /**
  * 微信支付的响应结果
  */
@@ -69,6 +113,8 @@ type TradeRes struct {
 	ReturnMsg  string `xml:"return_msg"`   //返回信息
 	AppId      string `xml:"app_id"`       //应用APPId
 	MchId      string `xml:"mch_id"`       //商户号
+	SubAppId   string `xml:"sub_appid"`    //子商户应用ID
+	SubMchId   string `xml:"sub_mch_id"`   //子商户号
 	DeviceInfo string `xml:"device_info"`  //设备号
 	NonceStr   string `xml:"nonce_str"`    //随机字符串
 	Sign       string `xml:"sign"`         //签名
@@ -79,67 +125,150 @@ type TradeRes struct {
 	TradeType  string `xml:"trade_type"`   //交易类型
 	PrepayId   string `xml:"prepay_id"`    //预支付交易会话标识
 	CodeUrl    string `xml:"code_url"`     //二维码链接
+	MwebUrl    string `xml:"mweb_url"`     //H5支付跳转链接
 }
 
 /**
- * 微信查询订单
+ * 扫码支付模式一的商品二维码链接
  */
-type TradeQuery struct {
-	TransactionId string //微信订单号
-	OutTradeNo    string //商户订单号
+type NativeProduct struct {
+	ProductId string `xml:"product_id"`             //商品ID
+	TimeStamp int64  `xml:"time_stamp" wx:"number"` //时间戳 秒，为0时使用当前时间
 }
 
-func (m *TradeQuery) Params() url.Values {
-	paramMap := url.Values{}
-	paramMap.Set("transaction_id", m.TransactionId)
-	paramMap.Set("out_trade_no", m.OutTradeNo)
+func (m *NativeProduct) Params() url.Values {
+	paramMap := kernel.EncodeParams(m)
+	if m.TimeStamp == 0 {
+		paramMap.Set("time_stamp", strconv.FormatInt(time.Now().Unix(), 10))
+	}
 	
 	return paramMap
 }
 
+/**
+ * 扫码支付模式一用户扫码后微信回调的参数
+ */
+type NativeCallback struct {
+	AppId       string `xml:"appid"`        //公众账号ID
+	OpenId      string `xml:"openid"`       //用户标识
+	MchId       string `xml:"mch_id"`       //商户号
+	IsSubscribe string `xml:"is_subscribe"` //是否关注公众账号
+	NonceStr    string `xml:"nonce_str"`    //随机字符串
+	ProductId   string `xml:"product_id"`   //商品ID
+	Sign        string `xml:"sign"`         //签名
+}
+
+/**
+ * 扫码支付模式一回调的响应
+ */
+type NativeCallbackReply struct {
+	ReturnCode string `xml:"return_code"`  //返回状态码 SUCCESS、FAIL
+	ReturnMsg  string `xml:"return_msg"`   //返回信息
+	PrepayId   string `xml:"prepay_id"`    //预支付交易会话标识
+	ResultCode string `xml:"result_code"`  //业务结果 SUCCESS、FAIL
+	ErrCodeDes string `xml:"err_code_des"` //错误描述，展示给用户
+}
+
+/**
+ * 转换短链接
+ */
+type ShortUrl struct {
+	LongUrl string `xml:"long_url"` //需要转换的链接，如 weixin://wxpay/bizpayurl?...
+}
+
+/**
+ * long_url 签名使用原串，传输时需URL编码
+ */
+func (m *ShortUrl) TransportParams(signedParams url.Values) {
+	signedParams.Set("long_url", url.QueryEscape(m.LongUrl))
+}
+
+func (m *ShortUrl) IsIdempotent() bool {
+	return true
+}
+
+type ShortUrlRes struct {
+	ReturnCode string `xml:"return_code"`  //返回状态码
+	ReturnMsg  string `xml:"return_msg"`   //返回信息
+	AppId      string `xml:"appid"`        //应用APPId
+	MchId      string `xml:"mch_id"`       //商户号
+	NonceStr   string `xml:"nonce_str"`    //随机字符串
+	Sign       string `xml:"sign"`         //签名
+	ResultCode string `xml:"result_code"`  //业务结果
+	ErrCode    string `xml:"err_code"`     //错误代码
+	ErrCodeDes string `xml:"err_code_des"` //错误代码描述
+	ShortUrl   string `xml:"short_url"`    //转换后的短链接，如 weixin://wxpay/s/XXXXXX
+}
+
+/**
+ * 微信查询订单
+ */
+type TradeQuery struct {
+	TransactionId string `xml:"transaction_id"` //微信订单号
+	OutTradeNo    string `xml:"out_trade_no"`   //商户订单号
+}
+
+func (m *TradeQuery) IsIdempotent() bool {
+	return true
+}
+
 type TradeQueryRes struct {
-	ReturnCode         string `xml:"return_code"`          //返回状态码
-	ReturnMsg          string `xml:"return_msg"`           //返回信息
-	AppId              string `xml:"app_id"`               //应用APPId
-	MchId              string `xml:"mch_id"`               //商户号
-	NonceStr           string `xml:"nonce_str"`            //随机字符串
-	Sign               string `xml:"sign"`                 //签名
-	ResultCode         string `xml:"result_code"`          //业务结果
-	ResultMsg          string `xml:"result_msg"`           //业务结果描述
-	ErrCode            string `xml:"err_code"`             //错误代码
-	ErrCodeDes         string `xml:"err_code_des"`         //错误代码描述
-	DeviceInfo         string `xml:"device_info"`          //设备号
-	OpenId             string `xml:"openid"`               //用户标识
-	IsSubscribe        string `xml:"is_subscribe"`         //是否关注公众账号
-	TradeType          string `xml:"trade_type"`           //交易类型
-	TradeState         string `xml:"trade_state"`          //交易状态
-	BankType           string `xml:"bank_type"`            //付款银行
-	TotalFee           int    `xml:"total_fee"`            //订单总金额 单位分
-	SettlementTotalFee int    `xml:"settlement_total_fee"` //应结订单金额
-	FeeType            string `xml:"fee_type"`             //货币类型
-	CashFee            int    `xml:"cash_fee"`             //现金支付金额
-	CashFeeType        string `xml:"cash_fee_type"`        //现金支付币种
-	CouponFee          int    `xml:"coupon_fee"`           //代金券金额
-	CouponCount        int    `xml:"coupon_count"`         //代金券使用数量
-	TransactionId      string `xml:"transaction_id"`       //微信支付订单号
-	OutTradeNo         string `xml:"out_trade_no"`         //商户订单号
-	Attach             string `xml:"attach"`               //附加数据
-	TimeEnd            string `xml:"time_end"`             //订单支付时间
-	TradeStateDesc     string `xml:"trade_state_desc"`     //交易状态描述
+	ReturnCode         string       `xml:"return_code"`          //返回状态码
+	ReturnMsg          string       `xml:"return_msg"`           //返回信息
+	AppId              string       `xml:"app_id"`               //应用APPId
+	MchId              string       `xml:"mch_id"`               //商户号
+	SubAppId           string       `xml:"sub_appid"`            //子商户应用ID
+	SubMchId           string       `xml:"sub_mch_id"`           //子商户号
+	NonceStr           string       `xml:"nonce_str"`            //随机字符串
+	Sign               string       `xml:"sign"`                 //签名
+	ResultCode         string       `xml:"result_code"`          //业务结果
+	ResultMsg          string       `xml:"result_msg"`           //业务结果描述
+	ErrCode            string       `xml:"err_code"`             //错误代码
+	ErrCodeDes         string       `xml:"err_code_des"`         //错误代码描述
+	DeviceInfo         string       `xml:"device_info"`          //设备号
+	OpenId             string       `xml:"openid"`               //用户标识
+	IsSubscribe        string       `xml:"is_subscribe"`         //是否关注公众账号
+	SubOpenId          string       `xml:"sub_openid"`           //用户在子商户应用下的标识
+	SubIsSubscribe     string       `xml:"sub_is_subscribe"`     //是否关注子商户公众账号
+	TradeType          string       `xml:"trade_type"`           //交易类型
+	TradeState         string       `xml:"trade_state"`          //交易状态
+	BankType           string       `xml:"bank_type"`            //付款银行
+	TotalFee           int          `xml:"total_fee"`            //订单总金额 单位分
+	TotalAmount        gopay.Amount `xml:"-" amount:"total_fee"` //订单金额
+	SettlementTotalFee int          `xml:"settlement_total_fee"` //应结订单金额
+	FeeType            string       `xml:"fee_type"`             //货币类型
+	CashFee            int          `xml:"cash_fee"`             //现金支付金额
+	CashAmount         gopay.Amount `xml:"-" amount:"cash_fee"`  //现金支付金额
+	CashFeeType        string       `xml:"cash_fee_type"`        //现金支付币种
+	CouponFee          int          `xml:"coupon_fee"`           //代金券金额
+	CouponCount        int          `xml:"coupon_count"`         //代金券使用数量
+	TransactionId      string       `xml:"transaction_id"`       //微信支付订单号
+	OutTradeNo         string       `xml:"out_trade_no"`         //商户订单号
+	Attach             string       `xml:"attach"`               //附加数据
+	TimeEnd            string       `xml:"time_end"`             //订单支付时间
+	TradeStateDesc     string       `xml:"trade_state_desc"`     //交易状态描述
+
+	Coupons []*Coupon `xml:"-" indexed:"coupon_count"` //代金券列表，由 coupon_id_$n 等参数解析
+}
+
+/**
+ * 代金券，对应 coupon_type_$n、coupon_id_$n、coupon_fee_$n
+ */
+type Coupon struct {
+	CouponType string `xml:"coupon_type_$n"` //代金券类型 CASH、NO_CASH
+	CouponId   string `xml:"coupon_id_$n"`   //代金券ID
+	CouponFee  int    `xml:"coupon_fee_$n"`  //单个代金券支付金额
 }
 
 /**
  * 微信关闭订单
  */
 type TradeClose struct {
-	OutTradeNo string //商户订单号
+	OutTradeNo string `xml:"out_trade_no"` //商户订单号
 }
 
-func (m *TradeClose) Params() url.Values {
-	paramMap := url.Values{}
-	paramMap.Set("out_trade_no", m.OutTradeNo)
-	
-	return paramMap
+func (m *TradeClose) IsIdempotent() bool {
+	return true
 }
 
 type TradeCloseRes struct {
@@ -147,6 +276,8 @@ type TradeCloseRes struct {
 	ReturnMsg  string `xml:"return_msg"`   //返回信息
 	AppId      string `xml:"app_id"`       //应用APPId
 	MchId      string `xml:"mch_id"`       //商户号
+	SubAppId   string `xml:"sub_appid"`    //子商户应用ID
+	SubMchId   string `xml:"sub_mch_id"`   //子商户号
 	NonceStr   string `xml:"nonce_str"`    //随机字符串
 	Sign       string `xml:"sign"`         //签名
 	ResultCode string `xml:"result_code"`  //业务结果
@@ -155,34 +286,204 @@ type TradeCloseRes struct {
 	ErrCodeDes string `xml:"err_code_des"` //错误代码描述
 }
 
+/**
+ * 微信申请退款
+ */
+type Refund struct {
+	TransactionId string       `xml:"transaction_id"`         //微信订单号 与 OutTradeNo 二选一
+	OutTradeNo    string       `xml:"out_trade_no"`           //商户订单号
+	OutRefundNo   string       `xml:"out_refund_no"`          //商户退款单号
+	TotalFee      uint64       `xml:"total_fee" wx:"number"`  //订单金额 分
+	RefundFee     uint64       `xml:"refund_fee" wx:"number"` //退款金额 分
+	TotalAmount   gopay.Amount `xml:"-"`                      //订单金额，TotalFee 为0时使用
+	RefundAmount  gopay.Amount `xml:"-"`                      //退款金额，RefundFee 为0时使用
+	RefundFeeType string       `xml:"refund_fee_type"`        //退款货币种类
+	RefundDesc    string       `xml:"refund_desc"`            //退款原因
+	RefundAccount string       `xml:"refund_account"`         //退款资金来源
+	NotifyUrl     string       `xml:"notify_url"`             //退款结果通知url
+}
+
+/**
+ * 订单金额与退款金额由分与 gopay.Amount 换算，其他参数按 xml 标签生成
+ */
+func (m *Refund) Params() url.Values {
+	paramMap := kernel.EncodeParams(m)
+	paramMap.Set("total_fee", feeParam(m.TotalFee, m.TotalAmount))
+	paramMap.Set("refund_fee", feeParam(m.RefundFee, m.RefundAmount))
+	
+	return paramMap
+}
+
+func (m *Refund) IsIdempotent() bool {
+	return len(m.OutRefundNo) > 0
+}
+
+type RefundRes struct {
+	ReturnCode          string       `xml:"return_code"`           //返回状态码
+	ReturnMsg           string       `xml:"return_msg"`            //返回信息
+	AppId               string       `xml:"appid"`                 //应用APPId
+	MchId               string       `xml:"mch_id"`                //商户号
+	SubAppId            string       `xml:"sub_appid"`             //子商户应用ID
+	SubMchId            string       `xml:"sub_mch_id"`            //子商户号
+	NonceStr            string       `xml:"nonce_str"`             //随机字符串
+	Sign                string       `xml:"sign"`                  //签名
+	ResultCode          string       `xml:"result_code"`           //业务结果
+	ErrCode             string       `xml:"err_code"`              //错误代码
+	ErrCodeDes          string       `xml:"err_code_des"`          //错误代码描述
+	TransactionId       string       `xml:"transaction_id"`        //微信订单号
+	OutTradeNo          string       `xml:"out_trade_no"`          //商户订单号
+	OutRefundNo         string       `xml:"out_refund_no"`         //商户退款单号
+	RefundId            string       `xml:"refund_id"`             //微信退款单号
+	RefundFee           int          `xml:"refund_fee"`            //退款金额
+	RefundAmount        gopay.Amount `xml:"-" amount:"refund_fee"` //退款金额
+	SettlementRefundFee int          `xml:"settlement_refund_fee"` //应结退款金额
+	TotalFee            int          `xml:"total_fee"`             //标价金额
+	TotalAmount         gopay.Amount `xml:"-" amount:"total_fee"`  //标价金额
+	SettlementTotalFee  int          `xml:"settlement_total_fee"`  //应结订单金额
+	FeeType             string       `xml:"fee_type"`              //标价币种
+	CashFee             int          `xml:"cash_fee"`              //现金支付金额
+	CashAmount          gopay.Amount `xml:"-" amount:"cash_fee"`   //现金支付金额
+	CashFeeType         string       `xml:"cash_fee_type"`         //现金支付币种
+	CashRefundFee       int          `xml:"cash_refund_fee"`       //现金退款金额
+	CouponRefundFee     int          `xml:"coupon_refund_fee"`     //代金券退款总金额
+	CouponRefundCount   int          `xml:"coupon_refund_count"`   //退款代金券使用数量
+}
+
+/**
+ * 微信查询退款
+ */
+type RefundQuery struct {
+	TransactionId string `xml:"transaction_id"`     //微信订单号 四选一
+	OutTradeNo    string `xml:"out_trade_no"`       //商户订单号
+	OutRefundNo   string `xml:"out_refund_no"`      //商户退款单号
+	RefundId      string `xml:"refund_id"`          //微信退款单号
+	Offset        int    `xml:"offset" wx:"number"` //偏移量 订单总退款次数超过10次时使用
+}
+
+func (m *RefundQuery) IsIdempotent() bool {
+	return true
+}
+
+type RefundQueryRes struct {
+	ReturnCode         string       `xml:"return_code"`          //返回状态码
+	ReturnMsg          string       `xml:"return_msg"`           //返回信息
+	AppId              string       `xml:"appid"`                //应用APPId
+	MchId              string       `xml:"mch_id"`               //商户号
+	SubAppId           string       `xml:"sub_appid"`            //子商户应用ID
+	SubMchId           string       `xml:"sub_mch_id"`           //子商户号
+	NonceStr           string       `xml:"nonce_str"`            //随机字符串
+	Sign               string       `xml:"sign"`                 //签名
+	ResultCode         string       `xml:"result_code"`          //业务结果
+	ErrCode            string       `xml:"err_code"`             //错误代码
+	ErrCodeDes         string       `xml:"err_code_des"`         //错误代码描述
+	TotalRefundCount   int          `xml:"total_refund_count"`   //订单总退款次数
+	TransactionId      string       `xml:"transaction_id"`       //微信订单号
+	OutTradeNo         string       `xml:"out_trade_no"`         //商户订单号
+	TotalFee           int          `xml:"total_fee"`            //订单金额
+	TotalAmount        gopay.Amount `xml:"-" amount:"total_fee"` //订单金额
+	SettlementTotalFee int          `xml:"settlement_total_fee"` //应结订单金额
+	FeeType            string       `xml:"fee_type"`             //标价币种
+	CashFee            int          `xml:"cash_fee"`             //现金支付金额
+	CashAmount         gopay.Amount `xml:"-" amount:"cash_fee"`  //现金支付金额
+	RefundCount        int          `xml:"refund_count"`         //退款笔数
+
+	Refunds []*RefundItem `xml:"-" indexed:"refund_count"` //退款列表，由 refund_id_$n 等参数解析
+}
+
+/**
+ * 退款记录，对应 refund_id_$n 等参数
+ */
+type RefundItem struct {
+	OutRefundNo         string       `xml:"out_refund_no_$n"`         //商户退款单号
+	RefundId            string       `xml:"refund_id_$n"`             //微信退款单号
+	RefundChannel       string       `xml:"refund_channel_$n"`        //退款渠道
+	RefundFee           int          `xml:"refund_fee_$n"`            //申请退款金额
+	RefundAmount        gopay.Amount `xml:"-" amount:"refund_fee_$n"` //申请退款金额
+	SettlementRefundFee int          `xml:"settlement_refund_fee_$n"` //退款金额
+	CouponRefundFee     int          `xml:"coupon_refund_fee_$n"`     //总代金券退款金额
+	CouponRefundCount   int          `xml:"coupon_refund_count_$n"`   //退款代金券使用数量
+	RefundStatus        string       `xml:"refund_status_$n"`         //退款状态 SUCCESS、REFUNDCLOSE、PROCESSING、CHANGE
+	RefundAccount       string       `xml:"refund_account_$n"`        //退款资金来源
+	RefundRecvAccout    string       `xml:"refund_recv_accout_$n"`    //退款入账账户
+	RefundSuccessTime   string       `xml:"refund_success_time_$n"`   //退款成功时间
+
+	Coupons []*RefundCoupon `xml:"-" indexed:"coupon_refund_count_$n"` //退款代金券列表
+}
+
+/**
+ * 退款代金券，对应 coupon_type_$n_$m、coupon_refund_id_$n_$m、coupon_refund_fee_$n_$m
+ */
+type RefundCoupon struct {
+	CouponType      string `xml:"coupon_type_$n_$m"`       //代金券类型
+	CouponRefundId  string `xml:"coupon_refund_id_$n_$m"`  //退款代金券ID
+	CouponRefundFee int    `xml:"coupon_refund_fee_$n_$m"` //单个退款代金券支付金额
+}
+
+/**
+ * 微信下载对账单
+ */
+type DownloadBill struct {
+	BillDate string `xml:"bill_date"`               //对账单日期 格式20140603
+	BillType string `xml:"bill_type" default:"ALL"` //账单类型 ALL SUCCESS REFUND RECHARGE_REFUND
+	TarType  string `xml:"tar_type"`                //压缩账单 GZIP
+}
+
+func (m *DownloadBill) IsIdempotent() bool {
+	return true
+}
+
+type DownloadBillRes struct {
+	ReturnCode string `xml:"return_code"` //返回状态码
+	ReturnMsg  string `xml:"return_msg"`  //返回信息
+	ErrorCode  string `xml:"error_code"`  //错误码
+}
+
+/**
+ * 异步通知的响应报文，未响应 SUCCESS 时微信会重新发送通知
+ */
+type NotifyReply struct {
+	XMLName    xml.Name `xml:"xml"`
+	ReturnCode string   `xml:"return_code"` //返回状态码 SUCCESS、FAIL
+	ReturnMsg  string   `xml:"return_msg"`  //返回信息
+}
+
 /**
  * 异步通知
  */
 type NotifyRes struct {
-	ReturnCode         string `xml:"return_code"`
-	ReturnMsg          string `xml:"return_msg"`
-	AppId              string `xml:"appid"`
-	MCHId              string `xml:"mch_id"`
-	DeviceInfo         string `xml:"device_info"`
-	NonceStr           string `xml:"nonce_str"`
-	Sign               string `xml:"sign"`
-	SignType           string `xml:"sign_type"`
-	ResultCode         string `xml:"result_code"`
-	ErrCode            string `xml:"err_code"`
-	ErrCodeDes         string `xml:"err_code_des"`
-	OpenId             string `xml:"openid"`
-	IsSubscribe        string `xml:"is_subscribe"`
-	TradeType          string `xml:"trade_type"`
-	BankType           string `xml:"bank_type"`
-	TotalFee           int    `xml:"total_fee"`
-	SettlementTotalFee int    `xml:"settlement_total_fee"`
-	FeeType            string `xml:"fee_type"`
-	CashFee            int    `xml:"cash_fee"`
-	CashFeeType        string `xml:"cash_fee_type"`
-	CouponFee          int    `xml:"coupon_fee"`
-	CouponCount        int    `xml:"coupon_count"`
-	TransactionId      string `xml:"transaction_id"`
-	OutTradeNo         string `xml:"out_trade_no"`
-	Attach             string `xml:"attach"`
-	TimeEnd            string `xml:"time_end"`
+	ReturnCode         string       `xml:"return_code"`
+	ReturnMsg          string       `xml:"return_msg"`
+	AppId              string       `xml:"appid"`
+	MCHId              string       `xml:"mch_id"`
+	SubAppId           string       `xml:"sub_appid"`
+	SubMchId           string       `xml:"sub_mch_id"`
+	DeviceInfo         string       `xml:"device_info"`
+	NonceStr           string       `xml:"nonce_str"`
+	Sign               string       `xml:"sign"`
+	SignType           string       `xml:"sign_type"`
+	ResultCode         string       `xml:"result_code"`
+	ErrCode            string       `xml:"err_code"`
+	ErrCodeDes         string       `xml:"err_code_des"`
+	OpenId             string       `xml:"openid"`
+	IsSubscribe        string       `xml:"is_subscribe"`
+	SubOpenId          string       `xml:"sub_openid"`
+	SubIsSubscribe     string       `xml:"sub_is_subscribe"`
+	TradeType          string       `xml:"trade_type"`
+	BankType           string       `xml:"bank_type"`
+	TotalFee           int          `xml:"total_fee"`
+	TotalAmount        gopay.Amount `xml:"-" amount:"total_fee"`
+	SettlementTotalFee int          `xml:"settlement_total_fee"`
+	FeeType            string       `xml:"fee_type"`
+	CashFee            int          `xml:"cash_fee"`
+	CashAmount         gopay.Amount `xml:"-" amount:"cash_fee"`
+	CashFeeType        string       `xml:"cash_fee_type"`
+	CouponFee          int          `xml:"coupon_fee"`
+	CouponCount        int          `xml:"coupon_count"`
+	TransactionId      string       `xml:"transaction_id"`
+	OutTradeNo         string       `xml:"out_trade_no"`
+	Attach             string       `xml:"attach"`
+	TimeEnd            string       `xml:"time_end"`
+	ContractId         string       `xml:"contract_id"`
+
+	Coupons []*Coupon `xml:"-" indexed:"coupon_count"` //代金券列表，由 coupon_id_$n 等参数解析
 }
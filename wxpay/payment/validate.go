@@ -0,0 +1,143 @@
+package payment
+
+import (
+	"net"
+	"regexp"
+
+	"github.com/shinmigo/gopay"
+)
+
+var (
+	outTradeNoPattern = regexp.MustCompile(`^[A-Za-z0-9_\-|*@]+$`)
+	timePattern       = regexp.MustCompile(`^[0-9]{14}$`)
+	billDatePattern   = regexp.MustCompile(`^[0-9]{8}$`)
+)
+
+/**
+ * 校验统一下单参数
+ */
+func (m *Trade) Validate() error {
+	validator := &gopay.Validator{}
+	m.validate(validator)
+	return validator.Err()
+}
+
+func (m *Trade) validate(validator *gopay.Validator) {
+	validator.MaxLength("device_info", m.DeviceInfo, 32)
+	validator.Required("body", m.Body)
+	validator.MaxLength("body", m.Body, 128)
+	validator.MaxLength("detail", m.Detail, 6000)
+	validator.MaxLength("attach", m.Attach, 127)
+	validator.Required("out_trade_no", m.OutTradeNo)
+	validator.MaxLength("out_trade_no", m.OutTradeNo, 32)
+	validator.Match("out_trade_no", m.OutTradeNo, outTradeNoPattern)
+	validator.OneOf("fee_type", m.FeeType, "CNY")
+	validator.Amount("total_fee", feeAmount(m.TotalFee, m.Amount), gopay.WxPayMinAmount, 0)
+	validator.Required("spbill_create_ip", m.SpbillCreateIp)
+	validator.Check(m.SpbillCreateIp == "" || net.ParseIP(m.SpbillCreateIp) != nil, "spbill_create_ip", "is not a valid IP")
+	validator.Match("time_start", m.TimeStart, timePattern)
+	validator.Match("time_expire", m.TimeExpire, timePattern)
+	validator.MaxLength("goods_tag", m.GoodsTag, 32)
+	validator.Required("notify_url", m.NotifyUrl)
+	validator.MaxLength("notify_url", m.NotifyUrl, 256)
+	validator.Required("trade_type", m.TradeType)
+	validator.OneOf("trade_type", m.TradeType, WX_JSAPI, WX_NATIVE, WX_APP, WX_MWEB)
+	if m.TradeType == WX_JSAPI {
+		validator.Check(len(m.OpenId) > 0 || len(m.SubOpenId) > 0, "openid|sub_openid", "is required")
+	}
+	if m.TradeType == WX_NATIVE {
+		validator.Required("product_id", m.ProductId)
+	}
+	if m.TradeType == WX_MWEB {
+		validator.Check(m.SceneInfo != nil, "scene_info", "is required")
+	}
+	if m.SceneInfo != nil {
+		m.SceneInfo.validate(validator)
+	}
+	validator.MaxLength("product_id", m.ProductId, 32)
+	validator.OneOf("limit_pay", m.LimitPay, "no_credit")
+	validator.MaxLength("openid", m.OpenId, 128)
+	validator.MaxLength("sub_openid", m.SubOpenId, 128)
+	validator.OneOf("receipt", m.Receipt, "Y")
+}
+
+func (m *SceneInfo) validate(validator *gopay.Validator) {
+	validator.Required("scene_info.type", m.Type)
+	validator.OneOf("scene_info.type", m.Type, SCENE_IOS, SCENE_ANDROID, SCENE_WAP)
+	switch m.Type {
+	case SCENE_WAP:
+		validator.Required("scene_info.wap_url", m.WapUrl)
+		validator.Required("scene_info.wap_name", m.WapName)
+	case SCENE_IOS:
+		validator.Required("scene_info.app_name", m.AppName)
+		validator.Required("scene_info.bundle_id", m.BundleId)
+	case SCENE_ANDROID:
+		validator.Required("scene_info.app_name", m.AppName)
+		validator.Required("scene_info.package_name", m.PackageName)
+	}
+	validator.MaxLength("scene_info", m.String(), 256)
+}
+
+func (m *NativeProduct) Validate() error {
+	validator := &gopay.Validator{}
+	validator.Required("product_id", m.ProductId)
+	validator.MaxLength("product_id", m.ProductId, 32)
+	validator.Check(m.TimeStamp >= 0, "time_stamp", "must not be negative")
+	return validator.Err()
+}
+
+func (m *ShortUrl) Validate() error {
+	validator := &gopay.Validator{}
+	validator.Required("long_url", m.LongUrl)
+	return validator.Err()
+}
+
+func (m *TradeQuery) Validate() error {
+	validator := &gopay.Validator{}
+	validator.AtLeastOne("transaction_id", m.TransactionId, "out_trade_no", m.OutTradeNo)
+	validator.MaxLength("transaction_id", m.TransactionId, 32)
+	validator.MaxLength("out_trade_no", m.OutTradeNo, 32)
+	return validator.Err()
+}
+
+func (m *TradeClose) Validate() error {
+	validator := &gopay.Validator{}
+	validator.Required("out_trade_no", m.OutTradeNo)
+	validator.MaxLength("out_trade_no", m.OutTradeNo, 32)
+	return validator.Err()
+}
+
+func (m *Refund) Validate() error {
+	validator := &gopay.Validator{}
+	validator.AtLeastOne("transaction_id", m.TransactionId, "out_trade_no", m.OutTradeNo)
+	validator.MaxLength("transaction_id", m.TransactionId, 32)
+	validator.MaxLength("out_trade_no", m.OutTradeNo, 32)
+	validator.Required("out_refund_no", m.OutRefundNo)
+	validator.MaxLength("out_refund_no", m.OutRefundNo, 64)
+	validator.Match("out_refund_no", m.OutRefundNo, outTradeNoPattern)
+	totalFee := feeAmount(m.TotalFee, m.TotalAmount)
+	refundFee := feeAmount(m.RefundFee, m.RefundAmount)
+	validator.Amount("total_fee", totalFee, gopay.WxPayMinAmount, 0)
+	validator.Amount("refund_fee", refundFee, gopay.WxPayMinAmount, totalFee)
+	validator.OneOf("refund_fee_type", m.RefundFeeType, "CNY")
+	validator.MaxLength("refund_desc", m.RefundDesc, 80)
+	validator.OneOf("refund_account", m.RefundAccount, "REFUND_SOURCE_UNSETTLED_FUNDS", "REFUND_SOURCE_RECHARGE_FUNDS")
+	validator.MaxLength("notify_url", m.NotifyUrl, 256)
+	return validator.Err()
+}
+
+func (m *RefundQuery) Validate() error {
+	validator := &gopay.Validator{}
+	validator.AtLeastOne("transaction_id", m.TransactionId, "out_trade_no", m.OutTradeNo, "out_refund_no", m.OutRefundNo, "refund_id", m.RefundId)
+	validator.Check(m.Offset >= 0, "offset", "must not be negative")
+	return validator.Err()
+}
+
+func (m *DownloadBill) Validate() error {
+	validator := &gopay.Validator{}
+	validator.Required("bill_date", m.BillDate)
+	validator.Match("bill_date", m.BillDate, billDatePattern)
+	validator.OneOf("bill_type", m.BillType, "ALL", "SUCCESS", "REFUND", "RECHARGE_REFUND")
+	validator.OneOf("tar_type", m.TarType, "GZIP")
+	return validator.Err()
+}
@@ -0,0 +1,166 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/shinmigo/gopay/wxpay/payment"
+)
+
+const (
+	/**
+	 * 仿真测试验收用例的订单金额，单位分
+	 */
+	CasePayFee    = 551 // 用例1003-1 公众号/扫码/APP支付成功
+	CaseRefundFee = 552 // 用例1003-2 公众号/扫码/APP支付退款
+)
+
+/**
+ * 验收用例执行结果
+ */
+type CaseResult struct {
+	Name    string        // 用例名称
+	Passed  bool          // 是否通过
+	Err     error         // 失败原因
+	Elapsed time.Duration // 执行耗时
+}
+
+/**
+ * 微信支付沙箱仿真测试验收用例执行器，客户端需使用非生产环境初始化
+ */
+type Runner struct {
+	Payment        *payment.Payment
+	TradeType      string // 支付类型，默认 JSAPI
+	OpenId         string // 用户标识，JSAPI支付时必传
+	NotifyUrl      string // 异步通知地址
+	SpbillCreateIp string // 终端IP
+	BillDate       string // 对账单日期，默认前一天
+	OutTradeNoFunc func(caseName string) string
+}
+
+type caseFunc func() error
+
+/**
+ * 依次执行全部验收用例
+ */
+func (m *Runner) Run() []*CaseResult {
+	payTradeNo := m.outTradeNo("pay")
+	refundTradeNo := m.outTradeNo("refund")
+
+	caseList := []struct {
+		name string
+		run  caseFunc
+	}{
+		{"1003-1 统一下单 551", func() error { return m.pay(payTradeNo, CasePayFee) }},
+		{"1003-1 查询订单 551", func() error { return m.query(payTradeNo, CasePayFee) }},
+		{"1003-2 统一下单 552", func() error { return m.pay(refundTradeNo, CaseRefundFee) }},
+		{"1003-2 查询订单 552", func() error { return m.query(refundTradeNo, CaseRefundFee) }},
+		{"1003-2 申请退款 552", func() error { return m.refund(refundTradeNo, CaseRefundFee) }},
+		{"1003-2 查询退款 552", func() error { return m.refundQuery(refundTradeNo) }},
+		{"1003-3 下载对账单", m.downloadBill},
+	}
+
+	results := make([]*CaseResult, 0, len(caseList))
+	for _, item := range caseList {
+		startTime := time.Now()
+		err := item.run()
+		results = append(results, &CaseResult{
+			Name:    item.name,
+			Passed:  err == nil,
+			Err:     err,
+			Elapsed: time.Since(startTime),
+		})
+	}
+
+	return results
+}
+
+func (m *Runner) pay(outTradeNo string, totalFee uint64) error {
+	tradeType := m.TradeType
+	if len(tradeType) == 0 {
+		tradeType = payment.WX_JSAPI
+	}
+	res, err := m.Payment.Pay(&payment.Trade{
+		Body:           "sandbox",
+		OutTradeNo:     outTradeNo,
+		TotalFee:       totalFee,
+		SpbillCreateIp: m.SpbillCreateIp,
+		NotifyUrl:      m.NotifyUrl,
+		TradeType:      tradeType,
+		OpenId:         m.OpenId,
+		ProductId:      outTradeNo,
+	})
+	if err != nil {
+		return err
+	}
+	return checkResult(res.ResultCode, res.ErrCode, res.ErrCodeDes)
+}
+
+func (m *Runner) query(outTradeNo string, totalFee int) error {
+	res, err := m.Payment.Query(&payment.TradeQuery{OutTradeNo: outTradeNo})
+	if err != nil {
+		return err
+	}
+	if err = checkResult(res.ResultCode, res.ErrCode, res.ErrCodeDes); err != nil {
+		return err
+	}
+	if res.TradeState != "SUCCESS" {
+		return fmt.Errorf("unexpected trade_state %s", res.TradeState)
+	}
+	if res.TotalFee != totalFee {
+		return fmt.Errorf("unexpected total_fee %d", res.TotalFee)
+	}
+	return nil
+}
+
+func (m *Runner) refund(outTradeNo string, totalFee uint64) error {
+	res, err := m.Payment.Refund(&payment.Refund{
+		OutTradeNo:  outTradeNo,
+		OutRefundNo: outTradeNo,
+		TotalFee:    totalFee,
+		RefundFee:   totalFee,
+	})
+	if err != nil {
+		return err
+	}
+	return checkResult(res.ResultCode, res.ErrCode, res.ErrCodeDes)
+}
+
+func (m *Runner) refundQuery(outTradeNo string) error {
+	res, err := m.Payment.RefundQuery(&payment.RefundQuery{OutTradeNo: outTradeNo})
+	if err != nil {
+		return err
+	}
+	return checkResult(res.ResultCode, res.ErrCode, res.ErrCodeDes)
+}
+
+func (m *Runner) downloadBill() error {
+	billDate := m.BillDate
+	if len(billDate) == 0 {
+		billDate = time.Now().AddDate(0, 0, -1).Format("20060102")
+	}
+	bill, err := m.Payment.DownloadBill(&payment.DownloadBill{BillDate: billDate})
+	if err != nil {
+		return err
+	}
+	if len(bill) == 0 {
+		return errors.New("empty bill")
+	}
+	return nil
+}
+
+func (m *Runner) outTradeNo(caseName string) string {
+	if m.OutTradeNoFunc != nil {
+		return m.OutTradeNoFunc(caseName)
+	}
+	return "sandbox" + caseName + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+func checkResult(resultCode, errCode, errCodeDes string) error {
+	if resultCode != "SUCCESS" {
+		return fmt.Errorf("%s - %s", errCode, errCodeDes)
+	}
+	return nil
+}